@@ -0,0 +1,234 @@
+// Command keyrotation encrypts and validates API keys from the shell through the keyrotation
+// library, so operators get its stdin key passing, redaction and error handling instead of
+// invoking the private binary directly.
+//
+// Usage:
+//
+//	keyrotation [flags] encrypt [-date yyyy-mm-dd] [-key-file path]
+//	keyrotation [flags] validate -encrypted value -date yyyy-mm-dd [-key-file path]
+//	keyrotation [flags] validate-today -encrypted value [-tolerance minutes] [-key-file path]
+//	keyrotation [flags] date [-date yyyy-mm-dd]
+//
+// The API key is read from the file named by -key-file or, without it, from stdin; one trailing
+// line ending is dropped. Validation prints true or false and exits 0 for a valid key, 1 for an
+// invalid one and 2 on error.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/pawincpe/key-rotation/pkg/keyrotation"
+)
+
+// Exit statuses
+const (
+	exitOK      = 0
+	exitInvalid = 1
+	exitError   = 2
+)
+
+// dateLayout is the layout of the -date flag
+const dateLayout = "2006-01-02"
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command line args and returns the exit status
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	global := flag.NewFlagSet("keyrotation", flag.ContinueOnError)
+	global.SetOutput(stderr)
+	binaryPath := global.String("binary", "", "path to keyrotation-binary (default $"+keyrotation.BinaryEnvVar+" or ./keyrotation-binary)")
+	timeout := global.Duration("timeout", keyrotation.DefaultTimeout, "per-invocation timeout")
+	legacyArgv := global.Bool("legacy-argv", false, "pass the key on argv, for binaries without the stdin protocol")
+	global.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: keyrotation [flags] encrypt|validate|validate-today|date [command flags]")
+		global.PrintDefaults()
+	}
+	if err := global.Parse(args); err != nil {
+		return exitError
+	}
+	if global.NArg() == 0 {
+		global.Usage()
+		return exitError
+	}
+
+	opts := []keyrotation.Option{keyrotation.WithTimeout(*timeout), keyrotation.WithLegacyArgv(*legacyArgv)}
+	if *binaryPath != "" {
+		opts = append(opts, keyrotation.WithBinaryPath(*binaryPath))
+	}
+	helper, err := keyrotation.NewWithOptions(opts...)
+	if err != nil {
+		fmt.Fprintln(stderr, "keyrotation:", err)
+		return exitError
+	}
+	defer helper.Close()
+
+	c := &command{helper: helper, stdin: stdin, stdout: stdout, stderr: stderr}
+	name, cmdArgs := global.Arg(0), global.Args()[1:]
+	switch name {
+	case "encrypt":
+		return c.encrypt(cmdArgs)
+	case "validate":
+		return c.validate(cmdArgs)
+	case "validate-today":
+		return c.validateToday(cmdArgs)
+	case "date":
+		return c.date(cmdArgs)
+	}
+	fmt.Fprintf(stderr, "keyrotation: unknown command %q\n", name)
+	global.Usage()
+	return exitError
+}
+
+// command carries the state shared by the subcommands
+type command struct {
+	helper *keyrotation.KeyRotationHelper
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// flags returns a flag set for the subcommand name that reports errors to c.stderr
+func (c *command) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	return fs
+}
+
+// fail reports err and returns exitError
+func (c *command) fail(err error) int {
+	fmt.Fprintln(c.stderr, "keyrotation:", err)
+	return exitError
+}
+
+// readKey reads the API key from keyFile, or from stdin when it is empty
+func (c *command) readKey(keyFile string) (string, error) {
+	var data []byte
+	var err error
+	if keyFile != "" {
+		data, err = os.ReadFile(keyFile)
+	} else {
+		data, err = io.ReadAll(c.stdin)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read API key: %w", err)
+	}
+	return strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r"), nil
+}
+
+// parseDate parses a -date flag value as midnight UTC
+func parseDate(s string) (time.Time, error) {
+	date, err := time.Parse(dateLayout, s)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid -date %q: expected yyyy-mm-dd", s)
+	}
+	return date, nil
+}
+
+func (c *command) encrypt(args []string) int {
+	fs := c.flags("encrypt")
+	dateFlag := fs.String("date", "", "encrypt for this date instead of today (yyyy-mm-dd)")
+	keyFile := fs.String("key-file", "", "read the API key from this file instead of stdin")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	apiKey, err := c.readKey(*keyFile)
+	if err != nil {
+		return c.fail(err)
+	}
+
+	var encrypted string
+	if *dateFlag == "" {
+		encrypted, err = c.helper.EncryptApiKey(apiKey)
+	} else {
+		var date time.Time
+		if date, err = parseDate(*dateFlag); err != nil {
+			return c.fail(err)
+		}
+		encrypted, err = c.helper.EncryptApiKeyWithDate(apiKey, date)
+	}
+	if err != nil {
+		return c.fail(err)
+	}
+	fmt.Fprintln(c.stdout, encrypted)
+	return exitOK
+}
+
+func (c *command) validate(args []string) int {
+	fs := c.flags("validate")
+	encrypted := fs.String("encrypted", "", "the ciphertext to check (required)")
+	dateFlag := fs.String("date", "", "the date the ciphertext was issued for (yyyy-mm-dd, required)")
+	keyFile := fs.String("key-file", "", "read the API key from this file instead of stdin")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if *encrypted == "" || *dateFlag == "" {
+		return c.fail(errors.New("validate requires -encrypted and -date"))
+	}
+	date, err := parseDate(*dateFlag)
+	if err != nil {
+		return c.fail(err)
+	}
+	apiKey, err := c.readKey(*keyFile)
+	if err != nil {
+		return c.fail(err)
+	}
+	return c.report(c.helper.ValidateApiKey(apiKey, *encrypted, date))
+}
+
+func (c *command) validateToday(args []string) int {
+	fs := c.flags("validate-today")
+	encrypted := fs.String("encrypted", "", "the ciphertext to check (required)")
+	tolerance := fs.Int("tolerance", 0, "also accept the dates this many minutes either side of now")
+	keyFile := fs.String("key-file", "", "read the API key from this file instead of stdin")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	if *encrypted == "" {
+		return c.fail(errors.New("validate-today requires -encrypted"))
+	}
+	apiKey, err := c.readKey(*keyFile)
+	if err != nil {
+		return c.fail(err)
+	}
+	if *tolerance > 0 {
+		return c.report(c.helper.ValidateApiKeyTodayWithTolerance(apiKey, *encrypted, *tolerance))
+	}
+	return c.report(c.helper.ValidateApiKeyToday(apiKey, *encrypted))
+}
+
+// report prints a validation result and returns the matching exit status
+func (c *command) report(isValid bool, err error) int {
+	if err != nil {
+		return c.fail(err)
+	}
+	fmt.Fprintln(c.stdout, isValid)
+	if !isValid {
+		return exitInvalid
+	}
+	return exitOK
+}
+
+func (c *command) date(args []string) int {
+	fs := c.flags("date")
+	dateFlag := fs.String("date", "", "print the date string for this date instead of today (yyyy-mm-dd)")
+	if err := fs.Parse(args); err != nil {
+		return exitError
+	}
+	date := time.Now()
+	if *dateFlag != "" {
+		var err error
+		if date, err = parseDate(*dateFlag); err != nil {
+			return c.fail(err)
+		}
+	}
+	fmt.Fprintln(c.stdout, c.helper.GetDateString(date))
+	return exitOK
+}
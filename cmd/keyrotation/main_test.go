@@ -0,0 +1,81 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// bundledBinary is the legacy binary shipped at the repository root
+var bundledBinary = filepath.Join("..", "..", "keyrotation-binary")
+
+// cli runs the command line with the bundled binary, feeding it stdin, and returns its exit
+// status and trimmed output
+func cli(t *testing.T, stdin string, args ...string) (int, string, string) {
+	t.Helper()
+	if _, err := os.Stat(bundledBinary); err != nil {
+		t.Skipf("Bundled binary not available: %v", err)
+	}
+	var stdout, stderr bytes.Buffer
+	code := run(append([]string{"-binary", bundledBinary, "-legacy-argv"}, args...), strings.NewReader(stdin), &stdout, &stderr)
+	return code, strings.TrimSpace(stdout.String()), strings.TrimSpace(stderr.String())
+}
+
+func TestCLI_EncryptAndValidate(t *testing.T) {
+	const want = "267392d8b0035e47f02c98d68701371a923a8eee30761fd662a1f8bdcfb8239a"
+
+	code, out, errOut := cli(t, "abc\n", "encrypt", "-date", "2024-01-15")
+	if code != exitOK || out != want {
+		t.Fatalf("encrypt: expected %s, got %d %q (stderr %q)", want, code, out, errOut)
+	}
+
+	if code, out, _ := cli(t, "abc\n", "validate", "-encrypted", want, "-date", "2024-01-15"); code != exitOK || out != "true" {
+		t.Errorf("validate: expected true, got %d %q", code, out)
+	}
+	if code, out, _ := cli(t, "abc\n", "validate", "-encrypted", want, "-date", "2024-01-16"); code != exitInvalid || out != "false" {
+		t.Errorf("validate: expected false, got %d %q", code, out)
+	}
+}
+
+func TestCLI_ValidateTodayFromKeyFile(t *testing.T) {
+	keyFile := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(keyFile, []byte("testApiKey123\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	code, encrypted, errOut := cli(t, "", "encrypt", "-key-file", keyFile)
+	if code != exitOK {
+		t.Fatalf("encrypt: exit %d: %s", code, errOut)
+	}
+	if code, out, errOut := cli(t, "", "validate-today", "-encrypted", encrypted, "-key-file", keyFile); code != exitOK || out != "true" {
+		t.Errorf("validate-today: expected true, got %d %q (stderr %q)", code, out, errOut)
+	}
+	if code, out, errOut := cli(t, "", "validate-today", "-encrypted", encrypted, "-tolerance", "5", "-key-file", keyFile); code != exitOK || out != "true" {
+		t.Errorf("validate-today -tolerance: expected true, got %d %q (stderr %q)", code, out, errOut)
+	}
+}
+
+func TestCLI_Date(t *testing.T) {
+	if code, out, _ := cli(t, "", "date", "-date", "2024-01-15"); code != exitOK || out != "20240115" {
+		t.Errorf("date: expected 20240115, got %d %q", code, out)
+	}
+}
+
+func TestCLI_Errors(t *testing.T) {
+	cases := [][]string{
+		{},
+		{"no-such-command"},
+		{"validate", "-date", "2024-01-15"},
+		{"encrypt", "-date", "15/01/2024"},
+	}
+	for _, args := range cases {
+		if code, _, errOut := cli(t, "abc\n", args...); code != exitError || errOut == "" {
+			t.Errorf("%q: expected exit %d with a message, got %d %q", args, exitError, code, errOut)
+		}
+	}
+	if code, _, errOut := cli(t, "", "encrypt"); code != exitError || !strings.Contains(errOut, "empty") {
+		t.Errorf("Expected an empty key to be rejected, got %d %q", code, errOut)
+	}
+}
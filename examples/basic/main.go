@@ -11,7 +11,8 @@ import (
 )
 
 func main() {
-	fmt.Println("=== Go Key Rotation Library (Public) - Basic Example ===\n")
+	fmt.Println("=== Go Key Rotation Library (Public) - Basic Example ===")
+	fmt.Println()
 
 	// Check if binary exists
 	binaryPath := "../golang-key-rotation-private/build/keyrotation-binary"
@@ -0,0 +1,95 @@
+package keyrotation
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"errors"
+	"fmt"
+	"hash"
+	"strings"
+	"time"
+)
+
+// Algorithm names a hash the binary can compute keys with
+type Algorithm string
+
+const (
+	// AlgorithmSHA256 is the binary's original hash and the default
+	AlgorithmSHA256 Algorithm = "sha256"
+	// AlgorithmSHA512 hashes with SHA-512, producing 128 hex characters
+	AlgorithmSHA512 Algorithm = "sha512"
+)
+
+// algoFlag selects the binary's hash. Like stdinFlag it precedes the subcommand, and it is only
+// sent for algorithms other than the default so binaries that predate it keep working.
+const algoFlag = "--algo"
+
+// Algorithm reports the hash the helper encrypts and validates with. Keys must be validated by a
+// helper configured with the algorithm they were encrypted with, so store it alongside them.
+func (k *KeyRotationHelper) Algorithm() Algorithm {
+	if k.algorithm == "" {
+		return AlgorithmSHA256
+	}
+	return k.algorithm
+}
+
+// algorithmFlags returns the flags selecting the helper's algorithm, HMAC mode and rotation
+// interval, which are empty for the default
+func (k *KeyRotationHelper) algorithmFlags() []string {
+	var flags []string
+	if algorithm := k.Algorithm(); algorithm != AlgorithmSHA256 {
+		flags = append(flags, algoFlag, string(algorithm))
+	}
+	if k.pepper != nil {
+		flags = append(flags, hmacFlag)
+	}
+	if k.subDaily() {
+		flags = append(flags, intervalFlag, fmt.Sprintf("%dh", k.RotationInterval()/time.Hour))
+	}
+	return flags
+}
+
+// algorithmError reports err as ErrUnsupportedAlgorithm when the binary rejected the algorithm,
+// either because it does not recognize algoFlag or because it lacks the requested hash, and as
+// ErrUnsupported when it does not recognize hmacFlag, intervalFlag or stdinLengthFlag
+func (k *KeyRotationHelper) algorithmError(err error) error {
+	if !errors.Is(err, ErrBinaryExecFailed) {
+		return err
+	}
+	msg := err.Error()
+	if k.pepper != nil && strings.Contains(msg, "Unknown command: "+hmacFlag) {
+		return fmt.Errorf("%w: HMAC mode: %w", ErrUnsupported, err)
+	}
+	if k.subDaily() && strings.Contains(msg, "Unknown command: "+intervalFlag) {
+		return fmt.Errorf("%w: rotation every %s: %w", ErrUnsupported, k.RotationInterval(), err)
+	}
+	if strings.Contains(msg, "Unknown command: "+stdinLengthFlag) {
+		return fmt.Errorf("%w: API keys containing line breaks or NUL bytes: %w", ErrUnsupported, err)
+	}
+	if k.Algorithm() == AlgorithmSHA256 {
+		return err
+	}
+	if strings.Contains(msg, "Unknown command: "+algoFlag) || strings.Contains(strings.ToLower(msg), "unsupported algorithm") {
+		return fmt.Errorf("%w %s: %w", ErrUnsupportedAlgorithm, k.Algorithm(), err)
+	}
+	return err
+}
+
+// newHash returns the hash implementing algorithm, keyed with pepper as an HMAC when it is
+// non-nil, for the in-memory engine
+func newHash(algorithm Algorithm, pepper []byte) (hash.Hash, error) {
+	var h func() hash.Hash
+	switch algorithm {
+	case AlgorithmSHA256:
+		h = sha256.New
+	case AlgorithmSHA512:
+		h = sha512.New
+	default:
+		return nil, fmt.Errorf("Unsupported algorithm: %s", algorithm)
+	}
+	if pepper != nil {
+		return hmac.New(h, pepper), nil
+	}
+	return h(), nil
+}
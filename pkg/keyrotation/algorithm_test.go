@@ -0,0 +1,89 @@
+package keyrotation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithAlgorithm_SHA512(t *testing.T) {
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	helpers := map[string]*KeyRotationHelper{
+		"exec":       newFakeHelper(t, WithAlgorithm(AlgorithmSHA512)),
+		"persistent": newPersistentFakeHelper(t, WithAlgorithm(AlgorithmSHA512)),
+		"in-memory":  NewInMemory(WithAlgorithm(AlgorithmSHA512)),
+	}
+	sha256Key, err := NewInMemory().EncryptApiKeyWithDate("abc", date)
+	if err != nil {
+		t.Fatalf("EncryptApiKeyWithDate failed: %v", err)
+	}
+
+	var want string
+	for name, helper := range helpers {
+		encrypted, err := helper.EncryptApiKeyWithDate("abc", date)
+		if err != nil {
+			t.Fatalf("%s: EncryptApiKeyWithDate failed: %v", name, err)
+		}
+		if len(encrypted) != 128 {
+			t.Errorf("%s: Expected a 128 character SHA-512 key, got %q", name, encrypted)
+		}
+		if want == "" {
+			want = encrypted
+		} else if encrypted != want {
+			t.Errorf("%s: Expected %s, got %s", name, want, encrypted)
+		}
+
+		if valid, err := helper.ValidateApiKey("abc", encrypted, date); err != nil || !valid {
+			t.Errorf("%s: Expected SHA-512 key to validate, got %v, %v", name, valid, err)
+		}
+		if valid, err := helper.ValidateApiKey("abc", sha256Key, date); !errors.Is(err, ErrMalformedCiphertext) || valid {
+			t.Errorf("%s: Expected SHA-256 key to be rejected as malformed, got %v, %v", name, valid, err)
+		}
+		if got := helper.Algorithm(); got != AlgorithmSHA512 {
+			t.Errorf("%s: Expected Algorithm() to be %s, got %s", name, AlgorithmSHA512, got)
+		}
+	}
+}
+
+func TestWithAlgorithm_DefaultSendsNoFlag(t *testing.T) {
+	t.Setenv(fakeModeEnv, "legacy")
+	helper := newFakeHelper(t, WithLegacyArgv(true), WithAlgorithm(AlgorithmSHA256))
+
+	if _, err := helper.EncryptApiKey("abc"); err != nil {
+		t.Errorf("Expected legacy binary to accept the default algorithm, got %v", err)
+	}
+	if got := New().Algorithm(); got != AlgorithmSHA256 {
+		t.Errorf("Expected default algorithm %s, got %s", AlgorithmSHA256, got)
+	}
+}
+
+func TestWithAlgorithm_Unsupported(t *testing.T) {
+	helpers := map[string]*KeyRotationHelper{
+		"exec":      newFakeHelper(t, WithAlgorithm("md5")),
+		"in-memory": NewInMemory(WithAlgorithm("md5")),
+	}
+	for name, helper := range helpers {
+		if _, err := helper.EncryptApiKey("abc"); !errors.Is(err, ErrUnsupportedAlgorithm) || !errors.Is(err, ErrUnsupported) {
+			t.Errorf("%s: Expected ErrUnsupportedAlgorithm, got %v", name, err)
+		}
+	}
+
+	if _, err := NewPersistent(newFakeHelper(t).binaryPath, WithAlgorithm("md5")); !errors.Is(err, ErrUnsupportedAlgorithm) {
+		t.Errorf("Expected NewPersistent to report ErrUnsupportedAlgorithm, got %v", err)
+	}
+
+	t.Setenv(fakeModeEnv, "legacy")
+	if _, err := newFakeHelper(t, WithAlgorithm(AlgorithmSHA512)).EncryptApiKey("abc"); !errors.Is(err, ErrUnsupportedAlgorithm) {
+		t.Errorf("Expected binary without --algo to report ErrUnsupportedAlgorithm, got %v", err)
+	}
+	if _, err := newFakeHelper(t, WithAlgorithm(AlgorithmSHA512)).ValidateApiKeyWithTolerance("abc", strings.Repeat("0", 128), time.Now(), 5); !errors.Is(err, ErrUnsupportedAlgorithm) {
+		t.Errorf("Expected tolerance validation to report ErrUnsupportedAlgorithm, got %v", err)
+	}
+}
+
+func TestWithAlgorithm_Empty(t *testing.T) {
+	if _, err := NewWithOptions(WithAlgorithm("")); err == nil {
+		t.Error("Expected an empty algorithm to be rejected")
+	}
+}
@@ -0,0 +1,299 @@
+package keyrotation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// batchErrorPrefix marks a batch output line that reports a per-item failure instead of a result
+const batchErrorPrefix = "error: "
+
+// BatchError reports the first item of a batch call that could not be processed
+type BatchError struct {
+	// Index is the position of the failed item in the input slice
+	Index int
+	// Err describes why the item failed
+	Err error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("keyrotation: batch item %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
+
+// KeyPair is a plaintext API key together with the ciphertext it is expected to validate against
+type KeyPair struct {
+	ApiKey       string
+	EncryptedKey string
+}
+
+// EncryptApiKeyBatch encrypts many API keys with the current date in a single binary invocation.
+// With WithLocation or WithClock, the date is sent to the encrypt-date-batch subcommand, and
+// binaries without it fail with ErrUnsupported. Results are aligned with keys. If some keys fail,
+// the returned slice still holds the ciphertexts of the keys that succeeded (failed positions are
+// empty) and the error is a *BatchError naming the first failed index.
+func (k *KeyRotationHelper) EncryptApiKeyBatch(keys []string) ([]string, error) {
+	return k.EncryptApiKeyBatchContext(context.Background(), keys)
+}
+
+// EncryptApiKeyBatchContext is like EncryptApiKeyBatch but kills the binary if ctx is done first
+func (k *KeyRotationHelper) EncryptApiKeyBatchContext(ctx context.Context, keys []string) ([]string, error) {
+	if len(keys) == 0 {
+		return []string{}, nil
+	}
+	for i, key := range keys {
+		if err := k.checkBatchKey(key); err != nil {
+			return nil, fmt.Errorf("failed to encrypt API key batch: %w", &BatchError{Index: i, Err: err})
+		}
+	}
+
+	command, prefix := k.todayBatch("encrypt-batch")
+	items := make([]string, len(keys))
+	for i, key := range keys {
+		items[i] = prefix + k.binaryKey(key)
+	}
+	lines, err := k.runTodayBatch(ctx, command, items, keys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt API key batch: %w", err)
+	}
+
+	results := make([]string, len(keys))
+	var firstErr error
+	for i, line := range lines {
+		encrypted, err := k.batchCiphertext(line, keys[i])
+		if err != nil {
+			if firstErr == nil {
+				firstErr = &BatchError{Index: i, Err: err}
+			}
+			continue
+		}
+		results[i] = encrypted
+	}
+	if firstErr != nil {
+		return results, fmt.Errorf("failed to encrypt API key batch: %w", firstErr)
+	}
+
+	return results, nil
+}
+
+// checkBatchKey rejects an API key that cannot be sent on an encrypt-batch input line
+func (k *KeyRotationHelper) checkBatchKey(key string) error {
+	if strings.ContainsAny(key, "\r\n") {
+		return errors.New("API key contains a line break")
+	}
+	if len(key) > k.maxKeyLen {
+		return k.checkKey(key)
+	}
+	return nil
+}
+
+// batchCiphertext returns the ciphertext on an encrypt-batch output line, or the per-item failure
+// it reports with apiKey redacted
+func (k *KeyRotationHelper) batchCiphertext(line, apiKey string) (string, error) {
+	msg, failed := strings.CutPrefix(line, batchErrorPrefix)
+	if !failed && line != "" {
+		return line, nil
+	}
+	if !failed {
+		msg = "empty ciphertext"
+	}
+	return "", errors.New(k.redact(msg, apiKey))
+}
+
+// ValidateApiKeyBatch validates many key pairs against today's date in a single binary invocation.
+// With WithLocation or WithClock, the date is sent to the validate-date-batch subcommand, and
+// binaries without it fail with ErrUnsupported. The returned slice is aligned with pairs: result i
+// is the outcome for pairs[i]. An empty input returns an empty slice without invoking the binary.
+// If some pairs cannot be checked, their results are false and the error is a *BatchError naming
+// the first failed index.
+func (k *KeyRotationHelper) ValidateApiKeyBatch(pairs []KeyPair) ([]bool, error) {
+	return k.ValidateApiKeyBatchContext(context.Background(), pairs)
+}
+
+// ValidateApiKeyBatchContext is like ValidateApiKeyBatch but kills the binary if ctx is done first
+func (k *KeyRotationHelper) ValidateApiKeyBatchContext(ctx context.Context, pairs []KeyPair) ([]bool, error) {
+	if len(pairs) == 0 {
+		return []bool{}, nil
+	}
+
+	// Each line is "<encrypted>\t<apikey>": the ciphertext never contains a tab, so the key, which
+	// is everything after the tab that ends the ciphertext, may contain tabs of its own
+	command, prefix := k.todayBatch("validate-batch")
+	items := make([]string, len(pairs))
+	for i, pair := range pairs {
+		if err := k.checkPair(pair); err != nil {
+			return nil, fmt.Errorf("failed to validate API key batch: %w", &BatchError{Index: i, Err: err})
+		}
+		items[i] = prefix + k.binaryCiphertext(pair.EncryptedKey) + "\t" + k.binaryKey(pair.ApiKey)
+	}
+
+	lines, err := k.runTodayBatch(ctx, command, items, pairKeys(pairs))
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate API key batch: %w", err)
+	}
+
+	results := make([]bool, len(pairs))
+	var firstErr error
+	for i, line := range lines {
+		if isValid, ok := k.parseValidation(line); ok {
+			results[i] = isValid
+			continue
+		}
+		if firstErr == nil {
+			if msg, failed := strings.CutPrefix(line, batchErrorPrefix); failed {
+				firstErr = &BatchError{Index: i, Err: errors.New(k.redact(msg, pairs[i].ApiKey))}
+			} else {
+				firstErr = &BatchError{Index: i, Err: fmt.Errorf("%w: %q", ErrUnexpectedOutput, k.redact(line, pairs[i].ApiKey))}
+			}
+		}
+	}
+	if firstErr != nil {
+		return results, fmt.Errorf("failed to validate API key batch: %w", firstErr)
+	}
+
+	return results, nil
+}
+
+// ValidateApiKeyBatchWithinDays validates many key pairs against today and each of the previous
+// daysBack days, in the helper's location, in a single invocation of the binary's
+// validate-date-batch subcommand. Result i is true if pairs[i] matches any date in the window.
+// Binaries without the subcommand are instead invoked once per pair and date, as
+// ValidateApiKeyWithinDays would. Errors are reported as for ValidateApiKeyBatch.
+func (k *KeyRotationHelper) ValidateApiKeyBatchWithinDays(pairs []KeyPair, daysBack int) ([]bool, error) {
+	return k.ValidateApiKeyBatchWithinDaysContext(context.Background(), pairs, daysBack)
+}
+
+// ValidateApiKeyBatchWithinDaysContext is like ValidateApiKeyBatchWithinDays but kills the binary if ctx is done first
+func (k *KeyRotationHelper) ValidateApiKeyBatchWithinDaysContext(ctx context.Context, pairs []KeyPair, daysBack int) ([]bool, error) {
+	if daysBack < 0 {
+		return nil, errors.New("keyrotation: daysBack must not be negative")
+	}
+	if daysBack >= MaxRangeDays {
+		return nil, fmt.Errorf("keyrotation: window spans more than %d days", MaxRangeDays)
+	}
+	if err := k.requireDaily("windowed validation"); err != nil {
+		return nil, err
+	}
+	if len(pairs) == 0 {
+		return []bool{}, nil
+	}
+
+	// Each line is "<date>\t<encrypted>\t<apikey>", one per pair and date, grouped by pair
+	today := k.startOfDay(k.now())
+	days := daysBack + 1
+	items := make([]string, 0, len(pairs)*days)
+	for i, pair := range pairs {
+		if err := k.checkPair(pair); err != nil {
+			return nil, fmt.Errorf("failed to validate API key batch: %w", &BatchError{Index: i, Err: err})
+		}
+		for d := range days {
+			items = append(items, k.binaryDate(today.AddDate(0, 0, -d))+"\t"+k.binaryCiphertext(pair.EncryptedKey)+"\t"+k.binaryKey(pair.ApiKey))
+		}
+	}
+
+	lines, err := k.runBatch(ctx, "validate-date-batch", items, pairKeys(pairs))
+	if isUnknownCommand(err) {
+		return k.validateEachWithinDays(ctx, pairs, daysBack)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to validate API key batch: %w", err)
+	}
+
+	results := make([]bool, len(pairs))
+	var firstErr error
+	for j, line := range lines {
+		i := j / days
+		if isValid, ok := k.parseValidation(line); ok {
+			results[i] = results[i] || isValid
+			continue
+		}
+		if firstErr == nil {
+			if msg, failed := strings.CutPrefix(line, batchErrorPrefix); failed {
+				firstErr = &BatchError{Index: i, Err: errors.New(k.redact(msg, pairs[i].ApiKey))}
+			} else {
+				firstErr = &BatchError{Index: i, Err: fmt.Errorf("%w: %q", ErrUnexpectedOutput, k.redact(line, pairs[i].ApiKey))}
+			}
+		}
+	}
+	if firstErr != nil {
+		return results, fmt.Errorf("failed to validate API key batch: %w", firstErr)
+	}
+
+	return results, nil
+}
+
+// validateEachWithinDays validates key pairs against a window with one invocation per pair and date
+func (k *KeyRotationHelper) validateEachWithinDays(ctx context.Context, pairs []KeyPair, daysBack int) ([]bool, error) {
+	results := make([]bool, len(pairs))
+	for i, pair := range pairs {
+		isValid, err := k.ValidateApiKeyWithinDaysContext(ctx, pair.ApiKey, pair.EncryptedKey, daysBack)
+		if err != nil {
+			return results, fmt.Errorf("failed to validate API key batch: %w", &BatchError{Index: i, Err: err})
+		}
+		results[i] = isValid
+	}
+	return results, nil
+}
+
+// pairKeys returns the API keys of pairs
+func pairKeys(pairs []KeyPair) []string {
+	keys := make([]string, len(pairs))
+	for i, pair := range pairs {
+		keys[i] = pair.ApiKey
+	}
+	return keys
+}
+
+// checkPair rejects a key pair that cannot be sent on a batch input line
+func (k *KeyRotationHelper) checkPair(pair KeyPair) error {
+	if strings.ContainsAny(pair.ApiKey, "\r\n") || strings.ContainsAny(pair.EncryptedKey, "\t\r\n") {
+		return errors.New("key pair contains a line break or tab")
+	}
+	if len(pair.ApiKey) > k.maxKeyLen {
+		return k.checkKey(pair.ApiKey)
+	}
+	return k.checkCiphertext(pair.EncryptedKey)
+}
+
+// todayBatch returns the subcommand that carries out the batch command for today's date, and the
+// prefix of each of its input lines. The binary's own UTC clock picks the date unless WithLocation
+// or WithClock is set, in which case the date-taking variant is given the date explicitly.
+func (k *KeyRotationHelper) todayBatch(command string) (string, string) {
+	if k.binaryClock() {
+		return command, ""
+	}
+	return strings.TrimSuffix(command, "-batch") + "-date-batch", k.binaryDate(k.now()) + "\t"
+}
+
+// runTodayBatch is runBatch for a subcommand returned by todayBatch, reporting a binary without
+// the date-taking variant as ErrUnsupported
+func (k *KeyRotationHelper) runTodayBatch(ctx context.Context, command string, items, apiKeys []string) ([]string, error) {
+	lines, err := k.runBatch(ctx, command, items, apiKeys)
+	if isUnknownCommand(err) && strings.HasSuffix(command, "-date-batch") {
+		err = fmt.Errorf("%w: %s: %w", ErrUnsupported, command, err)
+	}
+	return lines, err
+}
+
+// runBatch sends one input line per item to a batch subcommand and returns exactly one output line
+// per item. apiKeys are the plaintext keys the items were built from, for redaction.
+func (k *KeyRotationHelper) runBatch(ctx context.Context, command string, items, apiKeys []string) ([]string, error) {
+	out, err := k.call(ctx, request{command: command, input: strings.Join(items, "\n") + "\n", inputKeys: apiKeys})
+	if err != nil {
+		return nil, err
+	}
+
+	lines := strings.Split(out, "\n")
+	if len(lines) != len(items) {
+		return nil, fmt.Errorf("%w: expected %d result lines, got %d", ErrUnexpectedOutput, len(items), len(lines))
+	}
+	for i := range lines {
+		lines[i] = strings.TrimSpace(lines[i])
+	}
+	return lines, nil
+}
@@ -0,0 +1,171 @@
+package keyrotation
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestKeyRotationHelper_EncryptApiKeyBatch(t *testing.T) {
+	helper := newFakeHelper(t)
+	keys := []string{"first", "second", "third"}
+
+	results, err := helper.EncryptApiKeyBatch(keys)
+	if err != nil {
+		t.Fatalf("EncryptApiKeyBatch failed: %v", err)
+	}
+	if len(results) != len(keys) {
+		t.Fatalf("Expected %d results, got %d", len(keys), len(results))
+	}
+	today := time.Now().UTC()
+	for i, key := range keys {
+		if results[i] != fakeHash(key, today) {
+			t.Errorf("Result %d: expected %s, got %s", i, fakeHash(key, today), results[i])
+		}
+	}
+}
+
+func TestKeyRotationHelper_EncryptApiKeyBatchPartialFailure(t *testing.T) {
+	helper := newFakeHelper(t)
+
+	results, err := helper.EncryptApiKeyBatch([]string{"first", "", "third"})
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) {
+		t.Fatalf("Expected *BatchError, got %v", err)
+	}
+	if batchErr.Index != 1 {
+		t.Errorf("Expected failed index 1, got %d", batchErr.Index)
+	}
+	if len(results) != 3 || results[0] == "" || results[1] != "" || results[2] == "" {
+		t.Errorf("Expected partial results around the failed index, got %q", results)
+	}
+}
+
+func TestKeyRotationHelper_EncryptApiKeyBatchEmpty(t *testing.T) {
+	results, err := NewWithBinaryPath("/nonexistent").EncryptApiKeyBatch(nil)
+	if err != nil {
+		t.Fatalf("Expected empty batch not to invoke the binary, got %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected no results, got %q", results)
+	}
+}
+
+func TestKeyRotationHelper_EncryptApiKeyBatchRejectsLineBreaks(t *testing.T) {
+	_, err := newFakeHelper(t).EncryptApiKeyBatch([]string{"ok", "bad\nkey"})
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.Index != 1 {
+		t.Errorf("Expected *BatchError at index 1, got %v", err)
+	}
+}
+
+func TestKeyRotationHelper_ValidateApiKeyBatch(t *testing.T) {
+	helper := newFakeHelper(t)
+	today := time.Now().UTC()
+	pairs := []KeyPair{
+		{ApiKey: "first", EncryptedKey: fakeHash("first", today)},
+		{ApiKey: "second", EncryptedKey: fakeHash("other", today)},
+		{ApiKey: "key\twith tab", EncryptedKey: fakeHash("key\twith tab", today)},
+	}
+
+	results, err := helper.ValidateApiKeyBatch(pairs)
+	if err != nil {
+		t.Fatalf("ValidateApiKeyBatch failed: %v", err)
+	}
+	expected := []bool{true, false, true}
+	for i := range expected {
+		if results[i] != expected[i] {
+			t.Errorf("Result %d: expected %t, got %t", i, expected[i], results[i])
+		}
+	}
+}
+
+func TestKeyRotationHelper_ValidateApiKeyBatchEmpty(t *testing.T) {
+	results, err := NewWithBinaryPath("/nonexistent").ValidateApiKeyBatch([]KeyPair{})
+	if err != nil {
+		t.Fatalf("Expected empty batch not to invoke the binary, got %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected no results, got %v", results)
+	}
+}
+
+func TestKeyRotationHelper_ValidateApiKeyBatchWithinDays(t *testing.T) {
+	today := time.Now().UTC()
+	pairs := []KeyPair{
+		{"a", fakeHash("a", today)},
+		{"b", fakeHash("b", today.AddDate(0, 0, -2))},
+		{"c", fakeHash("other", today)},
+		{"d", fakeHash("d", today.AddDate(0, 0, -5))},
+	}
+	want := []bool{true, true, false, false}
+
+	recorder := &eventRecorder{}
+	helper := newFakeHelper(t, WithLogger(recorder))
+	results, err := helper.ValidateApiKeyBatchWithinDays(pairs, 3)
+	if err != nil || !slices.Equal(results, want) {
+		t.Errorf("Expected %v, got %v, %v", want, results, err)
+	}
+	if calls := len(recorder.recorded()); calls != 1 {
+		t.Errorf("Expected a single invocation, got %d", calls)
+	}
+
+	if results, err := NewInMemory().ValidateApiKeyBatchWithinDays(pairs, 3); err != nil || !slices.Equal(results, want) {
+		t.Errorf("in-memory: Expected %v, got %v, %v", want, results, err)
+	}
+
+	// Binaries without validate-date-batch are invoked once per pair and date
+	t.Setenv(fakeModeEnv, "legacy")
+	legacy := newFakeHelper(t, WithLegacyArgv(true))
+	if results, err := legacy.ValidateApiKeyBatchWithinDays(pairs, 3); err != nil || !slices.Equal(results, want) {
+		t.Errorf("legacy: Expected %v, got %v, %v", want, results, err)
+	}
+
+	if _, err := helper.ValidateApiKeyBatchWithinDays(pairs, -1); err == nil {
+		t.Error("Expected error for negative daysBack")
+	}
+	_, err = helper.ValidateApiKeyBatchWithinDays([]KeyPair{pairs[0], {"x", "bad\tvalue"}}, 1)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.Index != 1 {
+		t.Errorf("Expected BatchError at index 1, got %v", err)
+	}
+}
+
+func TestKeyRotationHelper_BatchWithLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+20", 20*60*60)
+	// 12:00 UTC is already the next day at UTC+20
+	clock := func() time.Time { return time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC) }
+	for name, helper := range map[string]*KeyRotationHelper{
+		"exec":      newFakeHelper(t, WithLocation(loc), WithClock(clock)),
+		"in-memory": NewInMemory(WithLocation(loc), WithClock(clock)),
+	} {
+		single, err := helper.EncryptApiKey("first")
+		if err != nil {
+			t.Fatalf("%s: EncryptApiKey failed: %v", name, err)
+		}
+		batch, err := helper.EncryptApiKeyBatch([]string{"first"})
+		if err != nil || batch[0] != single {
+			t.Errorf("%s: Expected the batch to encrypt for %s like EncryptApiKey, got %q, %v", name, single, batch, err)
+		}
+		if valid, err := helper.ValidateApiKeyToday("first", single); err != nil || !valid {
+			t.Errorf("%s: Expected today's key to validate, got %v, %v", name, valid, err)
+		}
+		results, err := helper.ValidateApiKeyBatch([]KeyPair{{ApiKey: "first", EncryptedKey: single}})
+		if err != nil || !results[0] {
+			t.Errorf("%s: Expected the batch to validate today's key, got %v, %v", name, results, err)
+		}
+	}
+}
+
+func TestKeyRotationHelper_BatchWithLocationUnsupported(t *testing.T) {
+	t.Setenv(fakeModeEnv, "legacy")
+	helper := newFakeHelper(t, WithLocation(time.FixedZone("UTC+20", 20*60*60)))
+
+	if _, err := helper.EncryptApiKeyBatch([]string{"first"}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported without encrypt-date-batch, got %v", err)
+	}
+	if _, err := helper.ValidateApiKeyBatch([]KeyPair{{ApiKey: "first", EncryptedKey: wrongCiphertext}}); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported without validate-date-batch, got %v", err)
+	}
+}
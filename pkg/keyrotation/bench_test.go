@@ -0,0 +1,80 @@
+package keyrotation
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// privateBinaryPath is where the private project builds the real binary, relative to this package
+const privateBinaryPath = "../golang-key-rotation-private/build/keyrotation-binary"
+
+// benchmarkBinaries runs bench against the fake binary built into the test executable, which
+// answers before any test setup runs and so measures little beyond the wrapper and process start,
+// and against the private binary when it is available, skipping that case otherwise
+func benchmarkBinaries(b *testing.B, bench func(b *testing.B, binaryPath string)) {
+	b.Run("fake", func(b *testing.B) {
+		exe, err := os.Executable()
+		if err != nil {
+			b.Fatalf("Failed to locate test executable: %v", err)
+		}
+		bench(b, exe)
+	})
+	b.Run("private", func(b *testing.B) {
+		if _, err := os.Stat(privateBinaryPath); os.IsNotExist(err) {
+			b.Skip("Binary not found, skipping benchmark")
+		}
+		absPath, err := filepath.Abs(privateBinaryPath)
+		if err != nil {
+			b.Fatalf("Failed to get absolute path: %v", err)
+		}
+		bench(b, absPath)
+	})
+}
+
+func BenchmarkEncryptApiKey(b *testing.B) {
+	benchmarkBinaries(b, func(b *testing.B, binaryPath string) {
+		helper := NewWithBinaryPath(binaryPath)
+		b.ReportAllocs()
+		for b.Loop() {
+			if _, err := helper.EncryptApiKey("testApiKey123"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkValidateApiKeyToday(b *testing.B) {
+	benchmarkBinaries(b, func(b *testing.B, binaryPath string) {
+		benchmarkValidateToday(b, NewWithBinaryPath(binaryPath))
+	})
+}
+
+func BenchmarkPersistent_ValidateApiKeyToday(b *testing.B) {
+	benchmarkBinaries(b, func(b *testing.B, binaryPath string) {
+		helper, err := NewPersistent(binaryPath)
+		if err != nil {
+			b.Skipf("Binary cannot run persistently, skipping benchmark: %v", err)
+		}
+		defer helper.Close()
+		benchmarkValidateToday(b, helper)
+	})
+}
+
+// benchmarkValidateToday measures validating a key encrypted by helper for today
+func benchmarkValidateToday(b *testing.B, helper *KeyRotationHelper) {
+	encrypted, err := helper.EncryptApiKey("testApiKey123")
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for b.Loop() {
+		isValid, err := helper.ValidateApiKeyToday("testApiKey123", encrypted)
+		if err != nil {
+			b.Fatal(err)
+		}
+		if !isValid {
+			b.Fatal("Expected key to validate")
+		}
+	}
+}
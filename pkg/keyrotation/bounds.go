@@ -0,0 +1,19 @@
+package keyrotation
+
+import (
+	"fmt"
+	"time"
+)
+
+// checkDate rejects encryption dates outside the bounds set with WithEarliestDate and
+// WithMaxFutureDays, comparing rotation dates in the helper's location
+func (k *KeyRotationHelper) checkDate(t time.Time) error {
+	date := k.startOfDay(t)
+	if !k.earliestDate.IsZero() && date.Before(k.startOfDay(k.earliestDate)) {
+		return fmt.Errorf("%w: %s is before the earliest allowed date %s", ErrDateOutOfRange, k.binaryDate(t), k.binaryDate(k.earliestDate))
+	}
+	if k.maxFutureDays > 0 && date.After(k.startOfDay(k.now()).AddDate(0, 0, k.maxFutureDays)) {
+		return fmt.Errorf("%w: %s is more than %d days in the future", ErrDateOutOfRange, k.binaryDate(t), k.maxFutureDays)
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package keyrotation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithMaxFutureDays(t *testing.T) {
+	helper := NewInMemory(WithMaxFutureDays(30))
+	now := time.Now()
+
+	if _, err := helper.EncryptApiKeyWithDate("testApiKey123", now.AddDate(0, 0, 30)); err != nil {
+		t.Errorf("Expected date 30 days ahead to be accepted, got %v", err)
+	}
+	if _, err := helper.EncryptApiKeyWithDate("testApiKey123", now.AddDate(0, 0, 31)); !errors.Is(err, ErrDateOutOfRange) {
+		t.Errorf("Expected ErrDateOutOfRange 31 days ahead, got %v", err)
+	}
+	if _, err := helper.EncryptApiKeyWithDate("testApiKey123", time.Date(3000, 1, 1, 0, 0, 0, 0, time.UTC)); !errors.Is(err, ErrDateOutOfRange) {
+		t.Errorf("Expected ErrDateOutOfRange for the year 3000, got %v", err)
+	}
+	if _, err := helper.RotationSchedule("testApiKey123", now, now.AddDate(0, 0, 60)); !errors.Is(err, ErrDateOutOfRange) {
+		t.Errorf("Expected schedule past the bound to be rejected, got %v", err)
+	}
+}
+
+func TestWithEarliestDate(t *testing.T) {
+	epoch := time.Date(2020, 1, 1, 15, 0, 0, 0, time.UTC)
+	helper := NewInMemory(WithEarliestDate(epoch))
+
+	if _, err := helper.EncryptApiKeyWithDate("testApiKey123", time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Errorf("Expected the earliest date itself to be accepted, got %v", err)
+	}
+	if _, err := helper.EncryptApiKeyWithDate("testApiKey123", time.Date(2019, 12, 31, 0, 0, 0, 0, time.UTC)); !errors.Is(err, ErrDateOutOfRange) {
+		t.Errorf("Expected ErrDateOutOfRange before the earliest date, got %v", err)
+	}
+	if err := helper.Ping(context.Background()); err != nil {
+		t.Errorf("Expected Ping to ignore the date bounds, got %v", err)
+	}
+}
+
+func TestDateBounds_OffByDefault(t *testing.T) {
+	if _, err := NewInMemory().EncryptApiKeyWithDate("testApiKey123", time.Date(3000, 1, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Errorf("Expected far-future dates to be accepted by default, got %v", err)
+	}
+	if _, err := NewWithOptions(WithMaxFutureDays(0)); err == nil {
+		t.Error("Expected non-positive max future days to be rejected")
+	}
+}
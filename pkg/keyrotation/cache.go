@@ -0,0 +1,131 @@
+package keyrotation
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// validationCache is a bounded LRU of validation results for a single rotation date. Entries are
+// keyed by a digest of the inputs so the cache never retains plaintext API keys, and the whole
+// cache is dropped as soon as a lookup is made for a different date.
+type validationCache struct {
+	size int
+
+	mu    sync.Mutex
+	date  string
+	order *list.List // of *cacheEntry, most recently used first
+	items map[[sha256.Size]byte]*list.Element
+}
+
+type cacheEntry struct {
+	key     [sha256.Size]byte
+	isValid bool
+}
+
+func newValidationCache(size int) *validationCache {
+	return &validationCache{
+		size:  size,
+		order: list.New(),
+		items: make(map[[sha256.Size]byte]*list.Element),
+	}
+}
+
+// cacheKey digests the inputs of a validation. The encrypted key is length-prefixed, since it is
+// caller-supplied, so distinct inputs can never be concatenated into the same digest.
+func cacheKey(apiKey, encryptedKey, date string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(date + ":" + strconv.Itoa(len(encryptedKey)) + ":" + encryptedKey + apiKey))
+}
+
+// roll drops every entry if date is not the cached date. The caller must hold c.mu.
+func (c *validationCache) roll(date string) {
+	if c.date != date {
+		c.date = date
+		c.order.Init()
+		clear(c.items)
+	}
+}
+
+// clear drops every entry
+func (c *validationCache) clear() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.date = ""
+	c.order.Init()
+	clear(c.items)
+}
+
+func (c *validationCache) get(date string, key [sha256.Size]byte) (isValid, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.roll(date)
+	elem, ok := c.items[key]
+	if !ok {
+		return false, false
+	}
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cacheEntry).isValid, true
+}
+
+func (c *validationCache) add(date string, key [sha256.Size]byte, isValid bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.roll(date)
+	if elem, ok := c.items[key]; ok {
+		elem.Value.(*cacheEntry).isValid = isValid
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.items[key] = c.order.PushFront(&cacheEntry{key: key, isValid: isValid})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// len reports the number of cached results
+func (c *validationCache) len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+// cachedValidation returns the cached result of validating apiKey against encryptedKey for the
+// date of t, calling validate on a miss. Only results for the current date are cached, and errors
+// never are.
+func (k *KeyRotationHelper) cachedValidation(apiKey, encryptedKey string, t time.Time, validate func() (bool, error)) (bool, error) {
+	if k.cache == nil {
+		return validate()
+	}
+	today := k.GetDateString(k.now())
+	if k.GetDateString(t) != today {
+		return validate()
+	}
+
+	key := cacheKey(apiKey, encryptedKey, today)
+	if isValid, ok := k.cache.get(today, key); ok {
+		return isValid, nil
+	}
+	isValid, err := validate()
+	if err == nil {
+		k.cache.add(today, key, isValid)
+	}
+	return isValid, err
+}
+
+// RefreshCaches discards everything the helper has computed for the current rotation date, as
+// the date rollover would: cached validation results, the ciphertexts precomputed for keys
+// registered with RegisterKeys, and the file loaded with LoadValidCiphertexts, which is read
+// again by the next validation. Registered keys stay registered. Call it after stepping the
+// system clock, for example on an NTP correction, so results are not reused until the next
+// rollover. It does nothing for caches that are not in use.
+func (k *KeyRotationHelper) RefreshCaches() {
+	if k.cache != nil {
+		k.cache.clear()
+	}
+	k.expireRegistry()
+	k.expireLoaded()
+}
@@ -0,0 +1,154 @@
+package keyrotation
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWithValidationCache_SkipsBinary(t *testing.T) {
+	recorder := &eventRecorder{}
+	helper := newFakeHelper(t, WithLogger(recorder), WithValidationCache(16))
+	testApiKey := "testApiKey123"
+	encrypted := fakeHash(testApiKey, time.Now().UTC())
+
+	for range 3 {
+		isValid, err := helper.ValidateApiKeyToday(testApiKey, encrypted)
+		if err != nil || !isValid {
+			t.Fatalf("Expected key to validate, got %v, %v", isValid, err)
+		}
+		isValid, err = helper.ValidateApiKeyToday(testApiKey, wrongCiphertext)
+		if err != nil || isValid {
+			t.Fatalf("Expected wrong key to be rejected, got %v, %v", isValid, err)
+		}
+	}
+	if calls := len(recorder.recorded()); calls != 2 {
+		t.Errorf("Expected 2 binary invocations, got %d", calls)
+	}
+
+	// Today's result is shared with ValidateApiKey; other dates bypass the cache
+	if isValid, err := helper.ValidateApiKey(testApiKey, encrypted, time.Now()); err != nil || !isValid {
+		t.Errorf("Expected cached key to validate, got %v, %v", isValid, err)
+	}
+	yesterday := time.Now().AddDate(0, 0, -1)
+	for range 2 {
+		helper.ValidateApiKey(testApiKey, encrypted, yesterday)
+	}
+	if calls := len(recorder.recorded()); calls != 4 {
+		t.Errorf("Expected uncached dates to call the binary, got %d invocations", calls)
+	}
+}
+
+func TestWithValidationCache_ErrorsNotCached(t *testing.T) {
+	recorder := &eventRecorder{}
+	missing := filepath.Join(t.TempDir(), "keyrotation-binary")
+	helper := NewWithBinaryPath(missing, WithLogger(recorder), WithValidationCache(16))
+
+	for range 2 {
+		if _, err := helper.ValidateApiKeyToday("testApiKey123", wrongCiphertext); err == nil {
+			t.Fatal("Expected missing binary to fail")
+		}
+	}
+	if calls := len(recorder.recorded()); calls != 2 {
+		t.Errorf("Expected failures to be retried against the binary, got %d invocations", calls)
+	}
+}
+
+func TestValidationCache_EvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newValidationCache(2)
+	a, b, c := cacheKey("a", "x", "20240115"), cacheKey("b", "x", "20240115"), cacheKey("c", "x", "20240115")
+
+	cache.add("20240115", a, true)
+	cache.add("20240115", b, false)
+	cache.get("20240115", a)
+	cache.add("20240115", c, true)
+
+	if _, ok := cache.get("20240115", b); ok {
+		t.Error("Expected least recently used entry to be evicted")
+	}
+	if isValid, ok := cache.get("20240115", a); !ok || !isValid {
+		t.Errorf("Expected recently used entry to survive, got %v, %v", isValid, ok)
+	}
+	if cache.len() != 2 {
+		t.Errorf("Expected cache to hold 2 entries, got %d", cache.len())
+	}
+}
+
+func TestValidationCache_DropsOtherDates(t *testing.T) {
+	cache := newValidationCache(2)
+	key := cacheKey("a", "x", "20240115")
+	cache.add("20240115", key, true)
+
+	if _, ok := cache.get("20240116", key); ok {
+		t.Error("Expected entry from a previous date to be discarded")
+	}
+	if cache.len() != 0 {
+		t.Errorf("Expected date rollover to empty the cache, got %d entries", cache.len())
+	}
+}
+
+func TestCacheKey_Unambiguous(t *testing.T) {
+	if cacheKey("b\x00c", "a", "20240115") == cacheKey("c", "a\x00b", "20240115") {
+		t.Error("Expected distinct inputs to produce distinct cache keys")
+	}
+	if cacheKey("bc", "a", "20240115") == cacheKey("c", "ab", "20240115") {
+		t.Error("Expected distinct inputs to produce distinct cache keys")
+	}
+}
+
+func TestRefreshCaches(t *testing.T) {
+	testApiKey := "testApiKey123"
+	encrypted := fakeHash(testApiKey, time.Now().UTC())
+
+	t.Run("validation cache", func(t *testing.T) {
+		recorder := &eventRecorder{}
+		helper := newFakeHelper(t, WithLogger(recorder), WithValidationCache(16))
+		helper.ValidateApiKeyToday(testApiKey, encrypted)
+		helper.ValidateApiKeyToday(testApiKey, encrypted)
+		helper.RefreshCaches()
+		if isValid, err := helper.ValidateApiKeyToday(testApiKey, encrypted); err != nil || !isValid {
+			t.Fatalf("Expected key to validate, got %v, %v", isValid, err)
+		}
+		if calls := len(recorder.recorded()); calls != 2 {
+			t.Errorf("Expected the refresh to force a binary invocation, got %d invocations", calls)
+		}
+	})
+
+	t.Run("registered keys", func(t *testing.T) {
+		recorder := &eventRecorder{}
+		helper := newFakeHelper(t, WithLogger(recorder))
+		if err := helper.RegisterKeys([]string{testApiKey}); err != nil {
+			t.Fatalf("RegisterKeys failed: %v", err)
+		}
+		helper.RefreshCaches()
+		for range 2 {
+			if isValid, err := helper.ValidateApiKeyToday(testApiKey, encrypted); err != nil || !isValid {
+				t.Fatalf("Expected registered key to validate, got %v, %v", isValid, err)
+			}
+		}
+		if calls := len(recorder.recorded()); calls != 2 {
+			t.Errorf("Expected the ciphertext to be recomputed once after the refresh, got %d invocations", calls)
+		}
+	})
+
+	t.Run("loaded ciphertexts", func(t *testing.T) {
+		helper := NewInMemory()
+		path := writeCiphertexts(t, filepath.Join(t.TempDir(), "valid.txt"), wrongCiphertext)
+		if err := helper.LoadValidCiphertexts(path); err != nil {
+			t.Fatalf("LoadValidCiphertexts failed: %v", err)
+		}
+		writeCiphertexts(t, path, encrypted)
+		helper.RefreshCaches()
+		if isValid, err := helper.ValidateAgainstLoaded(encrypted); err != nil || !isValid {
+			t.Errorf("Expected the file to be read again after the refresh, got %v, %v", isValid, err)
+		}
+	})
+}
+
+func TestRefreshCaches_NoCaches(t *testing.T) {
+	helper := NewInMemory()
+	helper.RefreshCaches()
+	if _, err := helper.ValidateApiKeyToday("testApiKey123", wrongCiphertext); err != nil {
+		t.Errorf("Expected validation to work after a no-op refresh, got %v", err)
+	}
+}
@@ -0,0 +1,98 @@
+package keyrotation
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// legacyCapabilities are the features of binaries that predate the capabilities subcommand, which
+// the wrapper assumes when it is missing
+var legacyCapabilities = BinaryCapabilities{
+	Commands:   []string{"encrypt", "encrypt-date", "validate", "validate-date", "validate-tolerance"},
+	Algorithms: []Algorithm{AlgorithmSHA256},
+}
+
+// BinaryCapabilities describes the features the deployed binary supports
+type BinaryCapabilities struct {
+	// Reported is true when the binary listed its capabilities, and false when it has no
+	// capabilities subcommand and the rest of the struct describes the original release instead
+	Reported bool
+	// Commands are the subcommands the binary implements, such as "validate-tolerance-date"
+	Commands []string
+	// Algorithms are the hashes the binary accepts for WithAlgorithm
+	Algorithms []Algorithm
+	// HMAC reports support for WithPepper
+	HMAC bool
+	// SubDaily reports support for sub-daily WithRotationInterval values
+	SubDaily bool
+	// JSON reports support for the JSON output EncryptApiKeyJSON requests
+	JSON bool
+}
+
+// Supports reports whether the binary implements command
+func (c BinaryCapabilities) Supports(command string) bool {
+	return slices.Contains(c.Commands, command)
+}
+
+// capabilitiesCache holds the answer of the first successful Capabilities query
+type capabilitiesCache struct {
+	mu   sync.Mutex
+	caps *BinaryCapabilities
+}
+
+// Capabilities reports the features the binary supports, from its capabilities subcommand, so
+// callers can degrade gracefully instead of failing on a missing subcommand. The subcommand prints
+// whitespace-separated tokens: subcommand names, "algo=<name>" per supported algorithm, "hmac",
+// "interval" and "json". Binaries without it are assumed to support only the original subcommands and
+// SHA-256, with Reported false. The answer is queried once and reused for the life of the helper.
+func (k *KeyRotationHelper) Capabilities(ctx context.Context) (BinaryCapabilities, error) {
+	c := &k.capabilities
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.caps != nil {
+		return c.caps.clone(), nil
+	}
+
+	result, err := k.runKeyless(ctx, "capabilities")
+	var caps BinaryCapabilities
+	switch {
+	case isUnknownCommand(err):
+		caps = legacyCapabilities.clone()
+	case err != nil:
+		return BinaryCapabilities{}, fmt.Errorf("failed to query binary capabilities: %w", err)
+	default:
+		caps = parseCapabilities(result)
+	}
+	c.caps = &caps
+	return caps.clone(), nil
+}
+
+// parseCapabilities parses the output of the capabilities subcommand
+func parseCapabilities(out string) BinaryCapabilities {
+	caps := BinaryCapabilities{Reported: true}
+	for _, token := range strings.Fields(out) {
+		switch {
+		case strings.HasPrefix(token, "algo="):
+			caps.Algorithms = append(caps.Algorithms, Algorithm(strings.TrimPrefix(token, "algo=")))
+		case token == "hmac":
+			caps.HMAC = true
+		case token == "interval":
+			caps.SubDaily = true
+		case token == "json":
+			caps.JSON = true
+		default:
+			caps.Commands = append(caps.Commands, token)
+		}
+	}
+	return caps
+}
+
+// clone returns a copy of c that shares no slices with it
+func (c BinaryCapabilities) clone() BinaryCapabilities {
+	c.Commands = slices.Clone(c.Commands)
+	c.Algorithms = slices.Clone(c.Algorithms)
+	return c
+}
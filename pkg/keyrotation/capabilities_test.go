@@ -0,0 +1,56 @@
+package keyrotation
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestCapabilities(t *testing.T) {
+	recorder := &eventRecorder{}
+	helper := newFakeHelper(t, WithLogger(recorder))
+	for range 2 {
+		caps, err := helper.Capabilities(context.Background())
+		if err != nil {
+			t.Fatalf("Capabilities failed: %v", err)
+		}
+		if !caps.Reported || !caps.HMAC || !caps.SubDaily {
+			t.Errorf("Expected reported HMAC and sub-daily support, got %+v", caps)
+		}
+		if !caps.Supports("validate-tolerance-date") || !caps.Supports("serve") || caps.Supports("hmac") {
+			t.Errorf("Unexpected commands %v", caps.Commands)
+		}
+		if !slices.Equal(caps.Algorithms, []Algorithm{AlgorithmSHA256, AlgorithmSHA512}) {
+			t.Errorf("Unexpected algorithms %v", caps.Algorithms)
+		}
+		// Callers must not be able to corrupt the cached answer
+		caps.Commands[0] = "tampered"
+	}
+	if got := len(recorder.recorded()); got != 1 {
+		t.Errorf("Expected the capabilities to be queried once, got %d invocations", got)
+	}
+}
+
+func TestCapabilities_Legacy(t *testing.T) {
+	t.Setenv(fakeModeEnv, "legacy")
+	caps, err := newFakeHelper(t).Capabilities(context.Background())
+	if err != nil {
+		t.Fatalf("Expected legacy binaries to report the baseline, got %v", err)
+	}
+	if caps.Reported || caps.HMAC || caps.SubDaily {
+		t.Errorf("Expected an unreported baseline, got %+v", caps)
+	}
+	if !caps.Supports("validate-tolerance") || caps.Supports("validate-tolerance-date") {
+		t.Errorf("Unexpected commands %v", caps.Commands)
+	}
+}
+
+func TestCapabilities_InMemory(t *testing.T) {
+	caps, err := NewInMemory().Capabilities(context.Background())
+	if err != nil {
+		t.Fatalf("Capabilities failed: %v", err)
+	}
+	if !caps.Reported || !caps.Supports("validate-tolerance-date") || caps.Supports("serve") {
+		t.Errorf("Unexpected capabilities %+v", caps)
+	}
+}
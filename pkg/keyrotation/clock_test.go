@@ -0,0 +1,83 @@
+package keyrotation
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeClock is a settable clock for WithClock
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *fakeClock) set(t time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.t = t
+}
+
+func TestWithClock_PinsToday(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2025, 3, 10, 23, 59, 59, 0, time.UTC)}
+	for name, helper := range map[string]*KeyRotationHelper{
+		"exec":      newFakeHelper(t, WithClock(clock.now), WithValidationCache(8)),
+		"in-memory": NewInMemory(WithClock(clock.now)),
+	} {
+		clock.set(time.Date(2025, 3, 10, 23, 59, 59, 0, time.UTC))
+		want := fakeHash("testApiKey123", clock.now())
+
+		encrypted, err := helper.EncryptApiKey("testApiKey123")
+		if err != nil || encrypted != want {
+			t.Errorf("%s: Expected the clock's date %s, got %s, %v", name, want, encrypted, err)
+		}
+		if valid, err := helper.ValidateApiKeyToday("testApiKey123", want); err != nil || !valid {
+			t.Errorf("%s: Expected key to validate on the clock's date, got %v, %v", name, valid, err)
+		}
+
+		clock.set(clock.now().Add(2 * time.Second))
+		if valid, err := helper.ValidateApiKeyToday("testApiKey123", want); err != nil || valid {
+			t.Errorf("%s: Expected key to be rejected after midnight, got %v, %v", name, valid, err)
+		}
+		if valid, err := helper.ValidateApiKeyTodayWithTolerance("testApiKey123", want, 5); err != nil || !valid {
+			t.Errorf("%s: Expected tolerance to reach back across midnight, got %v, %v", name, valid, err)
+		}
+		if valid, err := helper.ValidateApiKeyWithinDays("testApiKey123", want, 1); err != nil || !valid {
+			t.Errorf("%s: Expected yesterday's key within a 1-day window, got %v, %v", name, valid, err)
+		}
+	}
+}
+
+func TestWithClock_UsesExplicitDateSubcommands(t *testing.T) {
+	recorder := &eventRecorder{}
+	clock := func() time.Time { return time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC) }
+	helper := newFakeHelper(t, WithClock(clock), WithLogger(recorder))
+	// Query the capabilities before the fake starts echoing its argv
+	if _, err := helper.Capabilities(context.Background()); err != nil {
+		t.Fatalf("Capabilities failed: %v", err)
+	}
+	t.Setenv(fakeModeEnv, "argv")
+
+	out, err := helper.EncryptApiKey("testApiKey123")
+	if err != nil || out != "--stdin encrypt-date 2025-03-10" {
+		t.Errorf("Expected encrypt-date for the clock's date, got %q, %v", out, err)
+	}
+	helper.ValidateApiKeyToday("testApiKey123", wrongCiphertext)
+	helper.ValidateApiKeyTodayWithTolerance("testApiKey123", wrongCiphertext, 5)
+
+	var operations []string
+	for _, e := range recorder.recorded() {
+		operations = append(operations, e.Operation)
+	}
+	if got := strings.Join(operations, " "); got != "capabilities encrypt-date validate-date validate-tolerance-date" {
+		t.Errorf("Expected only explicit-date subcommands, got %s", got)
+	}
+}
@@ -0,0 +1,76 @@
+package keyrotation
+
+// Clone returns a copy of the helper with opts applied on top of its configuration, for
+// request-scoped variations such as a shorter timeout or another namespace. The original is left
+// untouched, so Clone is safe to call concurrently with calls on it.
+//
+// The copy shares no mutable state with the original: it starts with an empty validation cache of
+// the same size, no registered keys or loaded ciphertexts, and its own Capabilities and
+// WithRequiredBinaryVersion checks. Pass WithValidationCache to size its cache differently. An
+// embedded binary is the one exception: the clone runs the copy the original extracted, which is
+// removed only once both are closed, so a clone of a WithEmbeddedBinary helper must be closed too.
+// A clone of a helper created with NewPersistent runs the binary once per call, since the
+// persistent process belongs to the original and is configured for it. Closing either helper does
+// not affect the other. An invalid option is returned from every call on the clone, as with New.
+func (k *KeyRotationHelper) Clone(opts ...Option) *KeyRotationHelper {
+	c := &KeyRotationHelper{
+		binaryPath:          k.binaryPath,
+		timeout:             k.timeout,
+		legacyArgv:          k.legacyArgv,
+		algorithm:           k.algorithm,
+		maxKeyLen:           k.maxKeyLen,
+		extraArgs:           k.extraArgs,
+		wrapper:             k.wrapper,
+		workDir:             k.workDir,
+		env:                 k.env,
+		dryRun:              k.dryRun,
+		unredacted:          k.unredacted,
+		location:            k.location,
+		clock:               k.clock,
+		logger:              k.logger,
+		metrics:             k.metrics,
+		tracer:              k.tracer,
+		resourceUsage:       k.resourceUsage,
+		stdoutTee:           k.stdoutTee,
+		stderrTee:           k.stderrTee,
+		namespace:           k.namespace,
+		preHashSalt:         k.preHashSalt,
+		pepper:              k.pepper,
+		earliestDate:        k.earliestDate,
+		maxFutureDays:       k.maxFutureDays,
+		interval:            k.interval,
+		binaryDateLayout:    k.binaryDateLayout,
+		retryAttempts:       k.retryAttempts,
+		retryBackoff:        k.retryBackoff,
+		retryJitter:         k.retryJitter,
+		windowConcurrency:   k.windowConcurrency,
+		encoding:            k.encoding,
+		skipCiphertextCheck: k.skipCiphertextCheck,
+		trueToken:           k.trueToken,
+		falseToken:          k.falseToken,
+		runner:              k.runner,
+		requiredVersion:     k.requiredVersion,
+		configErr:           k.configErr,
+	}
+	if k.cache != nil {
+		c.cache = newValidationCache(k.cache.size)
+	}
+	c.embedded = k.embedded
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.embedded != nil && c.embedded == k.embedded {
+		c.embedded.acquire()
+	}
+	c.checkOptions()
+
+	switch k.engine.(type) {
+	case inMemoryEngine:
+		// Options may have changed the hash, so the engine is rebuilt from the clone's settings
+		c.engine = newInMemoryEngine(c)
+		c.trueToken, c.falseToken = "true", "false"
+	default:
+		c.engine = execEngine{c}
+	}
+	return c
+}
@@ -0,0 +1,64 @@
+package keyrotation
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestClone_AppliesOptionsOnTop(t *testing.T) {
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	recorder := &eventRecorder{}
+	base := newFakeHelper(t, WithLogger(recorder), WithValidationCache(8))
+	clone := base.Clone(WithNamespace("tenant-a"), WithTimeout(time.Minute))
+
+	encrypted, err := clone.EncryptApiKeyWithDate("secret-key", date)
+	if err != nil {
+		t.Fatalf("EncryptApiKeyWithDate failed: %v", err)
+	}
+	if encrypted != fakeHash("8:tenant-a:secret-key", date) {
+		t.Errorf("Expected the clone to use its namespace, got %s", encrypted)
+	}
+	if got, err := base.EncryptApiKeyWithDate("secret-key", date); err != nil || got != fakeHash("secret-key", date) {
+		t.Errorf("Expected the original to keep no namespace, got %s, %v", got, err)
+	}
+	if base.timeout != DefaultTimeout || clone.timeout != time.Minute {
+		t.Errorf("Expected timeouts %s and %s, got %s and %s", DefaultTimeout, time.Minute, base.timeout, clone.timeout)
+	}
+	if got := len(recorder.recorded()); got != 2 {
+		t.Errorf("Expected the clone to keep the original's logger, got %d events", got)
+	}
+
+	if clone.cache == nil || clone.cache == base.cache {
+		t.Error("Expected the clone to get a cache of its own")
+	}
+	base.Close()
+	if _, err := clone.EncryptApiKeyWithDate("secret-key", date); err != nil {
+		t.Errorf("Expected the clone to outlive the original, got %v", err)
+	}
+
+	if _, err := base.Clone(WithNamespace("bad\nnamespace")).EncryptApiKey("secret-key"); err == nil {
+		t.Error("Expected an invalid option to fail calls on the clone")
+	}
+}
+
+func TestClone_Engines(t *testing.T) {
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	want, err := NewInMemory(WithAlgorithm(AlgorithmSHA512)).EncryptApiKeyWithDate("secret-key", date)
+	if err != nil {
+		t.Fatalf("EncryptApiKeyWithDate failed: %v", err)
+	}
+	if got, err := NewInMemory().Clone(WithAlgorithm(AlgorithmSHA512)).EncryptApiKeyWithDate("secret-key", date); err != nil || got != want {
+		t.Errorf("Expected the in-memory clone to hash with its own algorithm, got %s, %v", got, err)
+	}
+
+	persistent := newPersistentFakeHelper(t)
+	clone := persistent.Clone()
+	persistent.Close()
+	if _, err := persistent.EncryptApiKey("secret-key"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed from the closed original, got %v", err)
+	}
+	if got, err := clone.EncryptApiKeyWithDate("secret-key", date); err != nil || got != fakeHash("secret-key", date) {
+		t.Errorf("Expected the clone to run the binary per call, got %s, %v", got, err)
+	}
+}
@@ -0,0 +1,37 @@
+package keyrotation
+
+import "crypto/subtle"
+
+// SecureCompare reports whether two ciphertexts are equal in time that depends only on their
+// lengths, not on where they first differ. Use it instead of == when checking a client-supplied
+// ciphertext against a stored one: an early-exit comparison lets an attacker who can time the
+// responses recover the expected value a byte at a time.
+func SecureCompare(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// CiphertextEqual reports whether two ciphertexts hold the same digest, comparing in constant time
+// like SecureCompare. Hex (in either case) and base64 (in either alphabet) renderings of a SHA-256
+// or SHA-512 digest are decoded first, so the same digest stored by binary versions with different
+// encodings compares equal; other values are compared as written.
+//
+// Equal ciphertexts were issued for the same key and rotation date, so this suits reconciling
+// records across services that hold no plaintext. It proves nothing about which key or date they
+// belong to: to authenticate a presented key, validate it with the plaintext instead.
+func CiphertextEqual(a, b string) bool {
+	da, okA := decodeAnyCiphertext(a)
+	db, okB := decodeAnyCiphertext(b)
+	if !okA || !okB {
+		return SecureCompare(a, b)
+	}
+	return subtle.ConstantTimeCompare(da, db) == 1
+}
+
+// decodeAnyCiphertext decodes a SHA-256 or SHA-512 digest in any of the Encoding constants,
+// telling the encodings apart as decodeCiphertext does
+func decodeAnyCiphertext(s string) ([]byte, bool) {
+	if b, ok := decodeDigest(s, 32); ok {
+		return b, true
+	}
+	return decodeDigest(s, 64)
+}
@@ -0,0 +1,51 @@
+package keyrotation
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSecureCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"abc123", "abc123", true},
+		{"abc123", "abc124", false},
+		{"abc123", "abc12", false},
+		{"", "", true},
+		{"", "a", false},
+	}
+	for _, tt := range tests {
+		if got := SecureCompare(tt.a, tt.b); got != tt.want {
+			t.Errorf("SecureCompare(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestCiphertextEqual(t *testing.T) {
+	digest, _ := hex.DecodeString(fakeHash("testApiKey123", time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)))
+	hexForm := hex.EncodeToString(digest)
+	base64Form := base64.StdEncoding.EncodeToString(digest)
+
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{hexForm, hexForm, true},
+		{hexForm, strings.ToUpper(hexForm), true},
+		{hexForm, base64Form, true},
+		{base64Form, wrongCiphertext, false},
+		{hexForm, hexForm[:62], false},
+		{"opaque", "opaque", true},
+		{"opaque", "Opaque", false},
+	}
+	for _, tt := range tests {
+		if got := CiphertextEqual(tt.a, tt.b); got != tt.want {
+			t.Errorf("CiphertextEqual(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
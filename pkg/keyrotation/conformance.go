@@ -0,0 +1,68 @@
+package keyrotation
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ConformanceSample is a plaintext API key and date together with the ciphertext a reference
+// implementation produced for them
+type ConformanceSample struct {
+	ApiKey   string
+	Date     time.Time
+	Expected string
+}
+
+// ConformanceResult is the outcome of checking one ConformanceSample
+type ConformanceResult struct {
+	Sample ConformanceSample
+	// Actual is the ciphertext the helper produced for the sample's key and date
+	Actual string
+	// Match reports whether Actual and Sample.Expected are the same digest, in any encoding
+	Match bool
+}
+
+// ConformanceReport lists the outcome of every sample checked by ConformanceCheck, in input order
+type ConformanceReport struct {
+	Results []ConformanceResult
+}
+
+// Diverged returns the results whose ciphertext did not match the reference
+func (r ConformanceReport) Diverged() []ConformanceResult {
+	var diverged []ConformanceResult
+	for _, result := range r.Results {
+		if !result.Match {
+			diverged = append(diverged, result)
+		}
+	}
+	return diverged
+}
+
+// OK reports whether every checked sample matched
+func (r ConformanceReport) OK() bool {
+	return len(r.Diverged()) == 0
+}
+
+// ConformanceCheck encrypts the key of every sample for its date with the helper's engine (the
+// binary, or the pure-Go path of NewInMemory) and compares the result with the sample's expected
+// ciphertext using CiphertextEqual, so a reference that encodes digests differently still
+// matches. It is intended for certifying parity with another implementation in CI. Samples are
+// not subject to WithEarliestDate or WithMaxFutureDays. If an encryption fails, the report holds
+// the samples checked so far.
+func (k *KeyRotationHelper) ConformanceCheck(ctx context.Context, samples []ConformanceSample) (ConformanceReport, error) {
+	report := ConformanceReport{Results: make([]ConformanceResult, 0, len(samples))}
+	for i, sample := range samples {
+		actual, err := k.encryptWithDate(ctx, sample.ApiKey, sample.Date)
+		if err != nil {
+			return report, fmt.Errorf("failed to check conformance sample %d: %w", i, err)
+		}
+		report.Results = append(report.Results, ConformanceResult{
+			Sample: sample,
+			Actual: actual,
+			Match:  CiphertextEqual(actual, sample.Expected),
+		})
+	}
+
+	return report, nil
+}
@@ -0,0 +1,61 @@
+package keyrotation
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+)
+
+// conformanceSamples returns a matching hex sample, a matching base64 sample and a divergent one
+func conformanceSamples(t *testing.T) []ConformanceSample {
+	t.Helper()
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	digest, err := hex.DecodeString(fakeHash("serviceKey", date))
+	if err != nil {
+		t.Fatalf("Failed to decode fake hash: %v", err)
+	}
+	return []ConformanceSample{
+		{ApiKey: "testApiKey123", Date: date, Expected: fakeHash("testApiKey123", date)},
+		{ApiKey: "serviceKey", Date: date, Expected: base64.StdEncoding.EncodeToString(digest)},
+		{ApiKey: "testApiKey123", Date: date.AddDate(0, 0, 1), Expected: fakeHash("testApiKey123", date)},
+	}
+}
+
+func TestKeyRotationHelper_ConformanceCheck(t *testing.T) {
+	helpers := map[string]*KeyRotationHelper{
+		"binary":   newFakeHelper(t),
+		"inMemory": NewInMemory(),
+	}
+	for name, helper := range helpers {
+		t.Run(name, func(t *testing.T) {
+			report, err := helper.ConformanceCheck(context.Background(), conformanceSamples(t))
+			if err != nil {
+				t.Fatalf("ConformanceCheck failed: %v", err)
+			}
+			if len(report.Results) != 3 {
+				t.Fatalf("Expected 3 results, got %d", len(report.Results))
+			}
+			if !report.Results[0].Match || !report.Results[1].Match {
+				t.Errorf("Expected the reference ciphertexts to match, got %+v", report.Results[:2])
+			}
+			if report.OK() || len(report.Diverged()) != 1 || report.Diverged()[0].Sample != report.Results[2].Sample {
+				t.Errorf("Expected only the sample for the wrong date to diverge, got %+v", report.Diverged())
+			}
+		})
+	}
+}
+
+func TestKeyRotationHelper_ConformanceCheckBinaryFailure(t *testing.T) {
+	helper := NewWithBinaryPath("/nonexistent")
+
+	report, err := helper.ConformanceCheck(context.Background(), conformanceSamples(t))
+	if !errors.Is(err, ErrBinaryNotFound) {
+		t.Errorf("Expected ErrBinaryNotFound, got %v", err)
+	}
+	if len(report.Results) != 0 {
+		t.Errorf("Expected no results, got %+v", report.Results)
+	}
+}
@@ -0,0 +1,67 @@
+package keyrotation
+
+import (
+	"os"
+	"sync"
+	"sync/atomic"
+)
+
+// defaultHelper is the helper set with SetDefault, or nil
+var defaultHelper atomic.Pointer[KeyRotationHelper]
+
+// fallback is the helper Default returns when none is set, together with the BinaryEnvVar value
+// it was built for
+var fallback struct {
+	mu      sync.Mutex
+	envPath string
+	helper  *KeyRotationHelper
+}
+
+// SetDefault makes helper the one the package-level functions, such as EncryptApiKey, call, so a
+// binary path, logger or timeout configured once at startup applies to them everywhere. It is
+// safe to call concurrently with those functions, which use the helper set at the time they are
+// called. A nil helper restores the default helper configured as New would configure it. The
+// package does not close a helper it is given, or one it replaces.
+func SetDefault(helper *KeyRotationHelper) {
+	defaultHelper.Store(helper)
+}
+
+// Default returns the helper the package-level functions call: the one set with SetDefault, or
+// else a shared helper configured as New would configure it. The shared helper looks the binary
+// up on PATH only once, and is replaced when BinaryEnvVar changes.
+func Default() *KeyRotationHelper {
+	if helper := defaultHelper.Load(); helper != nil {
+		return helper
+	}
+
+	envPath := os.Getenv(BinaryEnvVar)
+	fallback.mu.Lock()
+	defer fallback.mu.Unlock()
+	if fallback.helper == nil || fallback.envPath != envPath {
+		fallback.helper = New()
+		fallback.helper.resolved = &resolvedPath{}
+		fallback.envPath = envPath
+	}
+	return fallback.helper
+}
+
+// resolvedPath memoizes the result of resolving a configured binary path
+type resolvedPath struct {
+	mu         sync.Mutex
+	binaryPath string
+	path       string
+}
+
+// get returns the path binaryPath was last resolved to, if any
+func (r *resolvedPath) get(binaryPath string) (string, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.path, r.path != "" && r.binaryPath == binaryPath
+}
+
+// set records that binaryPath resolved to path
+func (r *resolvedPath) set(binaryPath, path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.binaryPath, r.path = binaryPath, path
+}
@@ -0,0 +1,97 @@
+package keyrotation
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSetDefault(t *testing.T) {
+	t.Cleanup(func() { SetDefault(nil) })
+	recorder := &eventRecorder{}
+	helper := newFakeHelper(t, WithLogger(recorder))
+	SetDefault(helper)
+
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	encrypted, err := EncryptApiKeyWithDate("testApiKey123", date)
+	if err != nil || encrypted != fakeHash("testApiKey123", date) {
+		t.Fatalf("Expected the package-level function to use the default helper, got %s, %v", encrypted, err)
+	}
+	if valid, err := ValidateApiKey("testApiKey123", encrypted, date); err != nil || !valid {
+		t.Errorf("Expected key to validate, got %v, %v", valid, err)
+	}
+	if got := len(recorder.recorded()); got != 2 {
+		t.Errorf("Expected the default helper's logger to see 2 invocations, got %d", got)
+	}
+
+	SetDefault(nil)
+	if Default() == helper {
+		t.Error("Expected the default helper to be replaced once cleared")
+	}
+}
+
+func TestDefault_ReusesFallback(t *testing.T) {
+	t.Setenv(BinaryEnvVar, "/first/keyrotation-binary")
+	first := Default()
+	if Default() != first {
+		t.Error("Expected the fallback helper to be reused")
+	}
+
+	t.Setenv(BinaryEnvVar, "/second/keyrotation-binary")
+	second := Default()
+	if second == first || second.binaryPath != "/second/keyrotation-binary" {
+		t.Errorf("Expected a new fallback helper for the changed path, got %s", second.binaryPath)
+	}
+}
+
+func TestDefault_MemoizesPathLookup(t *testing.T) {
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("Failed to locate test executable: %v", err)
+	}
+	dir := t.TempDir()
+	link := filepath.Join(dir, "keyrotation-fake")
+	if err := os.Symlink(exe, link); err != nil {
+		t.Skipf("Symlinks are not available: %v", err)
+	}
+	t.Setenv("PATH", dir)
+	t.Setenv(BinaryEnvVar, "keyrotation-fake")
+
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	if _, err := EncryptApiKeyWithDate("testApiKey123", date); err != nil {
+		t.Fatalf("EncryptApiKeyWithDate failed: %v", err)
+	}
+	// Moving the binary off PATH leaves the remembered path in place
+	if err := os.Rename(link, filepath.Join(t.TempDir(), "moved")); err != nil {
+		t.Fatalf("Failed to move the binary: %v", err)
+	}
+	if path, err := Default().resolveBinary(); err != nil || path != link {
+		t.Errorf("Expected the memoized path %s, got %s, %v", link, path, err)
+	}
+	if _, err := New().resolveBinary(); err == nil {
+		t.Error("Expected a helper from New to look the binary up again")
+	}
+}
+
+func TestSetDefault_Concurrent(t *testing.T) {
+	t.Cleanup(func() { SetDefault(nil) })
+	helpers := []*KeyRotationHelper{NewInMemory(), NewInMemory(WithNamespace("other"))}
+
+	var wg sync.WaitGroup
+	for i := range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 50 {
+				SetDefault(helpers[i%len(helpers)])
+				if _, err := EncryptApiKey("testApiKey123"); err != nil {
+					t.Errorf("EncryptApiKey failed: %v", err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,33 @@
+package keyrotation
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DeriveDailyKey returns the per-day value the binary derives from the rotation date of
+// utcDateTime, in the helper's location, before combining it with an API key. With the current
+// formula this is the yyyyMMdd date string itself. It invokes the binary's derive-key subcommand,
+// so comparing its output with another SDK's shows whether they agree on the date half of the
+// scheme when their ciphertexts differ. Binaries without the subcommand yield an error wrapping
+// ErrUnsupported.
+func (k *KeyRotationHelper) DeriveDailyKey(utcDateTime time.Time) (string, error) {
+	return k.DeriveDailyKeyContext(context.Background(), utcDateTime)
+}
+
+// DeriveDailyKeyContext is like DeriveDailyKey but kills the binary if ctx is done first
+func (k *KeyRotationHelper) DeriveDailyKeyContext(ctx context.Context, utcDateTime time.Time) (string, error) {
+	result, err := k.runKeyless(ctx, "derive-key", k.binaryDate(utcDateTime))
+	if isUnknownCommand(err) {
+		err = fmt.Errorf("%w: derive-key: %w", ErrUnsupported, err)
+	}
+	if err == nil && result == "" {
+		err = fmt.Errorf("%w: empty derived key", ErrUnexpectedOutput)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to derive daily key: %w", err)
+	}
+
+	return result, nil
+}
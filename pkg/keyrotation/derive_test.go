@@ -0,0 +1,34 @@
+package keyrotation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestKeyRotationHelper_DeriveDailyKey(t *testing.T) {
+	date := time.Date(2025, 3, 10, 15, 4, 5, 0, time.UTC)
+	for name, helper := range map[string]*KeyRotationHelper{"fake": newFakeHelper(t), "in-memory": NewInMemory()} {
+		derived, err := helper.DeriveDailyKey(date)
+		if err != nil || derived != "20250310" {
+			t.Errorf("%s: Expected 20250310, got %q, %v", name, derived, err)
+		}
+
+		// The derived value and the API key reproduce the ciphertext
+		encrypted, err := helper.EncryptApiKeyWithDate("testApiKey123", date)
+		if err != nil {
+			t.Fatalf("%s: EncryptApiKeyWithDate failed: %v", name, err)
+		}
+		sum := sha256.Sum256([]byte(derived + "testApiKey123"))
+		if got := hex.EncodeToString(sum[:]); got != encrypted {
+			t.Errorf("%s: Expected derived key to reproduce %s, got %s", name, encrypted, got)
+		}
+	}
+
+	t.Setenv(fakeModeEnv, "legacy")
+	if _, err := newFakeHelper(t).DeriveDailyKey(date); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported from a binary without derive-key, got %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package keyrotation
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// EncryptedKey is an encrypted API key along with the metadata needed to validate it later
+type EncryptedKey struct {
+	// Value is the ciphertext, as returned by EncryptApiKey
+	Value string
+	// Date is the rotation date the ciphertext was computed for, as midnight in the helper's location
+	// or, with a sub-daily WithRotationInterval, the start of the interval
+	Date time.Time
+	// Algorithm is the hash the ciphertext was computed with
+	Algorithm Algorithm
+	// ComputedAt is the UTC instant the ciphertext was computed
+	ComputedAt time.Time
+}
+
+// EncryptApiKeyDetailed is like EncryptApiKey but also reports the date and algorithm the key was
+// encrypted for, so they can be stored alongside it. The key can later be checked with
+// ValidateApiKey(apiKey, encrypted.Value, encrypted.Date).
+func (k *KeyRotationHelper) EncryptApiKeyDetailed(apiKey string) (EncryptedKey, error) {
+	return k.EncryptApiKeyDetailedContext(context.Background(), apiKey)
+}
+
+// EncryptApiKeyDetailedContext is like EncryptApiKeyDetailed but kills the binary if ctx is done first
+func (k *KeyRotationHelper) EncryptApiKeyDetailedContext(ctx context.Context, apiKey string) (EncryptedKey, error) {
+	// Encrypting for an explicit date keeps Date accurate even if the call straddles midnight
+	now := k.now()
+	value, err := k.EncryptApiKeyWithDateContext(ctx, apiKey, now)
+	if err != nil {
+		return EncryptedKey{}, err
+	}
+	return EncryptedKey{
+		Value:      value,
+		Date:       k.startOfInterval(now),
+		Algorithm:  k.Algorithm(),
+		ComputedAt: now.UTC(),
+	}, nil
+}
+
+// ValidationResult is the outcome of a validation along with the rotation date it matched, for audit trails
+type ValidationResult struct {
+	// Valid reports whether the key matched
+	Valid bool
+	// MatchedDate is the rotation date the key matched, as midnight in the helper's location or,
+	// with a sub-daily WithRotationInterval, the start of the interval. It is the zero time when
+	// Valid is false.
+	MatchedDate time.Time
+}
+
+// ValidateApiKeyTodayWithToleranceDetailed is like ValidateApiKeyTodayWithTolerance but also reports
+// which rotation date the key matched. Every rotation date within toleranceMinutes either side of
+// now is checked separately, current date first, so the call costs one binary invocation per
+// date. Tolerances over a week fail with ErrUnsupported, as when the check is emulated.
+func (k *KeyRotationHelper) ValidateApiKeyTodayWithToleranceDetailed(apiKey, encryptedKey string, toleranceMinutes int) (ValidationResult, error) {
+	return k.ValidateApiKeyTodayWithToleranceDetailedContext(context.Background(), apiKey, encryptedKey, toleranceMinutes)
+}
+
+// ValidateApiKeyTodayWithToleranceDetailedContext is like ValidateApiKeyTodayWithToleranceDetailed
+// but stops checking once ctx is done
+func (k *KeyRotationHelper) ValidateApiKeyTodayWithToleranceDetailedContext(ctx context.Context, apiKey, encryptedKey string, toleranceMinutes int) (ValidationResult, error) {
+	if err := checkTolerance(toleranceMinutes); err != nil {
+		return ValidationResult{}, fmt.Errorf("failed to validate API key with tolerance: %w", err)
+	}
+
+	if toleranceMinutes > maxEmulatedToleranceMinutes {
+		return ValidationResult{}, fmt.Errorf("%w: tolerance of %d minutes exceeds the %d that can be checked date by date",
+			ErrUnsupported, toleranceMinutes, maxEmulatedToleranceMinutes)
+	}
+
+	dates := toleranceDates(k.now().In(k.location), toleranceMinutes, k.RotationInterval())
+	date, isValid, err := k.firstMatch(ctx, apiKey, encryptedKey, dates)
+	if err != nil {
+		return ValidationResult{}, fmt.Errorf("failed to validate API key with tolerance: %w", err)
+	}
+	return ValidationResult{Valid: isValid, MatchedDate: date}, nil
+}
+
+// EncryptApiKeyDetailed encrypts an API key with the current UTC date, reporting its rotation metadata
+func EncryptApiKeyDetailed(apiKey string) (EncryptedKey, error) {
+	helper := Default()
+	return helper.EncryptApiKeyDetailed(apiKey)
+}
+
+// ValidateApiKeyTodayWithToleranceDetailed validates an API key for today with time tolerance, reporting the date it matched
+func ValidateApiKeyTodayWithToleranceDetailed(apiKey, encryptedKey string, toleranceMinutes int) (ValidationResult, error) {
+	helper := Default()
+	return helper.ValidateApiKeyTodayWithToleranceDetailed(apiKey, encryptedKey, toleranceMinutes)
+}
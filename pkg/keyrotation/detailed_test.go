@@ -0,0 +1,75 @@
+package keyrotation
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestKeyRotationHelper_EncryptApiKeyDetailed(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("Time zone database unavailable: %v", err)
+	}
+	helper := newFakeHelper(t, WithLocation(tokyo), WithAlgorithm(AlgorithmSHA512))
+	testApiKey := "testApiKey123"
+
+	before := time.Now()
+	encrypted, err := helper.EncryptApiKeyDetailed(testApiKey)
+	if err != nil {
+		t.Fatalf("EncryptApiKeyDetailed failed: %v", err)
+	}
+
+	if encrypted.ComputedAt.Location() != time.UTC || encrypted.ComputedAt.Before(before.Truncate(time.Second)) || encrypted.ComputedAt.After(time.Now()) {
+		t.Errorf("Expected ComputedAt to be the UTC time of the call, got %v", encrypted.ComputedAt)
+	}
+	wantDate := helper.startOfDay(encrypted.ComputedAt)
+	if !encrypted.Date.Equal(wantDate) || encrypted.Date.Location() != tokyo {
+		t.Errorf("Expected Date %v, got %v", wantDate, encrypted.Date)
+	}
+	if encrypted.Algorithm != AlgorithmSHA512 {
+		t.Errorf("Expected algorithm %s, got %s", AlgorithmSHA512, encrypted.Algorithm)
+	}
+
+	isValid, err := helper.ValidateApiKey(testApiKey, encrypted.Value, encrypted.Date)
+	if err != nil {
+		t.Fatalf("ValidateApiKey failed: %v", err)
+	}
+	if !isValid {
+		t.Error("Expected detailed key to validate against its recorded date")
+	}
+}
+
+func TestKeyRotationHelper_ValidateApiKeyTodayWithToleranceDetailed(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2025, 3, 11, 0, 2, 0, 0, time.UTC)}
+	helper := newFakeHelper(t, WithClock(clock.now))
+	testApiKey := "testApiKey123"
+	today := time.Date(2025, 3, 11, 0, 0, 0, 0, time.UTC)
+	yesterday := today.AddDate(0, 0, -1)
+
+	for _, tc := range []struct {
+		name      string
+		encrypted string
+		wantValid bool
+		wantDate  time.Time
+	}{
+		{"today", fakeHash(testApiKey, today), true, today},
+		{"yesterday within tolerance", fakeHash(testApiKey, yesterday), true, yesterday},
+		{"out of tolerance", fakeHash(testApiKey, yesterday.AddDate(0, 0, -1)), false, time.Time{}},
+	} {
+		result, err := helper.ValidateApiKeyTodayWithToleranceDetailed(testApiKey, tc.encrypted, 5)
+		if err != nil {
+			t.Fatalf("%s: ValidateApiKeyTodayWithToleranceDetailed failed: %v", tc.name, err)
+		}
+		if result.Valid != tc.wantValid || !result.MatchedDate.Equal(tc.wantDate) {
+			t.Errorf("%s: Expected valid=%v date=%v, got %+v", tc.name, tc.wantValid, tc.wantDate, result)
+		}
+	}
+
+	if _, err := helper.ValidateApiKeyTodayWithToleranceDetailed(testApiKey, wrongCiphertext, -1); !errors.Is(err, ErrUnsafeArgument) {
+		t.Errorf("Expected a negative tolerance to fail with ErrUnsafeArgument, got %v", err)
+	}
+	if _, err := helper.ValidateApiKeyTodayWithToleranceDetailed(testApiKey, wrongCiphertext, maxEmulatedToleranceMinutes+1); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected an over-wide tolerance to fail with ErrUnsupported, got %v", err)
+	}
+}
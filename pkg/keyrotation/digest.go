@@ -0,0 +1,123 @@
+package keyrotation
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// Encoding names a text encoding of ciphertext digests
+type Encoding string
+
+const (
+	// EncodingHex is lowercase hexadecimal, which binaries emit by default
+	EncodingHex Encoding = "hex"
+	// EncodingHexUpper is uppercase hexadecimal
+	EncodingHexUpper Encoding = "hex-upper"
+	// EncodingBase64 is standard padded base64, which some binary versions emitted
+	EncodingBase64 Encoding = "base64"
+	// EncodingBase64URL is padded base64 with the URL-safe alphabet
+	EncodingBase64URL Encoding = "base64url"
+)
+
+// checkEncoding rejects encodings other than the Encoding constants
+func checkEncoding(encoding Encoding) error {
+	switch encoding {
+	case EncodingHex, EncodingHexUpper, EncodingBase64, EncodingBase64URL:
+		return nil
+	}
+	return fmt.Errorf("keyrotation: unsupported ciphertext encoding %q", encoding)
+}
+
+// encodeDigest encodes digest in encoding, with an empty encoding meaning EncodingHex
+func encodeDigest(digest []byte, encoding Encoding) string {
+	switch encoding {
+	case EncodingHexUpper:
+		return strings.ToUpper(hex.EncodeToString(digest))
+	case EncodingBase64:
+		return base64.StdEncoding.EncodeToString(digest)
+	case EncodingBase64URL:
+		return base64.URLEncoding.EncodeToString(digest)
+	}
+	return hex.EncodeToString(digest)
+}
+
+// decodeDigest decodes s as a size-byte digest in any of the encodings. Hex and base64 are told
+// apart by length, which for a digest of a given size always differs between them; the two base64
+// alphabets only differ in characters that a value in the other cannot contain.
+func decodeDigest(s string, size int) ([]byte, bool) {
+	switch len(s) {
+	case hex.EncodedLen(size):
+		b, err := hex.DecodeString(s)
+		return b, err == nil
+	case base64.StdEncoding.EncodedLen(size):
+		if b, err := base64.StdEncoding.DecodeString(s); err == nil {
+			return b, true
+		}
+		b, err := base64.URLEncoding.DecodeString(s)
+		return b, err == nil
+	}
+	return nil, false
+}
+
+// binaryCiphertext re-encodes a stored ciphertext in the encoding the binary emits, so values
+// stored by binary versions with another encoding still validate. Ciphertexts that cannot be
+// decoded are returned unchanged for the binary to reject.
+func (k *KeyRotationHelper) binaryCiphertext(encrypted string) string {
+	digest, err := k.decodeCiphertext(encrypted)
+	if err != nil {
+		return encrypted
+	}
+	return encodeDigest(digest, k.encoding)
+}
+
+// digestSize returns the length in bytes of the digests the helper's algorithm produces
+func (k *KeyRotationHelper) digestSize() int {
+	if k.Algorithm() == AlgorithmSHA512 {
+		return 64
+	}
+	return 32
+}
+
+// decodeCiphertext decodes a ciphertext into its digest bytes. Binaries emit hex, but some
+// versions emitted standard base64; values in any of the Encoding constants are accepted, and
+// the detection is unambiguous.
+func (k *KeyRotationHelper) decodeCiphertext(s string) ([]byte, error) {
+	size := k.digestSize()
+	if b, ok := decodeDigest(s, size); ok {
+		return b, nil
+	}
+	return nil, fmt.Errorf("%w: %d characters that are neither hex nor base64 of a %d-byte %s digest", ErrMalformedCiphertext, len(s), size, k.Algorithm())
+}
+
+// checkCiphertext rejects encrypted with ErrMalformedCiphertext unless it is a well-formed digest
+// or the check was disabled with WithCiphertextCheck
+func (k *KeyRotationHelper) checkCiphertext(encrypted string) error {
+	if k.skipCiphertextCheck {
+		return nil
+	}
+	_, err := k.decodeCiphertext(encrypted)
+	return err
+}
+
+// EncryptApiKeyBytes is like EncryptApiKey but returns the raw digest bytes, for storage in binary
+// columns. The binary's hex output is decoded, as is the base64 some versions emitted; any other
+// output fails with an error wrapping ErrUnexpectedOutput.
+func (k *KeyRotationHelper) EncryptApiKeyBytes(apiKey string) ([]byte, error) {
+	return k.EncryptApiKeyBytesContext(context.Background(), apiKey)
+}
+
+// EncryptApiKeyBytesContext is like EncryptApiKeyBytes but kills the binary if ctx is done first
+func (k *KeyRotationHelper) EncryptApiKeyBytesContext(ctx context.Context, apiKey string) ([]byte, error) {
+	encrypted, err := k.EncryptApiKeyContext(ctx, apiKey)
+	if err != nil {
+		return nil, err
+	}
+	digest, err := k.decodeCiphertext(encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt API key: %w: %w", ErrUnexpectedOutput, err)
+	}
+	return digest, nil
+}
@@ -0,0 +1,146 @@
+package keyrotation
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestKeyRotationHelper_EncryptApiKeyBytes(t *testing.T) {
+	for _, algorithm := range []Algorithm{AlgorithmSHA256, AlgorithmSHA512} {
+		helper := newFakeHelper(t, WithAlgorithm(algorithm))
+		encrypted, err := helper.EncryptApiKey("testApiKey123")
+		if err != nil {
+			t.Fatalf("%s: EncryptApiKey failed: %v", algorithm, err)
+		}
+
+		digest, err := helper.EncryptApiKeyBytes("testApiKey123")
+		if err != nil {
+			t.Fatalf("%s: EncryptApiKeyBytes failed: %v", algorithm, err)
+		}
+		if hex.EncodeToString(digest) != encrypted {
+			t.Errorf("%s: Expected bytes of %s, got %x", algorithm, encrypted, digest)
+		}
+	}
+}
+
+func TestKeyRotationHelper_DecodeCiphertext(t *testing.T) {
+	helper := NewInMemory()
+	digest := make([]byte, 32)
+	for i := range digest {
+		digest[i] = byte(i * 7)
+	}
+
+	for _, encoded := range []string{hex.EncodeToString(digest), base64.StdEncoding.EncodeToString(digest)} {
+		got, err := helper.decodeCiphertext(encoded)
+		if err != nil || string(got) != string(digest) {
+			t.Errorf("decodeCiphertext(%q) = %x, %v", encoded, got, err)
+		}
+	}
+	for _, bad := range []string{"", "abc", hex.EncodeToString(digest[:31]), "zz" + hex.EncodeToString(digest)[2:]} {
+		if _, err := helper.decodeCiphertext(bad); !errors.Is(err, ErrMalformedCiphertext) {
+			t.Errorf("Expected ErrMalformedCiphertext decoding %q, got %v", bad, err)
+		}
+	}
+}
+
+func TestValidation_AcceptsEitherEncoding(t *testing.T) {
+	hexHelper := newFakeHelper(t)
+	hexStored, err := hexHelper.EncryptApiKey("testApiKey123")
+	if err != nil {
+		t.Fatalf("EncryptApiKey failed: %v", err)
+	}
+
+	t.Setenv(fakeModeEnv, "base64")
+	base64Helper := newFakeHelper(t, WithCiphertextEncoding(EncodingBase64))
+	base64Stored, err := base64Helper.EncryptApiKey("testApiKey123")
+	if err != nil {
+		t.Fatalf("EncryptApiKey failed: %v", err)
+	}
+	if base64Stored == hexStored {
+		t.Fatal("Expected the base64 binary to emit a different encoding")
+	}
+	for _, stored := range []string{hexStored, base64Stored} {
+		if valid, err := base64Helper.ValidateApiKeyToday("testApiKey123", stored); err != nil || !valid {
+			t.Errorf("Expected %s to validate against a base64 binary, got %v, %v", stored, valid, err)
+		}
+	}
+
+	t.Setenv(fakeModeEnv, "")
+	for _, stored := range []string{hexStored, base64Stored} {
+		if valid, err := hexHelper.ValidateApiKeyToday("testApiKey123", stored); err != nil || !valid {
+			t.Errorf("Expected %s to validate against a hex binary, got %v, %v", stored, valid, err)
+		}
+	}
+	results, err := hexHelper.ValidateApiKeyBatch([]KeyPair{{"testApiKey123", base64Stored}})
+	if err != nil || !results[0] {
+		t.Errorf("Expected base64 value to validate in a batch, got %v, %v", results, err)
+	}
+	if _, err := NewWithOptions(WithCiphertextEncoding("base32")); err == nil {
+		t.Error("Expected an unsupported encoding to be rejected")
+	}
+}
+
+func TestValidation_RejectsMalformedCiphertext(t *testing.T) {
+	recorder := &eventRecorder{}
+	helper := newFakeHelper(t, WithLogger(recorder))
+
+	for _, stored := range []string{"", "wrong", wrongCiphertext[1:], "zz" + wrongCiphertext[2:]} {
+		if _, err := helper.ValidateApiKeyToday("testApiKey123", stored); !errors.Is(err, ErrMalformedCiphertext) {
+			t.Errorf("%q: expected ErrMalformedCiphertext, got %v", stored, err)
+		}
+	}
+	_, err := helper.ValidateApiKeyBatch([]KeyPair{{"testApiKey123", wrongCiphertext}, {"testApiKey123", "wrong"}})
+	var batchErr *BatchError
+	if !errors.Is(err, ErrMalformedCiphertext) || !errors.As(err, &batchErr) || batchErr.Index != 1 {
+		t.Errorf("Expected ErrMalformedCiphertext for batch item 1, got %v", err)
+	}
+	if events := recorder.recorded(); len(events) != 0 {
+		t.Errorf("Expected malformed ciphertexts never to reach the binary, got %d invocations", len(events))
+	}
+
+	if valid, err := helper.ValidateApiKeyToday("testApiKey123", wrongCiphertext); err != nil || valid {
+		t.Errorf("Expected a well-formed wrong ciphertext to be a mismatch, got %v, %v", valid, err)
+	}
+	unchecked := newFakeHelper(t, WithCiphertextCheck(false))
+	if valid, err := unchecked.ValidateApiKeyToday("testApiKey123", "wrong"); err != nil || valid {
+		t.Errorf("Expected an unchecked malformed ciphertext to be a mismatch, got %v, %v", valid, err)
+	}
+}
+
+func TestValidation_Base64URLDigestStartingWithDash(t *testing.T) {
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	encoder := NewInMemory(WithDigestEncoding(EncodingBase64URL))
+	var apiKey, encrypted string
+	for i := 0; !strings.HasPrefix(encrypted, "-"); i++ {
+		apiKey = "dashKey" + strconv.Itoa(i)
+		var err error
+		if encrypted, err = encoder.EncryptApiKeyWithDate(apiKey, date); err != nil {
+			t.Fatalf("EncryptApiKeyWithDate failed: %v", err)
+		}
+	}
+
+	t.Setenv(fakeModeEnv, string(EncodingBase64URL))
+	clock := func() time.Time { return date.Add(12 * time.Hour) }
+	for name, helper := range map[string]*KeyRotationHelper{
+		"in-memory": NewInMemory(WithDigestEncoding(EncodingBase64URL), WithClock(clock)),
+		"exec":      newFakeHelper(t, WithCiphertextEncoding(EncodingBase64URL), WithClock(clock)),
+	} {
+		if valid, err := helper.ValidateApiKey(apiKey, encrypted, date); err != nil || !valid {
+			t.Errorf("%s: ValidateApiKey: expected %s to validate, got %v, %v", name, encrypted, valid, err)
+		}
+		if valid, err := helper.ValidateApiKeyToday(apiKey, encrypted); err != nil || !valid {
+			t.Errorf("%s: ValidateApiKeyToday: expected %s to validate, got %v, %v", name, encrypted, valid, err)
+		}
+		if valid, err := helper.ValidateApiKeyWithTolerance(apiKey, encrypted, date.Add(-5*time.Minute), 10); err != nil || !valid {
+			t.Errorf("%s: ValidateApiKeyWithTolerance: expected %s to validate, got %v, %v", name, encrypted, valid, err)
+		}
+		if valid, err := helper.ValidateApiKey("otherKey", encrypted, date); err != nil || valid {
+			t.Errorf("%s: expected another key to be rejected, got %v, %v", name, valid, err)
+		}
+	}
+}
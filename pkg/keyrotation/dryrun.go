@@ -0,0 +1,49 @@
+package keyrotation
+
+import (
+	"strings"
+)
+
+// dryRunCiphertext is the stubbed ciphertext returned by encryptions in dry-run mode
+var dryRunCiphertext = strings.Repeat("0", 64)
+
+// dryRunCall reports the invocation r would make to the logger without running it, and returns a
+// stubbed result: dryRunCiphertext for encryptions, false for validations, and "ok" for selftest.
+// The event's Command holds the full argv; the API key only appears in it in legacy argv mode,
+// since it is otherwise sent on stdin, and is redacted there unless WithRedaction(false) is set.
+func (k *KeyRotationHelper) dryRunCall(r request) (string, error) {
+	binaryPath, err := k.resolveBinary()
+	if err != nil {
+		binaryPath = k.binaryPath
+	}
+	argv, _ := k.commandArgs(r)
+	name, argv := wrapCommand(k.wrapper, binaryPath, argv)
+	k.logger.LogEvent(Event{
+		Operation: r.command,
+		Command:   append([]string{name}, k.redactArgs(argv, r)...),
+	})
+
+	result := k.dryRunResult(r.command)
+	if r.input == "" {
+		return result, nil
+	}
+	lines := strings.Count(r.input, "\n")
+	return strings.TrimSuffix(strings.Repeat(result+"\n", lines), "\n"), nil
+}
+
+// dryRunResult is the stubbed output of command
+func (k *KeyRotationHelper) dryRunResult(command string) string {
+	switch {
+	case strings.HasPrefix(command, "encrypt"):
+		return dryRunCiphertext
+	case strings.HasPrefix(command, "validate"):
+		return k.falseToken
+	case command == "selftest":
+		return "ok"
+	case command == "capabilities":
+		return inMemoryCapabilities
+	case command == "version", command == "derive-key":
+		return "dry-run"
+	}
+	return ""
+}
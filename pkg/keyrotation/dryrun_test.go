@@ -0,0 +1,62 @@
+package keyrotation
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWithDryRun(t *testing.T) {
+	recorder := &eventRecorder{}
+	missing := filepath.Join(t.TempDir(), "keyrotation-binary")
+	helper := NewWithBinaryPath(missing, WithDryRun(true), WithLogger(recorder))
+
+	encrypted, err := helper.EncryptApiKey("secret-key")
+	if err != nil {
+		t.Fatalf("EncryptApiKey failed: %v", err)
+	}
+	if encrypted != dryRunCiphertext {
+		t.Errorf("Expected stubbed ciphertext, got %s", encrypted)
+	}
+	isValid, err := helper.ValidateApiKeyToday("secret-key", encrypted)
+	if err != nil || isValid {
+		t.Errorf("Expected stubbed validation to be false, got %v, %v", isValid, err)
+	}
+	results, err := helper.EncryptApiKeyBatch([]string{"a", "b", "c"})
+	if err != nil || len(results) != 3 {
+		t.Errorf("Expected 3 stubbed batch results, got %q, %v", results, err)
+	}
+
+	events := recorder.recorded()
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d", len(events))
+	}
+	if got := strings.Join(events[0].Command, " "); got != missing+" --stdin encrypt" {
+		t.Errorf("Unexpected dry-run command %q", got)
+	}
+	for _, e := range events {
+		if strings.Contains(strings.Join(e.Command, " "), "secret-key") {
+			t.Errorf("Expected API key to stay off argv, got %q", e.Command)
+		}
+	}
+}
+
+func TestWithDryRun_LegacyArgvRedactsKey(t *testing.T) {
+	recorder := &eventRecorder{}
+	helper := NewWithBinaryPath("/opt/keyrotation-binary", WithDryRun(true), WithLegacyArgv(true), WithLogger(recorder))
+
+	helper.ValidateApiKeyToday("secret-key", wrongCiphertext)
+	if got := strings.Join(recorder.recorded()[0].Command, " "); got != "/opt/keyrotation-binary validate <redacted> "+wrongCiphertext {
+		t.Errorf("Unexpected dry-run command %q", got)
+	}
+}
+
+func TestWithDryRun_RedactionDisabledExposesKey(t *testing.T) {
+	recorder := &eventRecorder{}
+	helper := NewWithBinaryPath("/opt/keyrotation-binary", WithDryRun(true), WithLegacyArgv(true), WithRedaction(false), WithLogger(recorder))
+
+	helper.ValidateApiKeyToday("secret-key", wrongCiphertext)
+	if got := strings.Join(recorder.recorded()[0].Command, " "); got != "/opt/keyrotation-binary validate secret-key "+wrongCiphertext {
+		t.Errorf("Unexpected dry-run command %q", got)
+	}
+}
@@ -0,0 +1,88 @@
+package keyrotation
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync"
+)
+
+// embeddedBinary is a binary image set with WithEmbeddedBinary, extracted to a private temporary
+// directory the first time it is needed. It is shared by a helper and its clones, and removed once
+// the last of them is closed.
+type embeddedBinary struct {
+	data []byte
+
+	mu   sync.Mutex
+	refs int
+	dir  string
+	path string
+}
+
+// WithEmbeddedBinary runs the binary whose contents are data, typically a []byte filled by a
+// //go:embed directive in the calling program, so the service ships as a single artifact. The
+// image is written to a new temporary directory, readable and executable only by the current
+// user, before the first invocation. Clones of the helper share that copy, which is removed once
+// the helper and every clone are closed, so each clone must be closed too. It replaces
+// WithBinaryPath and is in turn replaced by a later WithBinaryPath. The image must be built for
+// the host platform.
+func WithEmbeddedBinary(data []byte) Option {
+	return func(k *KeyRotationHelper) {
+		if len(data) == 0 {
+			k.fail(errors.New("keyrotation: embedded binary must not be empty"))
+			return
+		}
+		k.embedded = &embeddedBinary{data: data, refs: 1}
+	}
+}
+
+// extract writes the image to its temporary directory unless that was already done, and returns
+// the path of the executable
+func (e *embeddedBinary) extract() (string, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.path != "" {
+		return e.path, nil
+	}
+
+	dir, err := os.MkdirTemp("", "keyrotation-")
+	if err != nil {
+		return "", fmt.Errorf("failed to extract embedded binary: %w", err)
+	}
+	name := defaultBinaryName
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, e.data, 0o700); err != nil {
+		os.RemoveAll(dir)
+		return "", fmt.Errorf("failed to extract embedded binary: %w", err)
+	}
+	e.dir, e.path = dir, path
+	return path, nil
+}
+
+// acquire records another helper sharing the image
+func (e *embeddedBinary) acquire() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.refs++
+}
+
+// release records that a helper sharing the image was closed and, once none is left, deletes the
+// extracted binary, if any, so a later extract writes it again
+func (e *embeddedBinary) release() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.refs--; e.refs > 0 || e.dir == "" {
+		return nil
+	}
+	err := os.RemoveAll(e.dir)
+	e.dir, e.path = "", ""
+	if err != nil {
+		return fmt.Errorf("failed to remove embedded binary: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,17 @@
+//go:build keyrotation_embed
+
+package keyrotation
+
+import _ "embed"
+
+// bundledImage is the keyrotation-binary file in this package's directory at build time. It is
+// only compiled in with the keyrotation_embed build tag, since the binary is platform-specific.
+//
+//go:embed keyrotation-binary
+var bundledImage []byte
+
+// WithBundledBinary runs the binary embedded into this package by building with
+// -tags keyrotation_embed, as WithEmbeddedBinary would
+func WithBundledBinary() Option {
+	return WithEmbeddedBinary(bundledImage)
+}
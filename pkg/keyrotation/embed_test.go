@@ -0,0 +1,117 @@
+package keyrotation
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+// fakeImage returns the contents of the fake binary, for embedding
+func fakeImage(t *testing.T) []byte {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("Failed to locate test executable: %v", err)
+	}
+	data, err := os.ReadFile(exe)
+	if err != nil {
+		t.Fatalf("Failed to read test executable: %v", err)
+	}
+	return data
+}
+
+func TestWithEmbeddedBinary(t *testing.T) {
+	helper, err := NewWithOptions(WithEmbeddedBinary(fakeImage(t)))
+	if err != nil {
+		t.Fatalf("NewWithOptions failed: %v", err)
+	}
+
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	if got, err := helper.EncryptApiKeyWithDate("testApiKey123", date); err != nil || got != fakeHash("testApiKey123", date) {
+		t.Fatalf("Expected the embedded binary to run, got %s, %v", got, err)
+	}
+	path, err := helper.resolveBinary()
+	if err != nil {
+		t.Fatalf("resolveBinary failed: %v", err)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Expected the extracted binary to exist: %v", err)
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm() != 0o700 {
+		t.Errorf("Expected mode 0700, got %v", info.Mode().Perm())
+	}
+
+	if err := helper.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Dir(path)); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected Close to remove the extracted binary, got %v", err)
+	}
+}
+
+func TestWithEmbeddedBinary_Empty(t *testing.T) {
+	if _, err := NewWithOptions(WithEmbeddedBinary(nil)); err == nil {
+		t.Error("Expected an empty embedded binary to be rejected")
+	}
+}
+
+func TestWithEmbeddedBinary_ReplacedByBinaryPath(t *testing.T) {
+	helper := New(WithEmbeddedBinary([]byte("image")), WithBinaryPath("/nonexistent"))
+	if _, err := helper.EncryptApiKey("testApiKey123"); !errors.Is(err, ErrBinaryNotFound) {
+		t.Errorf("Expected the later binary path to win, got %v", err)
+	}
+}
+
+func TestWithEmbeddedBinary_Clone(t *testing.T) {
+	helper := New(WithEmbeddedBinary(fakeImage(t)))
+	clone := helper.Clone()
+	defer clone.Close()
+
+	if _, err := helper.EncryptApiKey("testApiKey123"); err != nil {
+		t.Fatalf("EncryptApiKey failed: %v", err)
+	}
+	helper.Close()
+	if _, err := clone.EncryptApiKey("testApiKey123"); err != nil {
+		t.Errorf("Expected the clone to keep the shared extracted binary, got %v", err)
+	}
+}
+
+func TestWithEmbeddedBinary_ClonesLeaveNoDirectories(t *testing.T) {
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+	helper := New(WithEmbeddedBinary(fakeImage(t)))
+	clones := []*KeyRotationHelper{helper.Clone(), helper.Clone(WithNamespace("tenant-a")), helper.Clone(WithTimeout(time.Minute))}
+
+	for _, h := range append([]*KeyRotationHelper{helper}, clones...) {
+		if _, err := h.EncryptApiKey("testApiKey123"); err != nil {
+			t.Fatalf("EncryptApiKey failed: %v", err)
+		}
+	}
+	extracted := func() int {
+		entries, err := os.ReadDir(tmp)
+		if err != nil {
+			t.Fatalf("ReadDir failed: %v", err)
+		}
+		return len(entries)
+	}
+	if got := extracted(); got != 1 {
+		t.Errorf("Expected the helper and its clones to share one extracted binary, got %d directories", got)
+	}
+
+	helper.Close()
+	clones[0].Close()
+	if _, err := clones[1].EncryptApiKey("testApiKey123"); err != nil {
+		t.Errorf("Expected the open clones to keep the extracted binary, got %v", err)
+	}
+	for _, clone := range clones[1:] {
+		clone.Close()
+	}
+	if got := extracted(); got != 0 {
+		t.Errorf("Expected closing every helper to remove the extracted binary, got %d directories", got)
+	}
+}
@@ -0,0 +1,108 @@
+package keyrotation
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// request is a single subcommand for an engine to carry out
+type request struct {
+	command string
+	// apiKey is the plaintext key the subcommand operates on; keyed is false for subcommands without one
+	apiKey string
+	keyed  bool
+	args   []string
+	// input holds the newline-delimited items of a batch subcommand, and inputKeys the plaintext
+	// API keys among them, which are redacted from errors like apiKey
+	input     string
+	inputKeys []string
+	// json asks the binary for JSON output with jsonFlag
+	json bool
+}
+
+// fields flattens r into the subcommand, its API key if it is keyed, and the remaining arguments
+func (r request) fields() []string {
+	if !r.keyed {
+		return append([]string{r.command}, r.args...)
+	}
+	return append([]string{r.command, r.apiKey}, r.args...)
+}
+
+// engine carries out subcommands for a helper and returns their trimmed output. By default each
+// request starts the binary; NewPersistent and NewInMemory substitute engines that reuse a single
+// process or compute the result in Go.
+type engine interface {
+	do(ctx context.Context, r request) (string, error)
+}
+
+// execEngine starts the binary once per request
+type execEngine struct {
+	k *KeyRotationHelper
+}
+
+// do starts the binary for r. When a binary predating the stdin protocol rejects stdinFlag, the
+// helper switches to passing keys on argv, as with WithLegacyArgv, and retries r that way.
+func (e execEngine) do(ctx context.Context, r request) (string, error) {
+	out, err := e.k.invoke(ctx, r)
+	if r.keyed && !e.k.keyOnArgv(r.apiKey) && !needsFraming(r.apiKey) && isStdinUnsupported(err) {
+		e.k.stdinUnsupported.Store(true)
+		if err := e.k.checkArgs(r); err != nil {
+			return "", err
+		}
+		return e.k.invoke(ctx, r)
+	}
+	return out, err
+}
+
+// call carries out r on the helper's engine, reporting it to the configured observers
+func (k *KeyRotationHelper) call(ctx context.Context, r request) (string, error) {
+	if k.configErr != nil {
+		return "", k.configErr
+	}
+	if k.closed.Load() {
+		return "", ErrClosed
+	}
+	if err := k.checkBinaryVersion(ctx, r); err != nil {
+		return "", err
+	}
+	secrets := r.inputKeys
+	if r.keyed {
+		if err := k.checkKey(r.apiKey); err != nil {
+			return "", err
+		}
+		secrets = append(secrets, r.apiKey)
+		r.apiKey = k.binaryKey(r.apiKey)
+	}
+	if err := k.checkArgs(r); err != nil {
+		return "", err
+	}
+	if k.dryRun {
+		return k.dryRunCall(r)
+	}
+	out, err := k.execute(ctx, r.command, func(ctx context.Context) (string, error) {
+		out, err := k.engine.do(ctx, r)
+		return out, k.redactError(err, secrets...)
+	})
+	return out, k.algorithmError(err)
+}
+
+// checkArgs rejects requests that would place a value beginning with a dash among the binary's
+// positional arguments, where it could be parsed as a flag. The binary receives no "--"
+// terminator, since releases that predate it would take it for an argument, so values are
+// checked instead. The API key is only on argv in legacy argv mode or for binaries without the
+// stdin protocol; otherwise it goes to stdin, where any value is data.
+func (k *KeyRotationHelper) checkArgs(r request) error {
+	if r.keyed && k.keyOnArgv(r.apiKey) && strings.HasPrefix(r.apiKey, "-") {
+		return fmt.Errorf("%w: API keys starting with a dash cannot be passed in legacy argv mode", ErrUnsafeArgument)
+	}
+	if r.keyed && k.keyOnArgv(r.apiKey) && strings.ContainsRune(r.apiKey, 0) {
+		return fmt.Errorf("%w: API keys containing NUL bytes cannot be passed in legacy argv mode", ErrUnsafeArgument)
+	}
+	for _, arg := range r.args {
+		if strings.HasPrefix(arg, "-") {
+			return fmt.Errorf("%w: %s argument %q", ErrUnsafeArgument, r.command, arg)
+		}
+	}
+	return nil
+}
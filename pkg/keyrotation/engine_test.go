@@ -0,0 +1,136 @@
+package keyrotation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// hostileKeys look like flags or shell syntax, and must be treated as plain data
+var hostileKeys = []string{"-n", "--version", "--help", "; rm -rf /", "$(id)", "`id`"}
+
+func TestArgvInjection_KeysAreData(t *testing.T) {
+	helper := newFakeHelper(t)
+	reference := NewInMemory()
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	for _, apiKey := range hostileKeys {
+		want, err := reference.EncryptApiKeyWithDate(apiKey, date)
+		if err != nil {
+			t.Fatalf("%q: reference EncryptApiKeyWithDate failed: %v", apiKey, err)
+		}
+		got, err := helper.EncryptApiKeyWithDate(apiKey, date)
+		if err != nil || got != want {
+			t.Errorf("%q: expected %s, got %s, %v", apiKey, want, got, err)
+		}
+		if valid, err := helper.ValidateApiKey(apiKey, got, date); err != nil || !valid {
+			t.Errorf("%q: expected key to validate, got %v, %v", apiKey, valid, err)
+		}
+	}
+}
+
+func TestArgvInjection_KeysStayOffArgv(t *testing.T) {
+	t.Setenv(fakeModeEnv, "argv")
+	helper := newFakeHelper(t)
+
+	for _, apiKey := range hostileKeys {
+		argv, err := helper.EncryptApiKey(apiKey)
+		if err != nil {
+			t.Fatalf("%q: EncryptApiKey failed: %v", apiKey, err)
+		}
+		if argv != "--stdin encrypt" {
+			t.Errorf("%q: unexpected argv %q", apiKey, argv)
+		}
+	}
+}
+
+func TestArgvInjection_LegacyArgv(t *testing.T) {
+	helper := newFakeHelper(t, WithLegacyArgv(true))
+
+	for _, apiKey := range hostileKeys {
+		_, err := helper.EncryptApiKey(apiKey)
+		if strings.HasPrefix(apiKey, "-") {
+			if !errors.Is(err, ErrUnsafeArgument) {
+				t.Errorf("%q: expected ErrUnsafeArgument in legacy argv mode, got %v", apiKey, err)
+			}
+		} else if err != nil {
+			t.Errorf("%q: expected key to be passed as data, got %v", apiKey, err)
+		}
+	}
+}
+
+func TestArgvInjection_Ciphertexts(t *testing.T) {
+	recorder := &eventRecorder{}
+	helper := newFakeHelper(t, WithLogger(recorder), WithCiphertextCheck(false))
+
+	for _, encrypted := range []string{"--help", "-n"} {
+		if valid, err := helper.ValidateApiKeyToday("testApiKey123", encrypted); err != nil || valid {
+			t.Errorf("%q: expected the ciphertext to be rejected, got %v, %v", encrypted, valid, err)
+		}
+	}
+	if events := recorder.recorded(); len(events) != 0 {
+		t.Errorf("Expected dash-prefixed ciphertexts never to reach the binary, got %d invocations", len(events))
+	}
+	if _, err := helper.ValidateApiKeyTodayWithTolerance("testApiKey123", wrongCiphertext, -5); !errors.Is(err, ErrUnsafeArgument) {
+		t.Errorf("Expected a negative tolerance to be rejected, got %v", err)
+	}
+}
+
+// unusualKeys hold bytes the newline-terminated stdin protocol cannot carry, tabs, which separate
+// the fields of the persistent serve protocol, and multi-byte runes
+var unusualKeys = []string{"first\nsecond", "trailing-newline\n", "\n", "crlf\r\n", "nul\x00byte", "tab\tkey", "\t", "ключ-🔑-キー"}
+
+func TestStdinFraming_UnusualKeys(t *testing.T) {
+	reference := NewInMemory()
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	for name, helper := range map[string]*KeyRotationHelper{
+		"exec":       newFakeHelper(t),
+		"persistent": newPersistentFakeHelper(t),
+	} {
+		for _, apiKey := range unusualKeys {
+			want, err := reference.EncryptApiKeyWithDate(apiKey, date)
+			if err != nil {
+				t.Fatalf("%q: reference EncryptApiKeyWithDate failed: %v", apiKey, err)
+			}
+			got, err := helper.EncryptApiKeyWithDate(apiKey, date)
+			if err != nil || got != want {
+				t.Errorf("%s: %q: expected %s, got %s, %v", name, apiKey, want, got, err)
+			}
+			if valid, err := helper.ValidateApiKey(apiKey, want, date); err != nil || !valid {
+				t.Errorf("%s: %q: expected key to validate, got %v, %v", name, apiKey, valid, err)
+			}
+			// A key must never validate against its own truncation at the first line break
+			if prefix, _, cut := strings.Cut(apiKey, "\n"); cut && prefix != "" {
+				if valid, err := helper.ValidateApiKey(prefix, want, date); err != nil || valid {
+					t.Errorf("%s: %q: expected the truncated key to be rejected, got %v, %v", name, apiKey, valid, err)
+				}
+			}
+		}
+	}
+}
+
+func TestStdinFraming_Argv(t *testing.T) {
+	t.Setenv(fakeModeEnv, "argv")
+	helper := newFakeHelper(t)
+	for apiKey, want := range map[string]string{
+		"plain-key":     "--stdin encrypt",
+		"ключ-🔑-キー":     "--stdin encrypt",
+		"first\nsecond": "--stdin-length encrypt",
+		"nul\x00byte":   "--stdin-length encrypt",
+	} {
+		if argv, err := helper.EncryptApiKey(apiKey); err != nil || argv != want {
+			t.Errorf("%q: expected argv %q, got %q, %v", apiKey, want, argv, err)
+		}
+	}
+}
+
+func TestStdinFraming_Unsupported(t *testing.T) {
+	t.Setenv(fakeModeEnv, "legacy")
+	if _, err := newFakeHelper(t).EncryptApiKey("first\nsecond"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported from a binary without framing, got %v", err)
+	}
+	if _, err := newFakeHelper(t, WithLegacyArgv(true)).EncryptApiKey("nul\x00byte"); !errors.Is(err, ErrUnsafeArgument) {
+		t.Errorf("Expected ErrUnsafeArgument for a NUL byte on argv, got %v", err)
+	}
+}
@@ -0,0 +1,105 @@
+package keyrotation
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"syscall"
+)
+
+var (
+	// ErrBinaryNotFound is returned when the configured binary does not exist
+	ErrBinaryNotFound = errors.New("keyrotation: binary not found")
+	// ErrBinaryExecFailed is returned when the binary could not be started or exited unsuccessfully
+	ErrBinaryExecFailed = errors.New("keyrotation: binary execution failed")
+	// ErrBinaryNotExecutable is returned when the binary path names a directory, a file that is not
+	// a regular file, or one without execute permission. It wraps ErrBinaryExecFailed.
+	ErrBinaryNotExecutable = fmt.Errorf("%w: not an executable file", ErrBinaryExecFailed)
+	// ErrUnexpectedOutput is returned when the binary succeeds but its output cannot be interpreted
+	ErrUnexpectedOutput = errors.New("keyrotation: unexpected binary output")
+	// ErrUnsupported is returned when the binary does not implement a subcommand an operation needs
+	ErrUnsupported = errors.New("keyrotation: operation not supported by binary")
+	// ErrUnsupportedAlgorithm is returned when the binary does not implement the configured
+	// algorithm. It wraps ErrUnsupported.
+	ErrUnsupportedAlgorithm = fmt.Errorf("%w: unsupported algorithm", ErrUnsupported)
+	// ErrEmptyKey is returned when an operation is given an empty API key
+	ErrEmptyKey = errors.New("keyrotation: API key is empty")
+	// ErrKeyTooLong is returned when an API key exceeds the helper's maximum key length
+	ErrKeyTooLong = errors.New("keyrotation: API key is too long")
+	// ErrMalformedCiphertext is returned when a ciphertext to validate does not have the length and
+	// characters of a digest of the configured algorithm, such as a corrupted stored value
+	ErrMalformedCiphertext = errors.New("keyrotation: malformed ciphertext")
+	// ErrUnsafeArgument is returned when a value that would be placed on the binary's command line
+	// begins with a dash, so the binary could parse it as a flag rather than as data
+	ErrUnsafeArgument = errors.New("keyrotation: argument could be parsed as a flag")
+	// ErrDateOutOfRange is returned when an encryption date falls outside the bounds set with
+	// WithEarliestDate or WithMaxFutureDays
+	ErrDateOutOfRange = errors.New("keyrotation: date out of range")
+	// ErrBinaryKilled is returned when the binary was terminated by a signal, such as SIGKILL from
+	// the kernel's out-of-memory killer. The *BinaryError it comes with reports the signal.
+	ErrBinaryKilled = errors.New("keyrotation: binary killed by signal")
+	// ErrBinaryVersionMismatch is returned when the binary's version does not satisfy the
+	// constraint set with WithRequiredBinaryVersion
+	ErrBinaryVersionMismatch = errors.New("keyrotation: binary version mismatch")
+	// ErrStaleCiphertexts is returned when the file loaded with LoadValidCiphertexts declares a
+	// rotation date other than the current one
+	ErrStaleCiphertexts = errors.New("keyrotation: loaded ciphertexts are for another date")
+	// ErrTimeout is returned when a binary invocation exceeds the helper's configured timeout
+	ErrTimeout = errors.New("keyrotation: binary invocation timed out")
+	// ErrClosed is returned when a helper is used after Close
+	ErrClosed = errors.New("keyrotation: helper is closed")
+)
+
+// BinaryError reports a binary invocation that ran but exited unsuccessfully
+type BinaryError struct {
+	// Command is the binary path followed by the arguments it was invoked with, with the API key
+	// replaced by RedactedKey unless redaction is disabled
+	Command []string
+	// ExitCode is the process exit status, or -1 if it was terminated by a signal
+	ExitCode int
+	// Signal is the signal that terminated the process, or zero if it exited on its own
+	Signal syscall.Signal
+	// Stderr is the diagnostic output the binary wrote before exiting
+	Stderr string
+	// Err is the underlying error reported by os/exec
+	Err error
+}
+
+func (e *BinaryError) Error() string {
+	msg := fmt.Sprintf("%s exited with status %d", e.CommandLine(), e.ExitCode)
+	if e.Signal != 0 {
+		msg = fmt.Sprintf("%s was killed by signal %d (%s)", e.CommandLine(), int(e.Signal), e.Signal)
+	}
+	if e.Stderr != "" {
+		msg += ": " + e.Stderr
+	}
+	return msg
+}
+
+// CommandLine renders Command as a shell command line, for reproducing the failure by hand.
+// Unless the helper was in legacy argv mode, the binary also read the API key from stdin.
+func (e *BinaryError) CommandLine() string {
+	return shellJoin(e.Command)
+}
+
+func (e *BinaryError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports BinaryError as an ErrBinaryExecFailed so callers can match it with errors.Is, and
+// also as an ErrBinaryKilled when a signal terminated the process
+func (e *BinaryError) Is(target error) bool {
+	return target == ErrBinaryExecFailed || (target == ErrBinaryKilled && e.Signal != 0)
+}
+
+// exitSignal returns the signal that terminated the process err reports, or zero if there is none
+func exitSignal(err error) syscall.Signal {
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) {
+		return 0
+	}
+	if status, ok := exitErr.Sys().(syscall.WaitStatus); ok && status.Signaled() {
+		return status.Signal()
+	}
+	return 0
+}
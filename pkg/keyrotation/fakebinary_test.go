@@ -0,0 +1,413 @@
+package keyrotation
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// The test executable doubles as a stand-in for the private binary. When
+// fakeBinaryEnv is set it behaves like keyrotation-binary instead of running
+// the tests, which lets the wrapper be exercised without the real binary.
+const (
+	fakeBinaryEnv = "KEYROTATION_FAKE_BINARY"
+	fakeModeEnv   = "KEYROTATION_FAKE_MODE"
+	fakeVersion   = "1.4.2"
+)
+
+func TestMain(m *testing.M) {
+	// Fuzzing workers are started as copies of the test executable and inherit fakeBinaryEnv
+	if os.Getenv(fakeBinaryEnv) == "1" && !slices.ContainsFunc(os.Args, isFuzzWorkerFlag) {
+		os.Exit(fakeBinaryMain(os.Args[1:]))
+	}
+	os.Setenv(fakeBinaryEnv, "1")
+	os.Exit(m.Run())
+}
+
+// isFuzzWorkerFlag reports whether arg is the flag go test passes to fuzzing workers
+func isFuzzWorkerFlag(arg string) bool {
+	return strings.HasPrefix(arg, "-test.fuzzworker")
+}
+
+// newFakeHelper returns a helper whose binary is the fake implemented below
+func newFakeHelper(t testing.TB, opts ...Option) *KeyRotationHelper {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("Failed to locate test executable: %v", err)
+	}
+	return NewWithBinaryPath(exe, opts...)
+}
+
+// wrongCiphertext is a well-formed SHA-256 ciphertext that matches no test key
+var wrongCiphertext = strings.Repeat("0", 64)
+
+// fakeAlgorithm is the hash selected by the fake binary's --algo flag
+var fakeAlgorithm = AlgorithmSHA256
+
+// fakeInterval is the rotation interval selected by the fake binary's --interval flag
+var fakeInterval = 24 * time.Hour
+
+// fakePepper is the HMAC key read from pepperEnvVar when the --hmac flag is given
+var fakePepper []byte
+
+// fakeHash mirrors the private binary's formula: SHA256(yyyyMMdd + apiKey), hex encoded, with
+// SHA-512 substituted when it was selected, an HMAC keyed with the pepper in HMAC mode, and
+// yyyyMMddHH for the start of the interval when a sub-daily interval was selected. The
+// "base64", "base64url" and "hex-upper" modes encode it in those encodings instead, like some
+// binary versions and forks do.
+func fakeHash(apiKey string, date time.Time) string {
+	newHash := sha256.New
+	if fakeAlgorithm == AlgorithmSHA512 {
+		newHash = sha512.New
+	}
+	h := newHash()
+	if fakePepper != nil {
+		h = hmac.New(newHash, fakePepper)
+	}
+	h.Write([]byte(intervalString(date, fakeInterval) + apiKey))
+	switch os.Getenv(fakeModeEnv) {
+	case "base64":
+		return base64.StdEncoding.EncodeToString(h.Sum(nil))
+	case "base64url":
+		return base64.URLEncoding.EncodeToString(h.Sum(nil))
+	case "hex-upper":
+		return strings.ToUpper(hex.EncodeToString(h.Sum(nil)))
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// legacyCommands are the subcommands supported by binaries that predate the wrapper's extensions
+var legacyCommands = map[string]bool{
+	"encrypt":            true,
+	"encrypt-date":       true,
+	"validate":           true,
+	"validate-date":      true,
+	"validate-tolerance": true,
+}
+
+func fakeBinaryMain(args []string) int {
+	switch os.Getenv(fakeModeEnv) {
+	case "hang":
+		time.Sleep(time.Minute)
+		return 0
+	case "argv":
+		fmt.Println(strings.Join(args, " "))
+		return 0
+	case "silent":
+		return 0
+	case "env":
+		fmt.Println(strings.Join(os.Environ(), ","))
+		return 0
+	case "pwd":
+		dir, _ := os.Getwd()
+		fmt.Println(dir)
+		return 0
+	case "always-true":
+		fmt.Println("true")
+		return 0
+	case "killed":
+		self, _ := os.FindProcess(os.Getpid())
+		self.Kill()
+		time.Sleep(time.Minute)
+		return 0
+	}
+
+	// Legacy mode imitates a binary that only supports the original argv subcommands
+	if os.Getenv(fakeModeEnv) == "legacy" && len(args) > 0 && !legacyCommands[args[0]] {
+		fmt.Fprintf(os.Stderr, "Unknown command: %s\n", args[0])
+		return 1
+	}
+
+	if len(args) > 1 && args[0] == "--algo" {
+		fakeAlgorithm = Algorithm(args[1])
+		if fakeAlgorithm != AlgorithmSHA256 && fakeAlgorithm != AlgorithmSHA512 {
+			fmt.Fprintf(os.Stderr, "Unsupported algorithm: %s\n", args[1])
+			return 1
+		}
+		args = args[2:]
+	}
+
+	if len(args) > 0 && args[0] == "--hmac" {
+		pepper, err := hex.DecodeString(os.Getenv(pepperEnvVar))
+		if err != nil || len(pepper) == 0 {
+			fmt.Fprintln(os.Stderr, "HMAC mode requires a hex pepper in", pepperEnvVar)
+			return 1
+		}
+		fakePepper = pepper
+		args = args[1:]
+	}
+
+	if len(args) > 1 && args[0] == "--interval" {
+		interval, err := time.ParseDuration(args[1])
+		if err != nil || checkInterval(interval) != nil {
+			fmt.Fprintf(os.Stderr, "Unsupported interval: %s\n", args[1])
+			return 1
+		}
+		fakeInterval = interval
+		args = args[2:]
+	}
+
+	jsonOutput := len(args) > 0 && args[0] == "--json"
+	if jsonOutput {
+		args = args[1:]
+	}
+
+	if len(args) > 1 && args[0] == "--stdin-length" {
+		stdin := bufio.NewReader(os.Stdin)
+		prefix, err := stdin.ReadString('\n')
+		n, convErr := strconv.Atoi(strings.TrimSuffix(prefix, "\n"))
+		if err != nil || convErr != nil || n < 0 {
+			fmt.Fprintln(os.Stderr, "malformed API key length on stdin")
+			return 1
+		}
+		key := make([]byte, n)
+		if _, err := io.ReadFull(stdin, key); err != nil {
+			fmt.Fprintln(os.Stderr, "failed to read API key from stdin:", err)
+			return 1
+		}
+		args = append([]string{args[1], string(key)}, args[2:]...)
+	}
+
+	if len(args) > 1 && args[0] == "--stdin" {
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "failed to read API key from stdin:", err)
+			return 1
+		}
+		args = append([]string{args[1], strings.TrimSuffix(line, "\n")}, args[2:]...)
+	}
+
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: keyrotation-binary <command> [args...]")
+		return 1
+	}
+	if len(args) == 1 && args[0] == "serve" {
+		return fakeServe()
+	}
+	if len(args) == 1 && strings.HasSuffix(args[0], "-batch") {
+		return fakeBatch(args[0])
+	}
+
+	if out, ok, err := fakeKeyless(args); ok {
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		fakePrintln(out)
+		return 0
+	}
+
+	out, err := fakeCommand(args)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	if jsonOutput {
+		out = fakeJSON(args, out)
+	}
+	fakePrintln(out)
+	return 0
+}
+
+// fakeJSON describes the ciphertext out of the encrypt-date subcommand in args as JSON output mode does
+func fakeJSON(args []string, out string) string {
+	date, _ := parseBinaryDate(args[len(args)-1])
+	info, _ := json.Marshal(EncryptionInfo{Ciphertext: out, Date: intervalString(date, fakeInterval), Algorithm: fakeAlgorithm, Version: fakeVersion})
+	return string(info)
+}
+
+// fakeBOMWritten records whether the "bom" mode has already prefixed the output with a byte order mark
+var fakeBOMWritten bool
+
+// fakePrintln prints one line of output as a binary on another platform or fork might: the "crlf"
+// mode ends lines with CRLF, the "digits" mode prints 1 and 0 for true and false, and the "bom"
+// mode prefixes the output with a UTF-8 byte order mark, upper-cases it and pads it with whitespace
+func fakePrintln(line string) {
+	switch os.Getenv(fakeModeEnv) {
+	case "crlf":
+		fmt.Print(line + "\r\n")
+	case "digits":
+		fmt.Println(strings.NewReplacer("true", "1", "false", "0").Replace(line))
+	case "bom":
+		if !fakeBOMWritten {
+			fmt.Print("\uFEFF")
+			fakeBOMWritten = true
+		}
+		fmt.Printf(" %s \r\n", strings.ToUpper(line))
+	default:
+		fmt.Println(line)
+	}
+}
+
+// fakeKeyless runs a subcommand that takes no API key, reporting ok=false for any other subcommand
+func fakeKeyless(args []string) (out string, ok bool, err error) {
+	switch args[0] {
+	case "version":
+		return fakeVersion, true, nil
+	case "selftest":
+		if os.Getenv(fakeModeEnv) == "selftest-fail" {
+			return "", true, errors.New("selftest failed: hash mismatch")
+		}
+		return "ok", true, nil
+	case "capabilities":
+		return inMemoryCapabilities + " serve json", true, nil
+	case "derive-key":
+		if len(args) != 2 {
+			return "", true, errors.New("Usage: keyrotation-binary derive-key <date>")
+		}
+		date, err := parseBinaryDate(args[1])
+		if err != nil {
+			return "", true, err
+		}
+		return intervalString(date, fakeInterval), true, nil
+	}
+	return "", false, nil
+}
+
+// fakeCommand runs a single-key subcommand, where args[1] is always the plaintext API key
+func fakeCommand(args []string) (string, error) {
+	if len(args) > 1 && args[1] == "" {
+		return "", errors.New("API key cannot be empty")
+	}
+	// The "echo-key" mode imitates a binary that echoes its secrets in diagnostics
+	if os.Getenv(fakeModeEnv) == "echo-key" && len(args) > 1 {
+		return "", fmt.Errorf("rejected API key %s with pepper %s", args[1], os.Getenv(pepperEnvVar))
+	}
+
+	today := time.Now().UTC()
+	switch cmd := args[0]; {
+	case cmd == "encrypt" && len(args) == 2:
+		return fakeHash(args[1], today), nil
+	case cmd == "encrypt-date" && len(args) == 3:
+		date, err := parseBinaryDate(args[2])
+		if err != nil {
+			return "", err
+		}
+		return fakeHash(args[1], date), nil
+	case cmd == "validate" && len(args) == 3:
+		return strconv.FormatBool(fakeHash(args[1], today) == args[2]), nil
+	case cmd == "validate-date" && len(args) == 4:
+		date, err := parseBinaryDate(args[3])
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatBool(fakeHash(args[1], date) == args[2]), nil
+	case cmd == "validate-tolerance" && len(args) == 4:
+		tolerance, err := strconv.Atoi(args[3])
+		if err != nil {
+			return "", err
+		}
+		return fakeTolerance(args[1], args[2], today, tolerance), nil
+	case cmd == "validate-tolerance-date" && len(args) == 5:
+		at, err := time.Parse(time.RFC3339, args[3])
+		if err != nil {
+			return "", err
+		}
+		tolerance, err := strconv.Atoi(args[4])
+		if err != nil {
+			return "", err
+		}
+		return fakeTolerance(args[1], args[2], at, tolerance), nil
+	default:
+		return "", fmt.Errorf("Unknown command: %s", cmd)
+	}
+}
+
+// fakeServe answers tab-separated requests on stdin until it is closed
+func fakeServe() int {
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		fields := strings.Split(scanner.Text(), "\t")
+		if fields[0] == "ping" {
+			fmt.Println("ok\tpong")
+			continue
+		}
+		if os.Getenv(fakeModeEnv) == "serve-hang" {
+			time.Sleep(time.Minute)
+		}
+		out, ok, err := fakeKeyless(fields)
+		if !ok {
+			out, err = fakeCommand(fields)
+		}
+		if err != nil {
+			fmt.Printf("error\t%v\n", err)
+			continue
+		}
+		fmt.Printf("ok\t%s\n", out)
+	}
+	return 0
+}
+
+// fakeBatch answers a batch subcommand with one output line per stdin line
+func fakeBatch(command string) int {
+	today := time.Now().UTC()
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if os.Getenv(fakeModeEnv) == "echo-key" {
+			fakePrintln("error: rejected " + line)
+			continue
+		}
+		switch command {
+		case "encrypt-batch":
+			if line == "" {
+				fakePrintln("error: API key cannot be empty")
+				continue
+			}
+			fakePrintln(fakeHash(line, today))
+		case "validate-batch":
+			encrypted, apiKey, ok := strings.Cut(line, "\t")
+			if !ok {
+				fakePrintln("error: malformed batch line")
+				continue
+			}
+			fakePrintln(strconv.FormatBool(fakeHash(apiKey, today) == encrypted))
+		case "validate-date-batch":
+			day, rest, _ := strings.Cut(line, "\t")
+			encrypted, apiKey, ok := strings.Cut(rest, "\t")
+			date, err := parseBinaryDate(day)
+			if !ok || err != nil {
+				fakePrintln("error: malformed batch line")
+				continue
+			}
+			fakePrintln(strconv.FormatBool(fakeHash(apiKey, date) == encrypted))
+		case "encrypt-date-batch":
+			day, apiKey, _ := strings.Cut(line, "\t")
+			date, err := parseBinaryDate(day)
+			if err != nil {
+				fakePrintln("error: " + err.Error())
+				continue
+			}
+			fakePrintln(fakeHash(apiKey, date))
+		default:
+			fmt.Fprintf(os.Stderr, "Unknown command: %s\n", command)
+			return 1
+		}
+	}
+	return 0
+}
+
+// fakeTolerance reports whether encrypted matches any rotation date within tolerance minutes either
+// side of at, trying every minute in turn rather than sharing the library's window arithmetic
+func fakeTolerance(apiKey, encrypted string, at time.Time, tolerance int) string {
+	for m := -tolerance; m <= tolerance; m++ {
+		if fakeHash(apiKey, at.Add(time.Duration(m)*time.Minute)) == encrypted {
+			return "true"
+		}
+	}
+	return "false"
+}
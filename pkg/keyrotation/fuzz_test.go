@@ -0,0 +1,102 @@
+package keyrotation
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func FuzzValidationOutput(f *testing.F) {
+	for _, seed := range []string{"true", "false", "TRUE\r\n", "\uFEFFfalse\n", " true ", "", "yes", "Usage: validate", "testApiKey123"} {
+		f.Add(seed)
+	}
+	const apiKey = "testApiKey123"
+
+	f.Fuzz(func(t *testing.T, out string) {
+		helper := New(WithCommandRunner(&scriptedRunner{stdout: out}))
+		isValid, err := helper.ValidateApiKeyToday(apiKey, wrongCiphertext)
+
+		switch result := normalizeOutput(out); {
+		case strings.EqualFold(result, "true"):
+			if err != nil || !isValid {
+				t.Errorf("Output %q: expected true, got %v, %v", out, isValid, err)
+			}
+		case strings.EqualFold(result, "false"):
+			if err != nil || isValid {
+				t.Errorf("Output %q: expected false, got %v, %v", out, isValid, err)
+			}
+		default:
+			if !errors.Is(err, ErrUnexpectedOutput) || isValid {
+				t.Fatalf("Output %q: expected ErrUnexpectedOutput, got %v, %v", out, isValid, err)
+			}
+			if strings.Contains(err.Error(), apiKey) {
+				t.Errorf("Output %q: API key leaked into error %q", out, err)
+			}
+		}
+	})
+}
+
+// decodeStdinKey recovers the API key from the flags and stdin of an invocation as the binary
+// reads it, with either the line-based or the length-prefixed protocol
+func decodeStdinKey(args []string, stdin string) (string, error) {
+	switch {
+	case slices.Contains(args, stdinLengthFlag):
+		r := bufio.NewReader(strings.NewReader(stdin))
+		prefix, err := r.ReadString('\n')
+		if err != nil {
+			return "", fmt.Errorf("missing length prefix: %w", err)
+		}
+		n, err := strconv.Atoi(strings.TrimSuffix(prefix, "\n"))
+		if err != nil || n < 0 {
+			return "", fmt.Errorf("malformed length prefix %q", prefix)
+		}
+		key := make([]byte, n)
+		if _, err := io.ReadFull(r, key); err != nil {
+			return "", fmt.Errorf("short key: %w", err)
+		}
+		if rest, _ := io.ReadAll(r); len(rest) > 0 {
+			return "", fmt.Errorf("%d trailing bytes after the key", len(rest))
+		}
+		return string(key), nil
+	case slices.Contains(args, stdinFlag):
+		line, ok := strings.CutSuffix(stdin, "\n")
+		if !ok || strings.ContainsAny(line, "\r\n") {
+			return "", fmt.Errorf("stdin %q is not a single line", stdin)
+		}
+		return line, nil
+	}
+	return "", errors.New("no stdin flag")
+}
+
+func FuzzStdinFraming(f *testing.F) {
+	for _, seed := range []string{"testApiKey123", "line\nbreak", "carriage\rreturn", "nul\x00byte", "12\nfake", "\n", " padded "} {
+		f.Add(seed)
+	}
+
+	f.Fuzz(func(t *testing.T, apiKey string) {
+		runner := &scriptedRunner{stdout: wrongCiphertext}
+		helper := New(WithCommandRunner(runner))
+		if _, err := helper.EncryptApiKey(apiKey); err != nil {
+			if runner.args != nil {
+				t.Fatalf("Key %q: encryption failed after invoking the binary: %v", apiKey, err)
+			}
+			return
+		}
+
+		got, err := decodeStdinKey(runner.args, runner.stdin)
+		if err != nil {
+			t.Fatalf("Key %q: failed to decode stdin %q: %v", apiKey, runner.stdin, err)
+		}
+		if got != apiKey {
+			t.Errorf("Key %q: binary would read %q", apiKey, got)
+		}
+		if slices.Contains(runner.args, apiKey) {
+			t.Errorf("Key %q: passed on argv %q", apiKey, runner.args)
+		}
+	})
+}
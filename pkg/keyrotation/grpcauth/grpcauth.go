@@ -0,0 +1,99 @@
+// Package grpcauth provides a gRPC server interceptor that authenticates calls by validating their
+// API key against today's rotation.
+package grpcauth
+
+import (
+	"context"
+	"errors"
+
+	"github.com/pawincpe/key-rotation/pkg/keyrotation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// DefaultMetadataKey is the metadata key the API key is read from unless overridden with WithMetadataKey
+const DefaultMetadataKey = "x-api-key"
+
+// ErrUnknownKey is returned by a ResolveFunc when the call has no stored ciphertext, such as an
+// unrecognized client. The interceptor answers it with codes.Unauthenticated rather than codes.Internal.
+var ErrUnknownKey = errors.New("grpcauth: no stored ciphertext for call")
+
+// Validator validates an API key against today's rotation. *keyrotation.KeyRotationHelper implements it.
+type Validator interface {
+	ValidateApiKeyTodayContext(ctx context.Context, apiKey, encryptedKey string) (bool, error)
+}
+
+// ResolveFunc returns the stored ciphertext the API key of the call described by ctx and info must
+// match. It returns an error wrapping ErrUnknownKey when there is none.
+type ResolveFunc func(ctx context.Context, info *grpc.UnaryServerInfo) (string, error)
+
+// Option configures the interceptor
+type Option func(*config)
+
+type config struct {
+	metadataKey string
+}
+
+// WithMetadataKey sets the metadata key the API key is read from. The default is DefaultMetadataKey.
+func WithMetadataKey(key string) Option {
+	return func(c *config) {
+		c.metadataKey = key
+	}
+}
+
+// UnaryServerInterceptor returns an interceptor that passes a call on to its handler only if its
+// API key is valid for today. Calls with a missing, unknown, mismatched or malformed key (empty,
+// too long or unsafe to pass to the binary) fail with codes.Unauthenticated; other failures to
+// resolve or validate the key fail with codes.Internal. Validation uses the call's context, so it
+// respects the incoming deadline.
+func UnaryServerInterceptor(v Validator, resolve ResolveFunc, opts ...Option) grpc.UnaryServerInterceptor {
+	c := config{metadataKey: DefaultMetadataKey}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		values := md.Get(c.metadataKey)
+		if len(values) != 1 || values[0] == "" {
+			return nil, status.Error(codes.Unauthenticated, "missing API key")
+		}
+
+		encryptedKey, err := resolve(ctx, info)
+		if errors.Is(err, ErrUnknownKey) {
+			return nil, status.Error(codes.Unauthenticated, "invalid API key")
+		}
+		if err != nil {
+			return nil, internalError(err)
+		}
+
+		isValid, err := v.ValidateApiKeyTodayContext(ctx, values[0], encryptedKey)
+		if isClientError(err) {
+			return nil, status.Error(codes.Unauthenticated, "invalid API key")
+		}
+		if err != nil {
+			return nil, internalError(err)
+		}
+		if !isValid {
+			return nil, status.Error(codes.Unauthenticated, "invalid API key")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// isClientError reports whether a validation failed because of the key the client sent rather than
+// a fault of the server
+func isClientError(err error) bool {
+	return errors.Is(err, keyrotation.ErrEmptyKey) || errors.Is(err, keyrotation.ErrKeyTooLong) || errors.Is(err, keyrotation.ErrUnsafeArgument)
+}
+
+// internalError converts a resolution or validation failure to a status, reporting an expired
+// deadline or cancellation as such rather than as an internal error
+func internalError(err error) error {
+	if s := status.FromContextError(err); s.Code() != codes.Unknown {
+		return s.Err()
+	}
+	return status.Error(codes.Internal, "failed to validate API key")
+}
@@ -0,0 +1,140 @@
+package grpcauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/pawincpe/key-rotation/pkg/keyrotation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// validatorFunc adapts a function to the Validator interface
+type validatorFunc func(ctx context.Context, apiKey, encryptedKey string) (bool, error)
+
+func (f validatorFunc) ValidateApiKeyTodayContext(ctx context.Context, apiKey, encryptedKey string) (bool, error) {
+	return f(ctx, apiKey, encryptedKey)
+}
+
+var info = &grpc.UnaryServerInfo{FullMethod: "/test.Service/Method"}
+
+func handler(ctx context.Context, req any) (any, error) {
+	return "handled", nil
+}
+
+// intercept runs the interceptor for a call carrying md and returns the resulting status code
+func intercept(t *testing.T, ctx context.Context, v Validator, resolve ResolveFunc, md metadata.MD, opts ...Option) codes.Code {
+	t.Helper()
+	resp, err := UnaryServerInterceptor(v, resolve, opts...)(metadata.NewIncomingContext(ctx, md), "request", info, handler)
+	if err == nil && resp != "handled" {
+		t.Fatalf("Expected handler response, got %v", resp)
+	}
+	return status.Code(err)
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	helper := keyrotation.NewInMemory()
+	encrypted, err := helper.EncryptApiKey("client-key")
+	if err != nil {
+		t.Fatalf("EncryptApiKey failed: %v", err)
+	}
+	resolve := func(ctx context.Context, info *grpc.UnaryServerInfo) (string, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		if ids := md.Get("x-client-id"); len(ids) == 0 || ids[0] != "known" {
+			return "", ErrUnknownKey
+		}
+		return encrypted, nil
+	}
+
+	tests := []struct {
+		name string
+		md   metadata.MD
+		want codes.Code
+	}{
+		{"valid key", metadata.Pairs("x-client-id", "known", DefaultMetadataKey, "client-key"), codes.OK},
+		{"wrong key", metadata.Pairs("x-client-id", "known", DefaultMetadataKey, "other-key"), codes.Unauthenticated},
+		{"missing key", metadata.Pairs("x-client-id", "known"), codes.Unauthenticated},
+		{"repeated key", metadata.Pairs("x-client-id", "known", DefaultMetadataKey, "client-key", DefaultMetadataKey, "other-key"), codes.Unauthenticated},
+		{"unknown client", metadata.Pairs("x-client-id", "unknown", DefaultMetadataKey, "client-key"), codes.Unauthenticated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := intercept(t, context.Background(), helper, resolve, tt.md); got != tt.want {
+				t.Errorf("Expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestUnaryServerInterceptor_WithMetadataKey(t *testing.T) {
+	helper := keyrotation.NewInMemory()
+	encrypted, _ := helper.EncryptApiKey("client-key")
+	resolve := func(ctx context.Context, info *grpc.UnaryServerInfo) (string, error) { return encrypted, nil }
+
+	md := metadata.Pairs("authorization-key", "client-key")
+	if got := intercept(t, context.Background(), helper, resolve, md, WithMetadataKey("authorization-key")); got != codes.OK {
+		t.Errorf("Expected custom metadata key to be accepted, got %s", got)
+	}
+}
+
+func TestUnaryServerInterceptor_Errors(t *testing.T) {
+	valid := validatorFunc(func(ctx context.Context, apiKey, encryptedKey string) (bool, error) {
+		return true, nil
+	})
+	failing := validatorFunc(func(ctx context.Context, apiKey, encryptedKey string) (bool, error) {
+		return false, keyrotation.ErrBinaryNotFound
+	})
+	stored := func(ctx context.Context, info *grpc.UnaryServerInfo) (string, error) { return "stored", nil }
+	broken := func(ctx context.Context, info *grpc.UnaryServerInfo) (string, error) {
+		return "", errors.New("database unavailable")
+	}
+	md := metadata.Pairs(DefaultMetadataKey, "client-key")
+
+	if got := intercept(t, context.Background(), valid, broken, md); got != codes.Internal {
+		t.Errorf("Expected resolver failure to yield Internal, got %s", got)
+	}
+	if got := intercept(t, context.Background(), failing, stored, md); got != codes.Internal {
+		t.Errorf("Expected validation failure to yield Internal, got %s", got)
+	}
+}
+
+func TestUnaryServerInterceptor_ClientErrors(t *testing.T) {
+	helper := keyrotation.NewInMemory()
+	encrypted, _ := helper.EncryptApiKey("client-key")
+	resolve := func(ctx context.Context, info *grpc.UnaryServerInfo) (string, error) { return encrypted, nil }
+
+	md := metadata.Pairs(DefaultMetadataKey, strings.Repeat("k", 5000))
+	if got := intercept(t, context.Background(), helper, resolve, md); got != codes.Unauthenticated {
+		t.Errorf("Expected an overlong key to yield Unauthenticated, got %s", got)
+	}
+
+	md = metadata.Pairs(DefaultMetadataKey, "client-key")
+	for _, err := range []error{keyrotation.ErrEmptyKey, keyrotation.ErrKeyTooLong, keyrotation.ErrUnsafeArgument} {
+		v := validatorFunc(func(ctx context.Context, apiKey, encryptedKey string) (bool, error) {
+			return false, fmt.Errorf("failed to validate API key for today: %w", err)
+		})
+		if got := intercept(t, context.Background(), v, resolve, md); got != codes.Unauthenticated {
+			t.Errorf("Expected %v to yield Unauthenticated, got %s", err, got)
+		}
+	}
+}
+
+func TestUnaryServerInterceptor_RespectsDeadline(t *testing.T) {
+	v := validatorFunc(func(ctx context.Context, apiKey, encryptedKey string) (bool, error) {
+		<-ctx.Done()
+		return false, ctx.Err()
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	md := metadata.Pairs(DefaultMetadataKey, "client-key")
+	stored := func(ctx context.Context, info *grpc.UnaryServerInfo) (string, error) { return "stored", nil }
+	if got := intercept(t, ctx, v, stored, md); got != codes.Canceled {
+		t.Errorf("Expected cancellation to be reported as Canceled, got %s", got)
+	}
+}
@@ -0,0 +1,102 @@
+// Package httpauth provides net/http middleware that authenticates requests by validating their
+// API key against today's rotation.
+package httpauth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/pawincpe/key-rotation/pkg/keyrotation"
+)
+
+// DefaultHeader is the request header the API key is read from unless overridden with WithHeader
+const DefaultHeader = "X-API-Key"
+
+// ErrUnknownKey is returned by a ResolveFunc when the request has no stored ciphertext, such as an
+// unrecognized client. The middleware answers it with 401 rather than 500.
+var ErrUnknownKey = errors.New("httpauth: no stored ciphertext for request")
+
+// Validator validates an API key against today's rotation. *keyrotation.KeyRotationHelper implements it.
+type Validator interface {
+	ValidateApiKeyTodayContext(ctx context.Context, apiKey, encryptedKey string) (bool, error)
+}
+
+// ResolveFunc returns the stored ciphertext the API key of r must match. It returns an error
+// wrapping ErrUnknownKey when there is none.
+type ResolveFunc func(r *http.Request) (string, error)
+
+// Option configures the middleware
+type Option func(*config)
+
+type config struct {
+	header string
+}
+
+// WithHeader sets the request header the API key is read from. The default is DefaultHeader.
+func WithHeader(name string) Option {
+	return func(c *config) {
+		c.header = name
+	}
+}
+
+// Middleware returns middleware that passes a request on to the next handler only if its API key
+// is valid for today. Requests with a missing, unknown, mismatched or malformed key (empty, too
+// long or unsafe to pass to the binary) are rejected with 401 Unauthorized; other failures to
+// resolve or validate the key are rejected with 500. Validation uses the request's context, so it
+// is abandoned when the client goes away.
+func Middleware(v Validator, resolve ResolveFunc, opts ...Option) func(http.Handler) http.Handler {
+	c := config{header: DefaultHeader}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			apiKey := r.Header.Get(c.header)
+			if apiKey == "" {
+				unauthorized(w)
+				return
+			}
+
+			encryptedKey, err := resolve(r)
+			if errors.Is(err, ErrUnknownKey) {
+				unauthorized(w)
+				return
+			}
+			if err != nil {
+				internalError(w)
+				return
+			}
+
+			isValid, err := v.ValidateApiKeyTodayContext(r.Context(), apiKey, encryptedKey)
+			if isClientError(err) {
+				unauthorized(w)
+				return
+			}
+			if err != nil {
+				internalError(w)
+				return
+			}
+			if !isValid {
+				unauthorized(w)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// isClientError reports whether a validation failed because of the key the client sent rather than
+// a fault of the server
+func isClientError(err error) bool {
+	return errors.Is(err, keyrotation.ErrEmptyKey) || errors.Is(err, keyrotation.ErrKeyTooLong) || errors.Is(err, keyrotation.ErrUnsafeArgument)
+}
+
+func unauthorized(w http.ResponseWriter) {
+	http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+}
+
+func internalError(w http.ResponseWriter) {
+	http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+}
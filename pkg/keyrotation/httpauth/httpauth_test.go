@@ -0,0 +1,136 @@
+package httpauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/pawincpe/key-rotation/pkg/keyrotation"
+)
+
+// validatorFunc adapts a function to the Validator interface
+type validatorFunc func(ctx context.Context, apiKey, encryptedKey string) (bool, error)
+
+func (f validatorFunc) ValidateApiKeyTodayContext(ctx context.Context, apiKey, encryptedKey string) (bool, error) {
+	return f(ctx, apiKey, encryptedKey)
+}
+
+func serve(t *testing.T, v Validator, resolve ResolveFunc, req *http.Request, opts ...Option) int {
+	t.Helper()
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+	rec := httptest.NewRecorder()
+	Middleware(v, resolve, opts...)(next).ServeHTTP(rec, req)
+	return rec.Code
+}
+
+func TestMiddleware(t *testing.T) {
+	helper := keyrotation.NewInMemory()
+	encrypted, err := helper.EncryptApiKey("client-key")
+	if err != nil {
+		t.Fatalf("EncryptApiKey failed: %v", err)
+	}
+	resolve := func(r *http.Request) (string, error) {
+		if r.URL.Query().Get("client") != "known" {
+			return "", ErrUnknownKey
+		}
+		return encrypted, nil
+	}
+
+	tests := []struct {
+		name   string
+		target string
+		apiKey string
+		want   int
+	}{
+		{"valid key", "/?client=known", "client-key", http.StatusNoContent},
+		{"wrong key", "/?client=known", "other-key", http.StatusUnauthorized},
+		{"missing key", "/?client=known", "", http.StatusUnauthorized},
+		{"unknown client", "/?client=unknown", "client-key", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.target, nil)
+			if tt.apiKey != "" {
+				req.Header.Set(DefaultHeader, tt.apiKey)
+			}
+			if got := serve(t, helper, resolve, req); got != tt.want {
+				t.Errorf("Expected status %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMiddleware_WithHeader(t *testing.T) {
+	helper := keyrotation.NewInMemory()
+	encrypted, _ := helper.EncryptApiKey("client-key")
+	resolve := func(r *http.Request) (string, error) { return encrypted, nil }
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization-Key", "client-key")
+	if got := serve(t, helper, resolve, req, WithHeader("Authorization-Key")); got != http.StatusNoContent {
+		t.Errorf("Expected custom header to be accepted, got %d", got)
+	}
+}
+
+func TestMiddleware_Errors(t *testing.T) {
+	valid := validatorFunc(func(ctx context.Context, apiKey, encryptedKey string) (bool, error) {
+		return true, nil
+	})
+	failing := validatorFunc(func(ctx context.Context, apiKey, encryptedKey string) (bool, error) {
+		return false, keyrotation.ErrBinaryNotFound
+	})
+	stored := func(r *http.Request) (string, error) { return "stored", nil }
+	broken := func(r *http.Request) (string, error) { return "", errors.New("database unavailable") }
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(DefaultHeader, "client-key")
+	if got := serve(t, valid, broken, req); got != http.StatusInternalServerError {
+		t.Errorf("Expected resolver failure to yield 500, got %d", got)
+	}
+	if got := serve(t, failing, stored, req); got != http.StatusInternalServerError {
+		t.Errorf("Expected validation failure to yield 500, got %d", got)
+	}
+}
+
+func TestMiddleware_ClientErrors(t *testing.T) {
+	helper := keyrotation.NewInMemory()
+	encrypted, _ := helper.EncryptApiKey("client-key")
+	resolve := func(r *http.Request) (string, error) { return encrypted, nil }
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(DefaultHeader, strings.Repeat("k", 5000))
+	if got := serve(t, helper, resolve, req); got != http.StatusUnauthorized {
+		t.Errorf("Expected an overlong key to yield 401, got %d", got)
+	}
+
+	for _, err := range []error{keyrotation.ErrEmptyKey, keyrotation.ErrKeyTooLong, keyrotation.ErrUnsafeArgument} {
+		v := validatorFunc(func(ctx context.Context, apiKey, encryptedKey string) (bool, error) {
+			return false, fmt.Errorf("failed to validate API key for today: %w", err)
+		})
+		if got := serve(t, v, resolve, req); got != http.StatusUnauthorized {
+			t.Errorf("Expected %v to yield 401, got %d", err, got)
+		}
+	}
+}
+
+func TestMiddleware_PropagatesRequestContext(t *testing.T) {
+	type ctxKey struct{}
+	var seen any
+	v := validatorFunc(func(ctx context.Context, apiKey, encryptedKey string) (bool, error) {
+		seen = ctx.Value(ctxKey{})
+		return true, nil
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil).WithContext(context.WithValue(context.Background(), ctxKey{}, "request"))
+	req.Header.Set(DefaultHeader, "client-key")
+	serve(t, v, func(r *http.Request) (string, error) { return "stored", nil }, req)
+	if seen != "request" {
+		t.Errorf("Expected validation to use the request context, got %v", seen)
+	}
+}
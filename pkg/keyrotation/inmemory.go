@@ -0,0 +1,208 @@
+package keyrotation
+
+import (
+	"context"
+	"fmt"
+	"hash"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// inMemoryVersion is reported by BinaryVersion for helpers created with NewInMemory
+const inMemoryVersion = "in-memory"
+
+// inMemoryCapabilities is the capabilities subcommand's answer for helpers created with NewInMemory
+const inMemoryCapabilities = "encrypt encrypt-date validate validate-date validate-tolerance validate-tolerance-date " +
+	"version selftest capabilities derive-key encrypt-batch validate-batch encrypt-date-batch validate-date-batch " +
+	"algo=sha256 algo=sha512 hmac interval"
+
+// NewInMemory creates a helper that computes keys in Go instead of calling the binary, for
+// environments where the binary cannot be deployed. Its outputs match the binary's:
+// SHA256(yyyyMMdd + apiKey), hex encoded, or the hash selected with WithAlgorithm and WithPepper
+// over the date string selected with WithRotationInterval.
+func NewInMemory(opts ...Option) *KeyRotationHelper {
+	k, _ := NewWithOptions(opts...)
+	k.engine = newInMemoryEngine(k)
+	// The engine answers like the reference binary whatever tokens were configured for another one
+	k.trueToken, k.falseToken = "true", "false"
+	return k
+}
+
+// inMemoryEngine carries out the binary's subcommands in-process. Failures wrap
+// ErrBinaryExecFailed with the message the binary would print, so callers see the same errors
+// whichever engine is in use.
+type inMemoryEngine struct {
+	algorithm  Algorithm
+	pepper     []byte
+	interval   time.Duration
+	dateLayout string
+	encoding   Encoding
+}
+
+// newInMemoryEngine returns an engine computing keys with k's hash, interval and encodings
+func newInMemoryEngine(k *KeyRotationHelper) inMemoryEngine {
+	return inMemoryEngine{algorithm: k.Algorithm(), pepper: k.pepper, interval: k.RotationInterval(), dateLayout: k.binaryDateLayout, encoding: k.encoding}
+}
+
+func (e inMemoryEngine) do(ctx context.Context, r request) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	sum, err := newHash(e.algorithm, e.pepper)
+	if err != nil {
+		return "", inMemoryError("%v", err)
+	}
+	h := inMemoryHasher{h: sum, interval: e.interval, dateLayout: e.dateLayout, encoding: e.encoding}
+	if r.input != "" {
+		return inMemoryBatch(h, r.command, r.input)
+	}
+	if !r.keyed {
+		switch {
+		case r.command == "version" && len(r.args) == 0:
+			return inMemoryVersion, nil
+		case r.command == "selftest" && len(r.args) == 0:
+			return "ok", nil
+		case r.command == "capabilities" && len(r.args) == 0:
+			return inMemoryCapabilities, nil
+		case r.command == "derive-key" && len(r.args) == 1:
+			date, err := h.parseDate(r.args[0])
+			if err != nil {
+				return "", inMemoryError("%v", err)
+			}
+			return intervalString(date, e.interval), nil
+		}
+		return "", inMemoryError("Unknown command: %s", r.command)
+	}
+	if r.apiKey == "" {
+		return "", inMemoryError("API key cannot be empty")
+	}
+	out, err := inMemoryCommand(h, r.command, r.apiKey, r.args)
+	if err != nil {
+		return "", inMemoryError("%v", err)
+	}
+	return out, nil
+}
+
+// inMemoryError reports a subcommand failure the way the binary's stderr would
+func inMemoryError(format string, args ...any) error {
+	return fmt.Errorf("%w: %s", ErrBinaryExecFailed, fmt.Sprintf(format, args...))
+}
+
+// inMemoryHasher computes keys with the binary's hash and rotation interval, reading date
+// arguments in the layout set with WithBinaryDateLayout and encoding digests as set with
+// WithDigestEncoding
+type inMemoryHasher struct {
+	h          hash.Hash
+	interval   time.Duration
+	dateLayout string
+	encoding   Encoding
+}
+
+// parseDate parses a date argument as the helper formatted it
+func (h inMemoryHasher) parseDate(s string) (time.Time, error) {
+	if h.dateLayout != "" {
+		return time.Parse(h.dateLayout, s)
+	}
+	return parseBinaryDate(s)
+}
+
+// sum is the binary's formula: h(yyyyMMdd + apiKey), hex encoded unless another encoding was
+// selected, with yyyyMMddHH for sub-daily intervals
+func (h inMemoryHasher) sum(apiKey string, date time.Time) string {
+	h.h.Reset()
+	io.WriteString(h.h, intervalString(date, h.interval)+apiKey)
+	return encodeDigest(h.h.Sum(nil), h.encoding)
+}
+
+// inMemoryCommand runs a single-key subcommand against apiKey
+func inMemoryCommand(h inMemoryHasher, command, apiKey string, args []string) (string, error) {
+	today := time.Now().UTC()
+	switch {
+	case command == "encrypt" && len(args) == 0:
+		return h.sum(apiKey, today), nil
+	case command == "encrypt-date" && len(args) == 1:
+		date, err := h.parseDate(args[0])
+		if err != nil {
+			return "", err
+		}
+		return h.sum(apiKey, date), nil
+	case command == "validate" && len(args) == 1:
+		return strconv.FormatBool(SecureCompare(h.sum(apiKey, today), args[0])), nil
+	case command == "validate-date" && len(args) == 2:
+		date, err := h.parseDate(args[1])
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatBool(SecureCompare(h.sum(apiKey, date), args[0])), nil
+	case command == "validate-tolerance" && len(args) == 2:
+		return inMemoryTolerance(h, apiKey, args[0], today, args[1])
+	case command == "validate-tolerance-date" && len(args) == 3:
+		at, err := time.Parse(time.RFC3339, args[1])
+		if err != nil {
+			return "", err
+		}
+		return inMemoryTolerance(h, apiKey, args[0], at, args[2])
+	}
+	return "", fmt.Errorf("Unknown command: %s", command)
+}
+
+// inMemoryTolerance reports whether encrypted matches any rotation date within tolerance minutes
+// either side of t
+func inMemoryTolerance(h inMemoryHasher, apiKey, encrypted string, t time.Time, tolerance string) (string, error) {
+	minutes, err := strconv.Atoi(tolerance)
+	if err != nil {
+		return "", err
+	}
+	for _, date := range toleranceDates(t, minutes, h.interval) {
+		if SecureCompare(h.sum(apiKey, date), encrypted) {
+			return "true", nil
+		}
+	}
+	return "false", nil
+}
+
+// inMemoryBatch answers a batch subcommand with one output line per input line
+func inMemoryBatch(h inMemoryHasher, command, input string) (string, error) {
+	today := time.Now().UTC()
+	lines := strings.Split(strings.TrimSuffix(input, "\n"), "\n")
+	out := make([]string, len(lines))
+	for i, line := range lines {
+		switch command {
+		case "encrypt-batch":
+			if line == "" {
+				out[i] = batchErrorPrefix + "API key cannot be empty"
+				continue
+			}
+			out[i] = h.sum(line, today)
+		case "validate-batch":
+			encrypted, apiKey, ok := strings.Cut(line, "\t")
+			if !ok {
+				out[i] = batchErrorPrefix + "malformed batch line"
+				continue
+			}
+			out[i] = strconv.FormatBool(SecureCompare(h.sum(apiKey, today), encrypted))
+		case "validate-date-batch":
+			day, rest, _ := strings.Cut(line, "\t")
+			encrypted, apiKey, ok := strings.Cut(rest, "\t")
+			date, err := h.parseDate(day)
+			if !ok || err != nil {
+				out[i] = batchErrorPrefix + "malformed batch line"
+				continue
+			}
+			out[i] = strconv.FormatBool(SecureCompare(h.sum(apiKey, date), encrypted))
+		case "encrypt-date-batch":
+			day, apiKey, _ := strings.Cut(line, "\t")
+			date, err := h.parseDate(day)
+			if err != nil {
+				out[i] = batchErrorPrefix + err.Error()
+				continue
+			}
+			out[i] = h.sum(apiKey, date)
+		default:
+			return "", inMemoryError("Unknown command: %s", command)
+		}
+	}
+	return strings.Join(out, "\n"), nil
+}
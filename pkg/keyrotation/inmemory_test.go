@@ -0,0 +1,155 @@
+package keyrotation
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// bundledBinary is the legacy binary shipped at the repository root
+var bundledBinary = filepath.Join("..", "..", "keyrotation-binary")
+
+func TestNewInMemory_MatchesFormula(t *testing.T) {
+	helper := NewInMemory()
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	encrypted, err := helper.EncryptApiKeyWithDate("abc", date)
+	if err != nil {
+		t.Fatalf("EncryptApiKeyWithDate failed: %v", err)
+	}
+	if want := "267392d8b0035e47f02c98d68701371a923a8eee30761fd662a1f8bdcfb8239a"; encrypted != want {
+		t.Errorf("Expected %s, got %s", want, encrypted)
+	}
+
+	valid, err := helper.ValidateApiKey("abc", encrypted, date)
+	if err != nil || !valid {
+		t.Errorf("Expected in-memory key to validate, got %v, %v", valid, err)
+	}
+	if _, err := helper.EncryptApiKey(""); !errors.Is(err, ErrEmptyKey) {
+		t.Errorf("Expected empty key to be rejected, got %v", err)
+	}
+	if err := helper.VerifyBinary(); err != nil {
+		t.Errorf("Expected in-memory selftest to pass, got %v", err)
+	}
+}
+
+// TestNewInMemory_Conformance asserts the in-memory engine agrees with the fake binary and, when
+// it is present, the bundled binary
+func TestNewInMemory_Conformance(t *testing.T) {
+	binaries := map[string]*KeyRotationHelper{"fake": newFakeHelper(t)}
+	if _, err := os.Stat(bundledBinary); err == nil {
+		binaries["bundled"] = NewWithBinaryPath(bundledBinary, WithLegacyArgv(true))
+	}
+
+	inMemory := NewInMemory()
+	apiKeys := []string{"abc", "my-secret-api-key-12345", "ключ with spaces"}
+	dates := []time.Time{
+		time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC),
+		time.Now().UTC(),
+	}
+
+	for name, binary := range binaries {
+		for _, apiKey := range apiKeys {
+			want, err := binary.EncryptApiKey(apiKey)
+			if err != nil {
+				t.Fatalf("%s: EncryptApiKey failed: %v", name, err)
+			}
+			if got, err := inMemory.EncryptApiKey(apiKey); err != nil || got != want {
+				t.Errorf("%s: EncryptApiKey(%q) = %s, %v; binary gave %s", name, apiKey, got, err, want)
+			}
+
+			for _, date := range dates {
+				want, err := binary.EncryptApiKeyWithDate(apiKey, date)
+				if err != nil {
+					t.Fatalf("%s: EncryptApiKeyWithDate failed: %v", name, err)
+				}
+				if got, err := inMemory.EncryptApiKeyWithDate(apiKey, date); err != nil || got != want {
+					t.Errorf("%s: EncryptApiKeyWithDate(%q, %s) = %s, %v; binary gave %s", name, apiKey, date.Format("2006-01-02"), got, err, want)
+				}
+			}
+
+			encrypted, _ := inMemory.EncryptApiKey(apiKey)
+			for _, candidate := range []string{encrypted, wrongCiphertext} {
+				want, err := binary.ValidateApiKeyTodayWithTolerance(apiKey, candidate, 5)
+				if err != nil {
+					t.Fatalf("%s: ValidateApiKeyTodayWithTolerance failed: %v", name, err)
+				}
+				if got, err := inMemory.ValidateApiKeyTodayWithTolerance(apiKey, candidate, 5); err != nil || got != want {
+					t.Errorf("%s: ValidateApiKeyTodayWithTolerance(%q, %q) = %v, %v; binary gave %v", name, apiKey, candidate, got, err, want)
+				}
+			}
+		}
+	}
+}
+
+// TestNewInMemory_DigestEncodingConformance asserts the in-memory engine prints each encoding as
+// a binary emitting it does, and that the bundled binary, when present, agrees on the digest
+func TestNewInMemory_DigestEncodingConformance(t *testing.T) {
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	apiKey := "my-secret-api-key-12345"
+	var bundled string
+	if _, err := os.Stat(bundledBinary); err == nil {
+		var err error
+		if bundled, err = NewWithBinaryPath(bundledBinary, WithLegacyArgv(true)).EncryptApiKeyWithDate(apiKey, date); err != nil {
+			t.Fatalf("bundled: EncryptApiKeyWithDate failed: %v", err)
+		}
+	}
+
+	for _, encoding := range []Encoding{EncodingHex, EncodingHexUpper, EncodingBase64, EncodingBase64URL} {
+		inMemory := NewInMemory(WithDigestEncoding(encoding))
+		got, err := inMemory.EncryptApiKeyWithDate(apiKey, date)
+		if err != nil {
+			t.Fatalf("%s: EncryptApiKeyWithDate failed: %v", encoding, err)
+		}
+
+		t.Setenv(fakeModeEnv, string(encoding))
+		want, err := newFakeHelper(t, WithCiphertextEncoding(encoding)).EncryptApiKeyWithDate(apiKey, date)
+		if err != nil {
+			t.Fatalf("%s: fake EncryptApiKeyWithDate failed: %v", encoding, err)
+		}
+		if got != want {
+			t.Errorf("%s: in-memory gave %s, fake binary gave %s", encoding, got, want)
+		}
+		if bundled != "" && !CiphertextEqual(got, bundled) {
+			t.Errorf("%s: in-memory gave %s, bundled binary gave %s", encoding, got, bundled)
+		}
+
+		// Stored values in any encoding validate, whatever the engine emits
+		for _, stored := range []string{got, fakeHash(apiKey, date), bundled} {
+			if stored == "" {
+				continue
+			}
+			if valid, err := inMemory.ValidateApiKey(apiKey, stored, date); err != nil || !valid {
+				t.Errorf("%s: expected %s to validate, got %v, %v", encoding, stored, valid, err)
+			}
+		}
+	}
+	if _, err := NewWithOptions(WithDigestEncoding("base32")); err == nil {
+		t.Error("Expected an unknown encoding to be rejected")
+	}
+}
+
+func TestNewInMemory_Batch(t *testing.T) {
+	helper := NewInMemory()
+	keys := []string{"a", "", "c"}
+
+	encrypted, err := helper.EncryptApiKeyBatch(keys)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.Index != 1 {
+		t.Fatalf("Expected BatchError at index 1, got %v", err)
+	}
+
+	results, err := helper.ValidateApiKeyBatch([]KeyPair{
+		{ApiKey: "a", EncryptedKey: encrypted[0]},
+		{ApiKey: "c", EncryptedKey: encrypted[0]},
+	})
+	if err != nil {
+		t.Fatalf("ValidateApiKeyBatch failed: %v", err)
+	}
+	if !results[0] || results[1] {
+		t.Errorf("Expected [true false], got %v", results)
+	}
+}
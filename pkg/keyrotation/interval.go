@@ -0,0 +1,125 @@
+package keyrotation
+
+import (
+	"fmt"
+	"time"
+)
+
+// intervalFlag asks the binary to rotate keys every few hours instead of daily. Like algoFlag it
+// precedes the subcommand, and it is only sent for sub-daily intervals so binaries that predate it
+// keep working.
+const intervalFlag = "--interval"
+
+// HourlyStringLayout is the time.Format layout of the date string used for encryption with a
+// sub-daily rotation interval (yyyyMMddHH), where the hour is the start of the interval
+const HourlyStringLayout = "2006010215"
+
+// hourlyDateLayout is the layout of the date argument the binary expects with a sub-daily interval
+const hourlyDateLayout = "2006-01-02T15"
+
+// dailyInterval is the default rotation interval
+const dailyInterval = 24 * time.Hour
+
+// RotationInterval reports how often the helper's keys rotate
+func (k *KeyRotationHelper) RotationInterval() time.Duration {
+	if k.interval == 0 {
+		return dailyInterval
+	}
+	return k.interval
+}
+
+// TimeUntilNextRotation returns how long after now the current rotation interval ends and
+// ciphertexts computed for it stop validating, counting intervals from midnight in the helper's
+// location. Exactly at a boundary, now already belongs to the new interval, so the result is the
+// full length of that interval rather than zero. The result is elapsed time, so a daily interval
+// lasts 23 or 25 hours across a daylight saving change.
+func (k *KeyRotationHelper) TimeUntilNextRotation(now time.Time) time.Duration {
+	return k.nextInterval(now).Sub(now)
+}
+
+// subDaily reports whether keys rotate more often than daily
+func (k *KeyRotationHelper) subDaily() bool {
+	return k.RotationInterval() < dailyInterval
+}
+
+// startOfInterval returns the start of the rotation interval containing t in the helper's location
+func (k *KeyRotationHelper) startOfInterval(t time.Time) time.Time {
+	return floorInterval(t.In(k.location), k.RotationInterval())
+}
+
+// nextInterval returns the start of the rotation interval after the one containing t. The
+// interval's hours are added to the wall clock, so days lengthened or shortened by daylight saving
+// time still advance to the next midnight.
+func (k *KeyRotationHelper) nextInterval(t time.Time) time.Time {
+	return stepInterval(k.startOfInterval(t), k.RotationInterval())
+}
+
+// stepInterval returns the start of the interval after the one starting at start, in start's
+// location, advancing the wall clock like nextInterval
+func stepInterval(start time.Time, interval time.Duration) time.Time {
+	step := int(interval / time.Hour)
+	if step < 1 || step > 24 {
+		step = 24
+	}
+	return floorInterval(time.Date(start.Year(), start.Month(), start.Day(), start.Hour()+step, 0, 0, 0, start.Location()), interval)
+}
+
+// toleranceDates returns the start of the interval containing at, followed by the start of every
+// other interval overlapping the toleranceMinutes either side of it in chronological order. This is
+// the window every tolerance check accepts, whichever engine carries it out.
+func toleranceDates(at time.Time, toleranceMinutes int, interval time.Duration) []time.Time {
+	offset := time.Duration(toleranceMinutes) * time.Minute
+	current := floorInterval(at, interval)
+	dates := []time.Time{current}
+	for date := floorInterval(at.Add(-offset), interval); !date.After(at.Add(offset)); date = stepInterval(date, interval) {
+		if !date.Equal(current) {
+			dates = append(dates, date)
+		}
+	}
+	return dates
+}
+
+// floorInterval returns the start of the interval containing t in t's location. Intervals are
+// whole hours dividing a day, counted from midnight.
+func floorInterval(t time.Time, interval time.Duration) time.Time {
+	step := int(interval / time.Hour)
+	if step < 1 || step > 24 {
+		step = 24
+	}
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour()/step*step, 0, 0, 0, t.Location())
+}
+
+// intervalString formats the interval containing t as the binary hashes it: yyyyMMdd for daily
+// rotation, yyyyMMddHH otherwise
+func intervalString(t time.Time, interval time.Duration) string {
+	if interval > 0 && interval < dailyInterval {
+		return floorInterval(t, interval).Format(HourlyStringLayout)
+	}
+	return t.Format(DateStringLayout)
+}
+
+// parseBinaryDate parses a date argument in either of the default layouts binaryDate produces
+func parseBinaryDate(s string) (time.Time, error) {
+	if len(s) == len(hourlyDateLayout) {
+		return time.Parse(hourlyDateLayout, s)
+	}
+	return time.Parse("2006-01-02", s)
+}
+
+// checkInterval reports whether interval is a supported rotation interval: a whole number of hours
+// that divides a day evenly
+func checkInterval(interval time.Duration) error {
+	if interval <= 0 || interval%time.Hour != 0 || dailyInterval%interval != 0 {
+		return fmt.Errorf("keyrotation: rotation interval %s must be a whole number of hours dividing 24h", interval)
+	}
+	return nil
+}
+
+// requireDaily rejects operations that step through rotation dates one day at a time when keys
+// rotate more often
+func (k *KeyRotationHelper) requireDaily(operation string) error {
+	if k.subDaily() {
+		return fmt.Errorf("%w: %s requires daily rotation, not every %s", ErrUnsupported, operation, k.RotationInterval())
+	}
+	return nil
+}
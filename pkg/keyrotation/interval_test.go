@@ -0,0 +1,120 @@
+package keyrotation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestWithRotationInterval_Hourly(t *testing.T) {
+	at := time.Date(2025, 3, 10, 14, 35, 0, 0, time.UTC)
+	sum := sha256.Sum256([]byte("2025031014testApiKey123"))
+	want := hex.EncodeToString(sum[:])
+
+	for name, helper := range map[string]*KeyRotationHelper{
+		"exec":      newFakeHelper(t, WithRotationInterval(time.Hour)),
+		"in-memory": NewInMemory(WithRotationInterval(time.Hour)),
+	} {
+		encrypted, err := helper.EncryptApiKeyWithDate("testApiKey123", at)
+		if err != nil || encrypted != want {
+			t.Errorf("%s: Expected %s, got %s, %v", name, want, encrypted, err)
+		}
+		if valid, err := helper.ValidateApiKey("testApiKey123", want, at.Add(-30*time.Minute)); err != nil || !valid {
+			t.Errorf("%s: Expected key to validate within its hour, got %v, %v", name, valid, err)
+		}
+		if valid, err := helper.ValidateApiKey("testApiKey123", want, at.Add(30*time.Minute)); err != nil || valid {
+			t.Errorf("%s: Expected key to be rejected in the next hour, got %v, %v", name, valid, err)
+		}
+		if got := helper.GetDateString(at); got != "2025031014" {
+			t.Errorf("%s: Expected date string 2025031014, got %s", name, got)
+		}
+		if got, err := helper.DeriveDailyKey(at); err != nil || got != "2025031014" {
+			t.Errorf("%s: Expected derived key 2025031014, got %s, %v", name, got, err)
+		}
+		encrypted, err = helper.EncryptApiKey("testApiKey123")
+		if err != nil {
+			t.Fatalf("%s: EncryptApiKey failed: %v", name, err)
+		}
+		if valid, err := helper.ValidateApiKeyToday("testApiKey123", encrypted); err != nil || !valid {
+			t.Errorf("%s: Expected the current interval's key to validate, got %v, %v", name, valid, err)
+		}
+	}
+}
+
+func TestWithRotationInterval_Buckets(t *testing.T) {
+	helper := NewInMemory(WithRotationInterval(6 * time.Hour))
+	at := time.Date(2025, 3, 10, 14, 35, 0, 0, time.UTC)
+	if got := helper.GetDateString(at); got != "2025031012" {
+		t.Errorf("Expected the 6-hour interval to start at 12:00, got %s", got)
+	}
+	if got := helper.RotationInterval(); got != 6*time.Hour {
+		t.Errorf("Expected RotationInterval 6h, got %s", got)
+	}
+	if got := NewInMemory().RotationInterval(); got != 24*time.Hour {
+		t.Errorf("Expected the default interval to be a day, got %s", got)
+	}
+
+	// Grace applies at interval boundaries
+	previous, _ := helper.EncryptApiKeyWithDate("testApiKey123", at)
+	if valid, err := helper.validateWithGrace(context.Background(), "testApiKey123", previous, at.Add(3*time.Hour+30*time.Minute), 10*time.Minute); err != nil || !valid {
+		t.Errorf("Expected the previous interval's key within the grace, got %v, %v", valid, err)
+	}
+
+	for _, interval := range []time.Duration{0, -time.Hour, 90 * time.Minute, 5 * time.Hour, 48 * time.Hour} {
+		if _, err := NewWithOptions(WithRotationInterval(interval)); err == nil {
+			t.Errorf("Expected interval %s to be rejected", interval)
+		}
+	}
+}
+
+func TestWithRotationInterval_Unsupported(t *testing.T) {
+	t.Setenv(fakeModeEnv, "argv")
+	if out, err := newFakeHelper(t, WithRotationInterval(time.Hour)).EncryptApiKey("testApiKey123"); err != nil || out != "--interval 1h --stdin encrypt" {
+		t.Errorf("Expected the interval flag before the subcommand, got %q, %v", out, err)
+	}
+
+	t.Setenv(fakeModeEnv, "legacy")
+	helper := newFakeHelper(t, WithRotationInterval(time.Hour))
+	if _, err := helper.EncryptApiKey("testApiKey123"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported from a binary without intervals, got %v", err)
+	}
+
+	if _, err := NewInMemory(WithRotationInterval(time.Hour)).ValidateApiKeyWithinDays("testApiKey123", wrongCiphertext, 2); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected windowed validation to require daily rotation, got %v", err)
+	}
+}
+
+func TestTimeUntilNextRotation(t *testing.T) {
+	bangkok, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		t.Skipf("Time zone database unavailable: %v", err)
+	}
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("Time zone database unavailable: %v", err)
+	}
+	midnight := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+
+	for _, tc := range []struct {
+		name   string
+		helper *KeyRotationHelper
+		now    time.Time
+		want   time.Duration
+	}{
+		{"daily", New(), midnight.Add(23*time.Hour + 59*time.Minute + 30*time.Second), 30 * time.Second},
+		{"daily at the boundary", New(), midnight, 24 * time.Hour},
+		{"six-hourly", New(WithRotationInterval(6 * time.Hour)), midnight.Add(14*time.Hour + 35*time.Minute), 3*time.Hour + 25*time.Minute},
+		{"six-hourly at the boundary", New(WithRotationInterval(6 * time.Hour)), midnight.Add(12 * time.Hour), 6 * time.Hour},
+		// 16:00 UTC is 23:00 in Bangkok
+		{"location", New(WithLocation(bangkok)), midnight.Add(16 * time.Hour), time.Hour},
+		// Clocks in New York skip from 02:00 to 03:00 on 9 March 2025
+		{"daylight saving", New(WithLocation(newYork)), time.Date(2025, 3, 9, 0, 0, 0, 0, newYork), 23 * time.Hour},
+	} {
+		if got := tc.helper.TimeUntilNextRotation(tc.now); got != tc.want {
+			t.Errorf("%s: expected %s, got %s", tc.name, tc.want, got)
+		}
+	}
+}
@@ -0,0 +1,57 @@
+package keyrotation
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEncryptApiKeyJSON(t *testing.T) {
+	now := time.Now()
+	encrypted, err := NewInMemory(WithAlgorithm(AlgorithmSHA512)).EncryptApiKeyWithDate("testApiKey123", now)
+	if err != nil {
+		t.Fatalf("EncryptApiKeyWithDate failed: %v", err)
+	}
+	for name, helper := range map[string]*KeyRotationHelper{
+		"binary":     newFakeHelper(t, WithAlgorithm(AlgorithmSHA512)),
+		"persistent": newPersistentFakeHelper(t, WithAlgorithm(AlgorithmSHA512)),
+	} {
+		info, err := helper.EncryptApiKeyJSON("testApiKey123")
+		if err != nil {
+			t.Fatalf("%s: EncryptApiKeyJSON failed: %v", name, err)
+		}
+		want := EncryptionInfo{
+			Ciphertext: encrypted,
+			Date:       helper.GetDateString(now),
+			Algorithm:  AlgorithmSHA512,
+			Version:    fakeVersion,
+		}
+		if info != want {
+			t.Errorf("%s: Expected %+v, got %+v", name, want, info)
+		}
+	}
+}
+
+func TestEncryptApiKeyJSON_Fallback(t *testing.T) {
+	t.Setenv(fakeModeEnv, "legacy")
+	now := time.Now()
+	info, err := newFakeHelper(t, WithLegacyArgv(true)).EncryptApiKeyJSON("testApiKey123")
+	if err != nil {
+		t.Fatalf("Expected binaries without JSON output to fall back, got %v", err)
+	}
+	want := EncryptionInfo{Ciphertext: fakeHash("testApiKey123", now), Date: GetDateString(now), Algorithm: AlgorithmSHA256}
+	if info != want {
+		t.Errorf("Expected %+v, got %+v", want, info)
+	}
+}
+
+func TestEncryptApiKeyJSON_MalformedOutput(t *testing.T) {
+	helper := newFakeHelper(t)
+	helper.capabilities.caps = &BinaryCapabilities{Reported: true, JSON: true}
+	t.Setenv(fakeModeEnv, "argv")
+	_, err := helper.EncryptApiKeyJSON("testApiKey123")
+	if !errors.Is(err, ErrUnexpectedOutput) || !strings.Contains(err.Error(), jsonFlag) {
+		t.Errorf("Expected ErrUnexpectedOutput quoting the output, got %v", err)
+	}
+}
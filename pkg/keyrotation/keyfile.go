@@ -0,0 +1,26 @@
+package keyrotation
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EncryptApiKeyFromFile reads an API key from the file at path, dropping one trailing line ending,
+// and encrypts it with the current UTC date. Use it for keys provisioned as files, such as CI
+// secrets or systemd credentials: the key never appears in this process's argv, and unless legacy
+// argv mode is enabled it reaches the binary on stdin only.
+func (k *KeyRotationHelper) EncryptApiKeyFromFile(path string) (string, error) {
+	return k.EncryptApiKeyFromFileContext(context.Background(), path)
+}
+
+// EncryptApiKeyFromFileContext is like EncryptApiKeyFromFile but kills the binary if ctx is done first
+func (k *KeyRotationHelper) EncryptApiKeyFromFileContext(ctx context.Context, path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read API key file: %w", err)
+	}
+	apiKey := strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r")
+	return k.EncryptApiKeyContext(ctx, apiKey)
+}
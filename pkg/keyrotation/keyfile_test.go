@@ -0,0 +1,43 @@
+package keyrotation
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeyRotationHelper_EncryptApiKeyFromFile(t *testing.T) {
+	helper := NewInMemory()
+	want, err := helper.EncryptApiKey("testApiKey123")
+	if err != nil {
+		t.Fatalf("EncryptApiKey failed: %v", err)
+	}
+
+	for name, contents := range map[string]string{"plain": "testApiKey123", "lf": "testApiKey123\n", "crlf": "testApiKey123\r\n"} {
+		path := filepath.Join(t.TempDir(), "key")
+		if err := os.WriteFile(path, []byte(contents), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := helper.EncryptApiKeyFromFile(path); err != nil || got != want {
+			t.Errorf("%s: Expected %s, got %s, %v", name, want, got, err)
+		}
+	}
+}
+
+func TestKeyRotationHelper_EncryptApiKeyFromFileErrors(t *testing.T) {
+	helper := NewInMemory()
+
+	if _, err := helper.EncryptApiKeyFromFile(filepath.Join(t.TempDir(), "missing")); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected fs.ErrNotExist for a missing file, got %v", err)
+	}
+
+	empty := filepath.Join(t.TempDir(), "empty")
+	if err := os.WriteFile(empty, []byte("\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := helper.EncryptApiKeyFromFile(empty); !errors.Is(err, ErrEmptyKey) {
+		t.Errorf("Expected ErrEmptyKey for an empty file, got %v", err)
+	}
+}
@@ -1,160 +1,844 @@
 package keyrotation
 
 import (
-	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
-// KeyRotationHelper provides key rotation functionality by calling the private binary
+// DateStringLayout is the time.Format layout of the date string used for encryption (yyyyMMdd)
+const DateStringLayout = "20060102"
+
+// DefaultTimeout is the per-call timeout applied to each binary invocation unless overridden with WithTimeout
+const DefaultTimeout = 10 * time.Second
+
+// BinaryEnvVar names the environment variable consulted for the binary path when none is given
+// explicitly. Precedence is: NewWithBinaryPath or WithBinaryPath, then BinaryEnvVar, then
+// ./keyrotation-binary.
+const BinaryEnvVar = "KEYROTATION_BINARY"
+
+// defaultBinaryPath is the binary used when no path is configured, with defaultBinaryName
+// looked up on PATH as a fallback when it is absent from the working directory
+const (
+	defaultBinaryPath = "./keyrotation-binary"
+	defaultBinaryName = "keyrotation-binary"
+)
+
+// DefaultMaxKeyLength is the longest API key, in bytes, accepted unless overridden with WithMaxKeyLength
+const DefaultMaxKeyLength = 4096
+
+// waitDelay bounds how long a killed invocation may keep us waiting on its output pipes
+const waitDelay = time.Second
+
+// stdinFlag asks the binary to read the plaintext API key from stdin instead of argv.
+// It precedes the subcommand so binaries without stdin support reject it outright
+// rather than hashing the wrong value.
+const stdinFlag = "--stdin"
+
+// stdinLengthFlag is stdinFlag for API keys the line-based protocol cannot carry, those containing
+// line breaks or NUL bytes. The key is framed by its length in bytes, in decimal, and a newline,
+// and is followed by nothing else. Other keys keep using stdinFlag, so binaries without framing
+// support only reject the keys they would otherwise have hashed wrongly.
+const stdinLengthFlag = "--stdin-length"
+
+// needsFraming reports whether apiKey must be sent with stdinLengthFlag
+func needsFraming(apiKey string) bool {
+	return strings.ContainsAny(apiKey, "\r\n\x00")
+}
+
+// KeyRotationHelper provides key rotation functionality by calling the private binary.
+//
+// A KeyRotationHelper is safe for concurrent use by multiple goroutines. Its configuration is
+// fixed at construction and never mutated afterwards; any state that changes while serving
+// calls, such as the process started by NewPersistent, is guarded by its own mutex.
 type KeyRotationHelper struct {
 	binaryPath string
+	timeout    time.Duration
+	legacyArgv bool
+	algorithm  Algorithm
+	maxKeyLen  int
+	extraArgs  []string
+	wrapper    []string
+	workDir    string
+	env        []string
+	dryRun     bool
+	unredacted bool
+	location   *time.Location
+	clock      func() time.Time
+	logger     Logger
+	metrics    MetricsObserver
+	tracer     Tracer
+	stdoutTee  *teeWriter
+	stderrTee  *teeWriter
+
+	// resourceUsage is set by WithResourceUsage
+	resourceUsage bool
+	// resolved is nil unless this is the default helper, which memoizes its binary path
+	resolved *resolvedPath
+	// embedded is nil unless WithEmbeddedBinary is set
+	embedded *embeddedBinary
+
+	// namespace is empty unless WithNamespace is set
+	namespace string
+	// preHashSalt is nil unless WithPreHash is set
+	preHashSalt []byte
+	// pepper is nil unless WithPepper is set
+	pepper []byte
+
+	// earliestDate and maxFutureDays bound encryption dates when non-zero
+	earliestDate  time.Time
+	maxFutureDays int
+
+	// interval is how often keys rotate; zero means daily
+	interval time.Duration
+	// binaryDateLayout is the layout of date arguments set with WithBinaryDateLayout; empty means the default
+	binaryDateLayout string
+
+	retryAttempts int
+	retryBackoff  time.Duration
+	retryJitter   bool
+
+	// windowConcurrency is how many dates windowed validations check at once; below 2 is sequential
+	windowConcurrency int
+
+	cache *validationCache
+
+	// registry holds the keys added with RegisterKeys
+	registry keyRegistry
+	// capabilities caches the answer of Capabilities
+	capabilities capabilitiesCache
+	// loaded holds the ciphertexts read with LoadValidCiphertexts
+	loaded loadedCiphertexts
+
+	// encoding is the ciphertext encoding the binary emits; empty means hex
+	encoding            Encoding
+	skipCiphertextCheck bool
+
+	// trueToken and falseToken are the binary's validation results
+	trueToken  string
+	falseToken string
+
+	// runner is nil unless WithCommandRunner is set
+	runner CommandRunner
+
+	// requiredVersion is nil unless WithRequiredBinaryVersion is set. versionMu guards the outcome
+	// of checking it, which is kept once the binary has answered.
+	requiredVersion *versionConstraint
+	versionMu       sync.Mutex
+	versionChecked  bool
+	versionErr      error
+
+	engine engine
+	closed atomic.Bool
+	// stdinUnsupported is set once the binary rejects stdinFlag, after which keys go on argv
+	stdinUnsupported atomic.Bool
+
+	// configErr is the first error reported by an option, returned from every call
+	configErr error
 }
 
-// New creates a new instance of KeyRotationHelper
-func New() *KeyRotationHelper {
-	return &KeyRotationHelper{
-		binaryPath: "./keyrotation-binary", // Default binary name in current directory
-	}
+// New creates a new instance of KeyRotationHelper using the binary named by BinaryEnvVar,
+// or ./keyrotation-binary if it is unset
+func New(opts ...Option) *KeyRotationHelper {
+	k, _ := NewWithOptions(opts...)
+	return k
 }
 
 // NewWithBinaryPath creates a new instance with custom binary path
-func NewWithBinaryPath(binaryPath string) *KeyRotationHelper {
-	return &KeyRotationHelper{
+func NewWithBinaryPath(binaryPath string, opts ...Option) *KeyRotationHelper {
+	k, _ := NewWithOptions(append([]Option{WithBinaryPath(binaryPath)}, opts...)...)
+	return k
+}
+
+// NewWithOptions creates a new instance configured by opts, applied in order. Without options it
+// is equivalent to New. The returned error reports an invalid option; New and NewWithBinaryPath
+// instead keep such an error on the helper and return it from every call.
+func NewWithOptions(opts ...Option) (*KeyRotationHelper, error) {
+	binaryPath := os.Getenv(BinaryEnvVar)
+	if binaryPath == "" {
+		binaryPath = defaultBinaryPath
+	}
+
+	k := &KeyRotationHelper{
 		binaryPath: binaryPath,
+		timeout:    DefaultTimeout,
+		maxKeyLen:  DefaultMaxKeyLength,
+		trueToken:  "true",
+		falseToken: "false",
+		location:   time.UTC,
+		logger:     nopLogger{},
+	}
+	k.engine = execEngine{k}
+	for _, opt := range opts {
+		opt(k)
+	}
+	k.checkOptions()
+	return k, k.configErr
+}
+
+// NewWithBinaryPathChecked is like NewWithBinaryPath but fails fast if binaryPath does not
+// name an executable file, so misconfiguration surfaces at startup rather than on first use
+func NewWithBinaryPathChecked(binaryPath string, opts ...Option) (*KeyRotationHelper, error) {
+	k, err := NewWithOptions(append([]Option{WithBinaryPath(binaryPath)}, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	path, err := k.resolveBinary()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkBinary(path); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// NewWithBinaryPaths creates a new instance using the first of paths that names an executable file,
+// for deployments where the binary lives in different places per environment. The choice is made
+// once, here, and reused for every call. If no path is usable the error lists why each was rejected.
+func NewWithBinaryPaths(paths ...string) (*KeyRotationHelper, error) {
+	return NewWithOptions(WithBinaryPaths(paths...))
+}
+
+// resolveBinary returns the path of the binary to execute. A bare name without a path separator
+// is looked up on PATH, as a shell would. The default ./keyrotation-binary falls back to
+// keyrotation-binary on PATH when the working directory does not contain it. With WithWorkingDir,
+// relative paths are made absolute so they keep referring to the process's working directory.
+// The default helper remembers a successful resolution, so it searches PATH only once. With
+// WithEmbeddedBinary, the path is that of the extracted image.
+func (k *KeyRotationHelper) resolveBinary() (string, error) {
+	if k.embedded != nil {
+		return k.embedded.extract()
+	}
+	if k.resolved != nil {
+		if path, ok := k.resolved.get(k.binaryPath); ok {
+			return path, nil
+		}
+	}
+	path, err := resolveBinaryPath(k.binaryPath)
+	if err == nil && k.workDir != "" && !filepath.IsAbs(path) {
+		path, err = filepath.Abs(path)
+	}
+	if err == nil && k.resolved != nil {
+		k.resolved.set(k.binaryPath, path)
+	}
+	return path, err
+}
+
+// resolveBinaryPath resolves binaryPath as described for resolveBinary
+func resolveBinaryPath(binaryPath string) (string, error) {
+	path := binaryPath
+	if path == defaultBinaryPath {
+		if _, err := os.Stat(path); err == nil {
+			return path, nil
+		}
+		path = defaultBinaryName
+	}
+	if strings.ContainsRune(path, '/') || strings.ContainsRune(path, filepath.Separator) {
+		return path, nil
+	}
+
+	resolved, err := exec.LookPath(path)
+	if err != nil {
+		if binaryPath == defaultBinaryPath {
+			return "", fmt.Errorf("%w: %s is not in the working directory and %s is not on PATH: %w", ErrBinaryNotFound, defaultBinaryPath, defaultBinaryName, err)
+		}
+		return "", fmt.Errorf("%w: %s is not on PATH: %w", ErrBinaryNotFound, path, err)
+	}
+	return resolved, nil
+}
+
+// checkBinary confirms path exists, is a regular file, and is executable
+func checkBinary(path string) error {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrBinaryNotFound, path)
+	}
+	if err != nil {
+		return fmt.Errorf("keyrotation: cannot stat binary %s: %w", path, err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%w: %s is a directory, not the binary inside it", ErrBinaryNotExecutable, path)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%w: %s is not a regular file (mode %s)", ErrBinaryNotExecutable, path, info.Mode())
+	}
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o111 == 0 {
+		return fmt.Errorf("%w: %s has no execute permission (mode %s)", ErrBinaryNotExecutable, path, info.Mode())
+	}
+	return nil
+}
+
+// commandArgs builds the argv and stdin for r. The API key is written to stdin as a single
+// line unless legacy argv mode is enabled, in which case it becomes the first positional argument.
+// Flags are ordered as the wrapper's own, then any given with WithExtraArgs, then the subcommand.
+func (k *KeyRotationHelper) commandArgs(r request) ([]string, string) {
+	argv, stdin := k.algorithmFlags(), r.input
+	if r.json {
+		argv = append(argv, jsonFlag)
+	}
+	onArgv := r.keyed && k.keyOnArgv(r.apiKey)
+	switch {
+	case r.keyed && !onArgv && needsFraming(r.apiKey):
+		argv, stdin = append(argv, stdinLengthFlag), strconv.Itoa(len(r.apiKey))+"\n"+r.apiKey
+	case r.keyed && !onArgv:
+		argv, stdin = append(argv, stdinFlag), r.apiKey+"\n"
+	}
+	argv = append(append(argv, k.extraArgs...), r.command)
+	if onArgv {
+		argv = append(argv, r.apiKey)
+	}
+	return append(argv, r.args...), stdin
+}
+
+// keyOnArgv reports whether apiKey is passed as a command-line argument: in legacy argv mode, or
+// once the binary has rejected stdinFlag. Keys that need framing stay on stdin in the latter case,
+// so such a binary rejects them rather than receiving them on argv.
+func (k *KeyRotationHelper) keyOnArgv(apiKey string) bool {
+	return k.legacyArgv || (k.stdinUnsupported.Load() && !needsFraming(apiKey))
+}
+
+// isStdinUnsupported reports whether err is a binary rejecting stdinFlag, as releases predating
+// the stdin protocol do
+func isStdinUnsupported(err error) bool {
+	if !isUnknownCommand(err) {
+		return false
+	}
+	msg := err.Error()
+	i := strings.Index(msg, "Unknown command: "+stdinFlag)
+	return i >= 0 && !strings.HasPrefix(msg[i:], "Unknown command: "+stdinLengthFlag)
+}
+
+// run carries out command for apiKey and returns its trimmed output
+func (k *KeyRotationHelper) run(ctx context.Context, command, apiKey string, args ...string) (string, error) {
+	return k.call(ctx, request{command: command, apiKey: apiKey, keyed: true, args: args})
+}
+
+// runKeyless carries out a subcommand that takes no API key and returns its trimmed output
+func (k *KeyRotationHelper) runKeyless(ctx context.Context, command string, args ...string) (string, error) {
+	return k.call(ctx, request{command: command, args: args})
+}
+
+// runValidation carries out a validation subcommand for apiKey, where args[0] is the ciphertext,
+// re-encoded as the binary emits it. Output other than the result tokens, "true" or "false" by
+// default, is an ErrUnexpectedOutput error rather than a failed validation, so a malfunctioning
+// binary is never mistaken for a rejected key.
+func (k *KeyRotationHelper) runValidation(ctx context.Context, command, apiKey string, args ...string) (bool, error) {
+	if err := k.checkCiphertext(args[0]); err != nil {
+		return false, err
+	}
+	// A ciphertext starting with a dash must not reach argv, where it would be parsed as a flag.
+	// Only base64url digests can start with one; anything else of that shape is not a digest.
+	ciphertext := k.binaryCiphertext(args[0])
+	if strings.HasPrefix(ciphertext, "-") {
+		if _, err := k.decodeCiphertext(args[0]); err != nil {
+			return false, nil
+		}
+		return k.validateByEncrypting(ctx, command, apiKey, args...)
+	}
+	args = append([]string{ciphertext}, args[1:]...)
+	result, err := k.run(ctx, command, apiKey, args...)
+	if err != nil {
+		return false, err
+	}
+	if isValid, ok := k.parseValidation(result); ok {
+		return isValid, nil
+	}
+	return false, fmt.Errorf("%w: %s printed %q, expected %s or %s", ErrUnexpectedOutput, command, k.redact(result, apiKey), k.trueToken, k.falseToken)
+}
+
+// validateByEncrypting carries out a validation subcommand without passing the ciphertext args[0]
+// to the binary: it encrypts apiKey for the same date, or each date in the same tolerance window,
+// and compares the result with the ciphertext in Go
+func (k *KeyRotationHelper) validateByEncrypting(ctx context.Context, command, apiKey string, args ...string) (bool, error) {
+	var encrypted string
+	var err error
+	switch command {
+	case "validate":
+		encrypted, err = k.run(ctx, "encrypt", apiKey)
+	case "validate-date":
+		encrypted, err = k.run(ctx, "encrypt-date", apiKey, args[1])
+	case "validate-tolerance", "validate-tolerance-date":
+		at, minutes := k.now(), args[len(args)-1]
+		if command == "validate-tolerance-date" {
+			if at, err = time.Parse(time.RFC3339, args[1]); err != nil {
+				return false, err
+			}
+		}
+		toleranceMinutes, err := strconv.Atoi(minutes)
+		if err != nil {
+			return false, err
+		}
+		return k.emulateTolerance(ctx, apiKey, args[0], at, toleranceMinutes)
+	default:
+		return false, fmt.Errorf("keyrotation: %s cannot be validated by encrypting", command)
+	}
+	if err != nil {
+		return false, err
+	}
+	return CiphertextEqual(encrypted, args[0]), nil
+}
+
+// parseValidation interprets a validation result, accepting the result tokens in any case
+func (k *KeyRotationHelper) parseValidation(out string) (isValid, ok bool) {
+	switch {
+	case strings.EqualFold(out, k.trueToken):
+		return true, true
+	case strings.EqualFold(out, k.falseToken):
+		return false, true
 	}
+	return false, false
+}
+
+// normalizeOutput trims the binary's output of surrounding whitespace, including the CRLF line
+// endings written on Windows, and of the UTF-8 byte order mark some platforms prefix it with
+func normalizeOutput(out string) string {
+	return strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(out), "\uFEFF"))
+}
+
+// invoke executes the binary for r through the command runner and returns its trimmed stdout.
+// The process is killed if ctx is done or the configured timeout elapses before it exits.
+func (k *KeyRotationHelper) invoke(ctx context.Context, r request) (string, error) {
+	// A custom runner receives the path as configured, since it may not run the binary on this host
+	binaryPath := k.binaryPath
+	if k.runner == nil {
+		var err error
+		if binaryPath, err = k.resolveBinary(); err != nil {
+			return "", err
+		}
+	}
+	argv, stdin := k.commandArgs(r)
+
+	runCtx := ctx
+	if k.timeout > 0 {
+		var cancel context.CancelFunc
+		runCtx, cancel = context.WithTimeout(ctx, k.timeout)
+		defer cancel()
+	}
+
+	name, argv := wrapCommand(k.wrapper, binaryPath, argv)
+	stdout, stderr := getBuffer(), getBuffer()
+	defer putBuffer(stdout)
+	defer putBuffer(stderr)
+	err := k.runCommand(runCtx, name, argv, strings.NewReader(stdin), stdout, stderr)
+	if err != nil {
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", ctxErr
+		}
+		command := append([]string{name}, k.redactArgs(argv, r)...)
+		if runCtx.Err() == context.DeadlineExceeded {
+			return "", fmt.Errorf("%w after %s running %s: %w", ErrTimeout, k.timeout, shellJoin(command), context.DeadlineExceeded)
+		}
+		var exitErr exitCoder
+		if errors.As(err, &exitErr) {
+			return "", &BinaryError{
+				Command:  command,
+				ExitCode: exitErr.ExitCode(),
+				Signal:   exitSignal(err),
+				Stderr:   strings.TrimSpace(stderr.String()),
+				Err:      err,
+			}
+		}
+		if k.runner != nil {
+			return "", startError("", err)
+		}
+		return "", startError(name, err)
+	}
+
+	return normalizeOutput(stdout.String()), nil
+}
+
+// startError classifies a failure to start the program at path. Failures other than a missing
+// file are checked against the file itself, so a path naming a directory or a file without execute
+// permission is reported as such rather than as the kernel's terse error. An empty path skips the
+// check, for custom runners that may not run the program on this host.
+func startError(path string, err error) error {
+	if errors.Is(err, exec.ErrNotFound) || errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %w", ErrBinaryNotFound, err)
+	}
+	if path != "" {
+		if checkErr := checkBinary(path); errors.Is(checkErr, ErrBinaryNotExecutable) {
+			return fmt.Errorf("%w: %w", checkErr, err)
+		}
+	}
+	return fmt.Errorf("%w: %w", ErrBinaryExecFailed, err)
+}
+
+// binaryDate formats the rotation date of t, in the helper's location, as the binary expects it:
+// yyyy-mm-dd, or the start of the interval as yyyy-mm-ddTHH when keys rotate more often than daily,
+// unless another layout was set with WithBinaryDateLayout
+func (k *KeyRotationHelper) binaryDate(t time.Time) string {
+	if k.binaryDateLayout != "" {
+		return k.startOfInterval(t).Format(k.binaryDateLayout)
+	}
+	if k.subDaily() {
+		return k.startOfInterval(t).Format(hourlyDateLayout)
+	}
+	return t.In(k.location).Format("2006-01-02")
+}
+
+// checkKey rejects API keys that are empty or longer than the helper's maximum before they reach the binary
+func (k *KeyRotationHelper) checkKey(apiKey string) error {
+	if apiKey == "" {
+		return ErrEmptyKey
+	}
+	if len(apiKey) > k.maxKeyLen {
+		return fmt.Errorf("%w: %d bytes exceeds the limit of %d", ErrKeyTooLong, len(apiKey), k.maxKeyLen)
+	}
+	return nil
+}
+
+// startOfDay returns midnight of t's rotation date in the helper's location
+func (k *KeyRotationHelper) startOfDay(t time.Time) time.Time {
+	t = t.In(k.location)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, k.location)
+}
+
+// binaryClock reports whether "today" can be left to the binary, which only knows the UTC date
+// and its own clock. Otherwise the wrapper computes the date itself and uses the explicit-date
+// subcommands.
+func (k *KeyRotationHelper) binaryClock() bool {
+	return k.location == time.UTC && k.clock == nil
+}
+
+// now returns the current time from the clock set with WithClock, or the system clock
+func (k *KeyRotationHelper) now() time.Time {
+	if k.clock != nil {
+		return k.clock()
+	}
+	return time.Now()
+}
+
+// isUnknownCommand reports whether err is the binary rejecting a subcommand or flag it does not recognize
+func isUnknownCommand(err error) bool {
+	return errors.Is(err, ErrBinaryExecFailed) && !errors.Is(err, ErrUnsupported) && strings.Contains(err.Error(), "Unknown command")
+}
+
+// Close releases the helper's resources: it shuts down the binary process started by NewPersistent,
+// removes the binary extracted for WithEmbeddedBinary once no open clone shares it and discards any
+// cached validation results, registered keys and loaded ciphertexts. It is safe to call more than
+// once. The helper must not be used after Close; calls made afterwards fail with ErrClosed.
+func (k *KeyRotationHelper) Close() error {
+	if k.closed.Swap(true) {
+		return nil
+	}
+	if k.cache != nil {
+		k.cache.clear()
+	}
+	k.clearRegistry()
+	k.clearLoaded()
+	var err error
+	if c, ok := k.engine.(interface{ close() error }); ok {
+		err = c.close()
+	}
+	if k.embedded != nil {
+		err = errors.Join(err, k.embedded.release())
+	}
+	return err
 }
 
 // EncryptApiKey encrypts an API key using SHA256 with the current UTC date
 func (k *KeyRotationHelper) EncryptApiKey(apiKey string) (string, error) {
-	cmd := exec.Command(k.binaryPath, "encrypt", apiKey)
-	var out bytes.Buffer
-	cmd.Stdout = &out
+	return k.EncryptApiKeyContext(context.Background(), apiKey)
+}
 
-	err := cmd.Run()
+// EncryptApiKeyContext is like EncryptApiKey but kills the binary if ctx is done first
+func (k *KeyRotationHelper) EncryptApiKeyContext(ctx context.Context, apiKey string) (string, error) {
+	command, args := "encrypt", []string(nil)
+	if !k.binaryClock() {
+		command, args = "encrypt-date", []string{k.binaryDate(k.now())}
+	}
+	result, err := k.run(ctx, command, apiKey, args...)
+	if err == nil && result == "" {
+		err = fmt.Errorf("%w: empty ciphertext", ErrUnexpectedOutput)
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to encrypt API key: %v", err)
+		return "", fmt.Errorf("failed to encrypt API key: %w", err)
 	}
 
-	return strings.TrimSpace(out.String()), nil
+	return result, nil
 }
 
 // EncryptApiKeyWithDate encrypts an API key using SHA256 with a specific UTC date
 func (k *KeyRotationHelper) EncryptApiKeyWithDate(apiKey string, utcDateTime time.Time) (string, error) {
-	dateStr := utcDateTime.Format("2006-01-02")
-	cmd := exec.Command(k.binaryPath, "encrypt-date", apiKey, dateStr)
-	var out bytes.Buffer
-	cmd.Stdout = &out
+	return k.EncryptApiKeyWithDateContext(context.Background(), apiKey, utcDateTime)
+}
 
-	err := cmd.Run()
+// EncryptApiKeyWithDateContext is like EncryptApiKeyWithDate but kills the binary if ctx is done first
+func (k *KeyRotationHelper) EncryptApiKeyWithDateContext(ctx context.Context, apiKey string, utcDateTime time.Time) (string, error) {
+	if err := k.checkDate(utcDateTime); err != nil {
+		return "", fmt.Errorf("failed to encrypt API key with date: %w", err)
+	}
+	return k.encryptWithDate(ctx, apiKey, utcDateTime)
+}
+
+// encryptWithDate encrypts apiKey for the date of utcDateTime without checking it against the date bounds
+func (k *KeyRotationHelper) encryptWithDate(ctx context.Context, apiKey string, utcDateTime time.Time) (string, error) {
+	result, err := k.run(ctx, "encrypt-date", apiKey, k.binaryDate(utcDateTime))
+	if err == nil && result == "" {
+		err = fmt.Errorf("%w: empty ciphertext", ErrUnexpectedOutput)
+	}
 	if err != nil {
-		return "", fmt.Errorf("failed to encrypt API key with date: %v", err)
+		return "", fmt.Errorf("failed to encrypt API key with date: %w", err)
 	}
 
-	return strings.TrimSpace(out.String()), nil
+	return result, nil
 }
 
 // ValidateApiKey validates if an encrypted API key matches the expected hash for a given date
 func (k *KeyRotationHelper) ValidateApiKey(apiKey, encryptedKey string, utcDateTime time.Time) (bool, error) {
-	dateStr := utcDateTime.Format("2006-01-02")
-	cmd := exec.Command(k.binaryPath, "validate-date", apiKey, encryptedKey, dateStr)
-	var out bytes.Buffer
-	cmd.Stdout = &out
+	return k.ValidateApiKeyContext(context.Background(), apiKey, encryptedKey, utcDateTime)
+}
 
-	err := cmd.Run()
-	if err != nil {
-		return false, fmt.Errorf("failed to validate API key: %v", err)
-	}
+// ValidateApiKeyContext is like ValidateApiKey but kills the binary if ctx is done first
+func (k *KeyRotationHelper) ValidateApiKeyContext(ctx context.Context, apiKey, encryptedKey string, utcDateTime time.Time) (bool, error) {
+	return k.cachedValidation(apiKey, encryptedKey, utcDateTime, func() (bool, error) {
+		isValid, err := k.runValidation(ctx, "validate-date", apiKey, encryptedKey, k.binaryDate(utcDateTime))
+		if err != nil {
+			return false, fmt.Errorf("failed to validate API key: %w", err)
+		}
 
-	result := strings.TrimSpace(out.String())
-	return result == "true", nil
+		return isValid, nil
+	})
 }
 
-// ValidateApiKeyWithTolerance validates if an encrypted API key matches the expected hash for a given date with time tolerance
+// ValidateApiKeyWithTolerance validates if an encrypted API key matches the expected hash for a given date with time tolerance.
+// The key is accepted if it matches the date of any instant within toleranceMinutes of utcDateTime.
+// On binaries whose Capabilities lack the validate-tolerance-date subcommand, the key is encrypted
+// for each rotation date in the window and compared in Go instead, for tolerances of up to a week.
 func (k *KeyRotationHelper) ValidateApiKeyWithTolerance(apiKey, encryptedKey string, utcDateTime time.Time, toleranceMinutes int) (bool, error) {
-	// For now, we'll use the base validation since the binary doesn't support tolerance with specific date
-	// In a real implementation, you might want to add this functionality to the binary
-	return k.ValidateApiKey(apiKey, encryptedKey, utcDateTime)
+	return k.ValidateApiKeyWithToleranceContext(context.Background(), apiKey, encryptedKey, utcDateTime, toleranceMinutes)
+}
+
+// ValidateApiKeyWithToleranceContext is like ValidateApiKeyWithTolerance but kills the binary if ctx is done first
+func (k *KeyRotationHelper) ValidateApiKeyWithToleranceContext(ctx context.Context, apiKey, encryptedKey string, utcDateTime time.Time, toleranceMinutes int) (bool, error) {
+	if err := checkTolerance(toleranceMinutes); err != nil {
+		return false, fmt.Errorf("failed to validate API key with tolerance: %w", err)
+	}
+
+	caps, err := k.Capabilities(ctx)
+	if err != nil {
+		return false, fmt.Errorf("failed to validate API key with tolerance: %w", err)
+	}
+
+	var isValid bool
+	if caps.Supports("validate-tolerance-date") {
+		// The binary takes dates in the UTC offset of the timestamp it is given
+		at := utcDateTime.In(k.location).Format(time.RFC3339)
+		isValid, err = k.runValidation(ctx, "validate-tolerance-date", apiKey, encryptedKey, at, strconv.Itoa(toleranceMinutes))
+	} else {
+		isValid, err = k.emulateTolerance(ctx, apiKey, encryptedKey, utcDateTime, toleranceMinutes)
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to validate API key with tolerance: %w", err)
+	}
+
+	return isValid, nil
 }
 
 // ValidateApiKeyToday validates if an encrypted API key matches the expected hash for today (UTC)
 func (k *KeyRotationHelper) ValidateApiKeyToday(apiKey, encryptedKey string) (bool, error) {
-	cmd := exec.Command(k.binaryPath, "validate", apiKey, encryptedKey)
-	var out bytes.Buffer
-	cmd.Stdout = &out
+	return k.ValidateApiKeyTodayContext(context.Background(), apiKey, encryptedKey)
+}
 
-	err := cmd.Run()
-	if err != nil {
-		return false, fmt.Errorf("failed to validate API key for today: %v", err)
+// ValidateApiKeyTodayContext is like ValidateApiKeyToday but kills the binary if ctx is done first
+func (k *KeyRotationHelper) ValidateApiKeyTodayContext(ctx context.Context, apiKey, encryptedKey string) (bool, error) {
+	now := k.now()
+	if isValid, ok, err := k.registeredValidation(ctx, apiKey, encryptedKey, now); ok {
+		if err != nil {
+			return false, fmt.Errorf("failed to validate API key for today: %w", err)
+		}
+		return isValid, nil
 	}
+	return k.cachedValidation(apiKey, encryptedKey, now, func() (bool, error) {
+		command, args := "validate", []string{encryptedKey}
+		if !k.binaryClock() {
+			command, args = "validate-date", []string{encryptedKey, k.binaryDate(now)}
+		}
+		isValid, err := k.runValidation(ctx, command, apiKey, args...)
+		if err != nil {
+			return false, fmt.Errorf("failed to validate API key for today: %w", err)
+		}
+
+		return isValid, nil
+	})
+}
+
+// ValidateApiKeyYesterday validates if an encrypted API key matches the expected hash for the day
+// before today, in the helper's location, for clients whose clocks lag across midnight
+func (k *KeyRotationHelper) ValidateApiKeyYesterday(apiKey, encryptedKey string) (bool, error) {
+	return k.ValidateApiKeyYesterdayContext(context.Background(), apiKey, encryptedKey)
+}
+
+// ValidateApiKeyYesterdayContext is like ValidateApiKeyYesterday but kills the binary if ctx is done first
+func (k *KeyRotationHelper) ValidateApiKeyYesterdayContext(ctx context.Context, apiKey, encryptedKey string) (bool, error) {
+	return k.ValidateApiKeyContext(ctx, apiKey, encryptedKey, k.now().In(k.location).AddDate(0, 0, -1))
+}
+
+// ValidateApiKeyTomorrow validates if an encrypted API key matches the expected hash for the day
+// after today, in the helper's location, for clients whose clocks run ahead across midnight
+func (k *KeyRotationHelper) ValidateApiKeyTomorrow(apiKey, encryptedKey string) (bool, error) {
+	return k.ValidateApiKeyTomorrowContext(context.Background(), apiKey, encryptedKey)
+}
+
+// ValidateApiKeyTomorrowContext is like ValidateApiKeyTomorrow but kills the binary if ctx is done first
+func (k *KeyRotationHelper) ValidateApiKeyTomorrowContext(ctx context.Context, apiKey, encryptedKey string) (bool, error) {
+	return k.ValidateApiKeyContext(ctx, apiKey, encryptedKey, k.now().In(k.location).AddDate(0, 0, 1))
+}
+
+// ValidateApiKeyTodaySoft is like ValidateApiKeyToday but fails closed instead of returning an
+// error: any failure, including a missing binary or a malformed ciphertext, yields false. The
+// error is reported to the configured Logger as an Event with Operation "validate-soft". Callers
+// give up the ability to tell a wrong key from an outage, so use it only where both must deny.
+func (k *KeyRotationHelper) ValidateApiKeyTodaySoft(apiKey, encryptedKey string) bool {
+	return k.ValidateApiKeyTodaySoftContext(context.Background(), apiKey, encryptedKey)
+}
 
-	result := strings.TrimSpace(out.String())
-	return result == "true", nil
+// ValidateApiKeyTodaySoftContext is like ValidateApiKeyTodaySoft but kills the binary if ctx is done first
+func (k *KeyRotationHelper) ValidateApiKeyTodaySoftContext(ctx context.Context, apiKey, encryptedKey string) bool {
+	isValid, err := k.ValidateApiKeyTodayContext(ctx, apiKey, encryptedKey)
+	if err != nil {
+		k.logger.LogEvent(Event{Operation: "validate-soft", Err: err, ExitCode: exitCode(err)})
+		return false
+	}
+	return isValid
 }
 
 // ValidateApiKeyTodayWithTolerance validates if an encrypted API key matches the expected hash for today (UTC) with time tolerance
 func (k *KeyRotationHelper) ValidateApiKeyTodayWithTolerance(apiKey, encryptedKey string, toleranceMinutes int) (bool, error) {
-	cmd := exec.Command(k.binaryPath, "validate-tolerance", apiKey, encryptedKey, strconv.Itoa(toleranceMinutes))
-	var out bytes.Buffer
-	cmd.Stdout = &out
+	return k.ValidateApiKeyTodayWithToleranceContext(context.Background(), apiKey, encryptedKey, toleranceMinutes)
+}
 
-	err := cmd.Run()
+// ValidateApiKeyTodayWithToleranceContext is like ValidateApiKeyTodayWithTolerance but kills the binary if ctx is done first
+func (k *KeyRotationHelper) ValidateApiKeyTodayWithToleranceContext(ctx context.Context, apiKey, encryptedKey string, toleranceMinutes int) (bool, error) {
+	if err := checkTolerance(toleranceMinutes); err != nil {
+		return false, fmt.Errorf("failed to validate API key with tolerance: %w", err)
+	}
+	if !k.binaryClock() {
+		return k.ValidateApiKeyWithToleranceContext(ctx, apiKey, encryptedKey, k.now(), toleranceMinutes)
+	}
+
+	isValid, err := k.runValidation(ctx, "validate-tolerance", apiKey, encryptedKey, strconv.Itoa(toleranceMinutes))
 	if err != nil {
-		return false, fmt.Errorf("failed to validate API key with tolerance: %v", err)
+		return false, fmt.Errorf("failed to validate API key with tolerance: %w", err)
 	}
 
-	result := strings.TrimSpace(out.String())
-	return result == "true", nil
+	return isValid, nil
 }
 
-// GetDateString gets the date string format used for encryption (yyyyMMdd)
+// GetDateString gets the date string format used for encryption (yyyyMMdd), or yyyyMMddHH for the
+// start of the interval with a sub-daily WithRotationInterval
 func (k *KeyRotationHelper) GetDateString(utcDateTime time.Time) string {
-	return utcDateTime.Format("20060102")
+	if k.subDaily() {
+		return k.GetDateStringWithLayout(k.startOfInterval(utcDateTime), HourlyStringLayout)
+	}
+	return k.GetDateStringWithLayout(utcDateTime, DateStringLayout)
+}
+
+// GetDateStringWithLayout formats the rotation date of utcDateTime using a custom time.Format layout.
+// The date is taken in the helper's location.
+func (k *KeyRotationHelper) GetDateStringWithLayout(utcDateTime time.Time, layout string) string {
+	return utcDateTime.In(k.location).Format(layout)
+}
+
+// ParseDateString parses a date string in DateStringLayout (yyyyMMdd), as produced by GetDateString,
+// and returns midnight of that date in the helper's location
+func (k *KeyRotationHelper) ParseDateString(s string) (time.Time, error) {
+	if len(s) != len(DateStringLayout) {
+		return time.Time{}, fmt.Errorf("keyrotation: date string %q must be %d digits (yyyyMMdd)", s, len(DateStringLayout))
+	}
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return time.Time{}, fmt.Errorf("keyrotation: date string %q contains non-digit %q", s, c)
+		}
+	}
+	date, err := time.ParseInLocation(DateStringLayout, s, k.location)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("keyrotation: invalid date string %q: %w", s, err)
+	}
+	return date, nil
 }
 
 // Package-level convenience functions
 
 // EncryptApiKey encrypts an API key using SHA256 with the current UTC date
 func EncryptApiKey(apiKey string) (string, error) {
-	helper := New()
+	helper := Default()
 	return helper.EncryptApiKey(apiKey)
 }
 
 // EncryptApiKeyWithDate encrypts an API key using SHA256 with a specific UTC date
 func EncryptApiKeyWithDate(apiKey string, utcDateTime time.Time) (string, error) {
-	helper := New()
+	helper := Default()
 	return helper.EncryptApiKeyWithDate(apiKey, utcDateTime)
 }
 
 // ValidateApiKey validates if an encrypted API key matches the expected hash for a given date
 func ValidateApiKey(apiKey, encryptedKey string, utcDateTime time.Time) (bool, error) {
-	helper := New()
+	helper := Default()
 	return helper.ValidateApiKey(apiKey, encryptedKey, utcDateTime)
 }
 
 // ValidateApiKeyWithTolerance validates if an encrypted API key matches the expected hash for a given date with time tolerance
 func ValidateApiKeyWithTolerance(apiKey, encryptedKey string, utcDateTime time.Time, toleranceMinutes int) (bool, error) {
-	helper := New()
+	helper := Default()
 	return helper.ValidateApiKeyWithTolerance(apiKey, encryptedKey, utcDateTime, toleranceMinutes)
 }
 
 // ValidateApiKeyToday validates if an encrypted API key matches the expected hash for today (UTC)
 func ValidateApiKeyToday(apiKey, encryptedKey string) (bool, error) {
-	helper := New()
+	helper := Default()
 	return helper.ValidateApiKeyToday(apiKey, encryptedKey)
 }
 
+// ValidateApiKeyYesterday validates if an encrypted API key matches the expected hash for yesterday (UTC)
+func ValidateApiKeyYesterday(apiKey, encryptedKey string) (bool, error) {
+	helper := Default()
+	return helper.ValidateApiKeyYesterday(apiKey, encryptedKey)
+}
+
+// ValidateApiKeyTomorrow validates if an encrypted API key matches the expected hash for tomorrow (UTC)
+func ValidateApiKeyTomorrow(apiKey, encryptedKey string) (bool, error) {
+	helper := Default()
+	return helper.ValidateApiKeyTomorrow(apiKey, encryptedKey)
+}
+
+// ValidateApiKeyTodaySoft validates an API key for today (UTC), returning false on any error
+func ValidateApiKeyTodaySoft(apiKey, encryptedKey string) bool {
+	helper := Default()
+	return helper.ValidateApiKeyTodaySoft(apiKey, encryptedKey)
+}
+
 // ValidateApiKeyTodayWithTolerance validates if an encrypted API key matches the expected hash for today (UTC) with time tolerance
 func ValidateApiKeyTodayWithTolerance(apiKey, encryptedKey string, toleranceMinutes int) (bool, error) {
-	helper := New()
+	helper := Default()
 	return helper.ValidateApiKeyTodayWithTolerance(apiKey, encryptedKey, toleranceMinutes)
 }
 
 // GetDateString gets the date string format used for encryption (yyyyMMdd)
 func GetDateString(utcDateTime time.Time) string {
-	helper := New()
+	helper := Default()
 	return helper.GetDateString(utcDateTime)
 }
+
+// GetDateStringWithLayout formats the rotation date of utcDateTime using a custom time.Format layout
+func GetDateStringWithLayout(utcDateTime time.Time, layout string) string {
+	helper := Default()
+	return helper.GetDateStringWithLayout(utcDateTime, layout)
+}
+
+// ParseDateString parses a date string in DateStringLayout (yyyyMMdd) and returns midnight UTC of that date
+func ParseDateString(s string) (time.Time, error) {
+	helper := Default()
+	return helper.ParseDateString(s)
+}
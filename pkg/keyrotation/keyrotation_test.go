@@ -1,8 +1,15 @@
 package keyrotation
 
 import (
+	"context"
+	"errors"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
 	"testing"
 	"time"
 )
@@ -125,6 +132,689 @@ func TestKeyRotationHelper_GetDateString(t *testing.T) {
 	}
 }
 
+func TestKeyRotationHelper_Timeout(t *testing.T) {
+	t.Setenv(fakeModeEnv, "hang")
+	helper := newFakeHelper(t, WithTimeout(100*time.Millisecond))
+
+	start := time.Now()
+	_, err := helper.EncryptApiKey("testApiKey123")
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Expected ErrTimeout, got %v", err)
+	}
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected error to wrap context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected hung binary to be killed promptly, took %s", elapsed)
+	}
+}
+
+func TestKeyRotationHelper_ContextCanceled(t *testing.T) {
+	t.Setenv(fakeModeEnv, "hang")
+	helper := newFakeHelper(t, WithTimeout(0))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err := helper.ValidateApiKeyTodayContext(ctx, "testApiKey123", wrongCiphertext)
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if errors.Is(err, ErrTimeout) {
+		t.Error("Caller deadline should not be reported as the helper timeout")
+	}
+}
+
+func TestKeyRotationHelper_ToleranceContextCanceled(t *testing.T) {
+	t.Setenv(fakeModeEnv, "hang")
+	helper := newFakeHelper(t, WithTimeout(0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := helper.ValidateApiKeyTodayWithToleranceContext(ctx, "testApiKey123", wrongCiphertext, 5); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from today's tolerance check, got %v", err)
+	}
+	if _, err := helper.ValidateApiKeyWithToleranceContext(ctx, "testApiKey123", wrongCiphertext, time.Now(), 5); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled from dated tolerance check, got %v", err)
+	}
+}
+
+func TestKeyRotationHelper_DefaultTimeout(t *testing.T) {
+	if helper := New(); helper.timeout != DefaultTimeout {
+		t.Errorf("Expected default timeout %s, got %s", DefaultTimeout, helper.timeout)
+	}
+	if helper := New(WithTimeout(0)); helper.timeout != 0 {
+		t.Errorf("Expected zero timeout, got %s", helper.timeout)
+	}
+}
+
+func TestKeyRotationHelper_FakeBinaryRoundTrip(t *testing.T) {
+	helper := newFakeHelper(t)
+	testApiKey := "testApiKey123"
+	testDate := time.Date(2024, 1, 15, 12, 30, 0, 0, time.UTC)
+
+	encrypted, err := helper.EncryptApiKeyWithDate(testApiKey, testDate)
+	if err != nil {
+		t.Fatalf("EncryptApiKeyWithDate failed: %v", err)
+	}
+	if encrypted != fakeHash(testApiKey, testDate) {
+		t.Errorf("Expected %s, got %s", fakeHash(testApiKey, testDate), encrypted)
+	}
+
+	isValid, err := helper.ValidateApiKey(testApiKey, encrypted, testDate)
+	if err != nil {
+		t.Fatalf("ValidateApiKey failed: %v", err)
+	}
+	if !isValid {
+		t.Error("Expected validation to succeed")
+	}
+}
+
+func TestKeyRotationHelper_KeyNotOnArgv(t *testing.T) {
+	t.Setenv(fakeModeEnv, "argv")
+	helper := newFakeHelper(t)
+	testApiKey := "testApiKey123"
+
+	argv, err := helper.EncryptApiKey(testApiKey)
+	if err != nil {
+		t.Fatalf("EncryptApiKey failed: %v", err)
+	}
+	if strings.Contains(argv, testApiKey) {
+		t.Errorf("API key leaked onto argv: %q", argv)
+	}
+	if argv != "--stdin encrypt" {
+		t.Errorf("Expected %q, got %q", "--stdin encrypt", argv)
+	}
+}
+
+func TestKeyRotationHelper_LegacyArgv(t *testing.T) {
+	t.Setenv(fakeModeEnv, "legacy")
+	testApiKey := "testApiKey123"
+
+	// A binary rejecting --stdin is retried with the key on argv, and keeps getting it there
+	recorder := &eventRecorder{}
+	fallback := newFakeHelper(t, WithLogger(recorder))
+	for range 2 {
+		if encrypted, err := fallback.EncryptApiKey(testApiKey); err != nil || encrypted != fakeHash(testApiKey, time.Now().UTC()) {
+			t.Errorf("Expected a binary without stdin support to get the key on argv, got %s, %v", encrypted, err)
+		}
+	}
+	if got := len(recorder.recorded()); got != 2 {
+		t.Errorf("Expected one event per call, got %d", got)
+	}
+	if !fallback.stdinUnsupported.Load() {
+		t.Error("Expected the helper to remember that the binary lacks stdin support")
+	}
+	if _, err := fallback.EncryptApiKey("-dash-key"); !errors.Is(err, ErrUnsafeArgument) {
+		t.Errorf("Expected a key starting with a dash to be kept off argv, got %v", err)
+	}
+	if _, err := fallback.EncryptApiKey("first\nsecond"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected a key needing framing to fail with ErrUnsupported, got %v", err)
+	}
+
+	encrypted, err := newFakeHelper(t, WithLegacyArgv(true)).EncryptApiKey(testApiKey)
+	if err != nil {
+		t.Fatalf("EncryptApiKey failed: %v", err)
+	}
+	if encrypted != fakeHash(testApiKey, time.Now().UTC()) {
+		t.Errorf("Unexpected legacy result %s", encrypted)
+	}
+}
+
+func TestKeyRotationHelper_BinaryError(t *testing.T) {
+	helper := newFakeHelper(t)
+
+	_, err := helper.run(context.Background(), "no-such-command", "testApiKey123")
+	var binErr *BinaryError
+	if !errors.As(err, &binErr) {
+		t.Fatalf("Expected *BinaryError, got %T: %v", err, err)
+	}
+	if binErr.ExitCode != 1 {
+		t.Errorf("Expected exit code 1, got %d", binErr.ExitCode)
+	}
+	if binErr.Stderr != "Unknown command: no-such-command" {
+		t.Errorf("Unexpected stderr %q", binErr.Stderr)
+	}
+	if got := binErr.Command[1:]; strings.Join(got, " ") != "--stdin no-such-command" {
+		t.Errorf("Unexpected command %q", got)
+	}
+	if !strings.Contains(err.Error(), "Unknown command: no-such-command") {
+		t.Errorf("Expected stderr in error message, got %q", err.Error())
+	}
+}
+
+func TestKeyRotationHelper_BinaryKilled(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("processes are not terminated by signals on Windows")
+	}
+	t.Setenv(fakeModeEnv, "killed")
+
+	_, err := newFakeHelper(t).EncryptApiKey("testApiKey123")
+	var binErr *BinaryError
+	if !errors.Is(err, ErrBinaryKilled) || !errors.As(err, &binErr) {
+		t.Fatalf("Expected ErrBinaryKilled, got %v", err)
+	}
+	if binErr.Signal != syscall.SIGKILL || !errors.Is(err, ErrBinaryExecFailed) {
+		t.Errorf("Expected SIGKILL, got %d", binErr.Signal)
+	}
+	if !strings.Contains(err.Error(), "killed by signal 9") {
+		t.Errorf("Expected the signal in the error message, got %q", err.Error())
+	}
+
+	t.Setenv(fakeModeEnv, "")
+	if _, err := newFakeHelper(t).run(context.Background(), "no-such-command", "testApiKey123"); errors.Is(err, ErrBinaryKilled) {
+		t.Errorf("Expected an unsuccessful exit not to match ErrBinaryKilled, got %v", err)
+	}
+}
+
+func TestKeyRotationHelper_SentinelErrors(t *testing.T) {
+	missing := NewWithBinaryPath(filepath.Join(t.TempDir(), "keyrotation-binary"))
+	if _, err := missing.EncryptApiKey("testApiKey123"); !errors.Is(err, ErrBinaryNotFound) {
+		t.Errorf("Expected ErrBinaryNotFound, got %v", err)
+	}
+
+	if _, err := newFakeHelper(t).run(context.Background(), "no-such-command", "testApiKey123"); !errors.Is(err, ErrBinaryExecFailed) {
+		t.Errorf("Expected ErrBinaryExecFailed, got %v", err)
+	}
+
+	t.Setenv(fakeModeEnv, "silent")
+	if _, err := newFakeHelper(t).EncryptApiKey("testApiKey123"); !errors.Is(err, ErrUnexpectedOutput) {
+		t.Errorf("Expected ErrUnexpectedOutput, got %v", err)
+	}
+}
+
+func TestKeyRotationHelper_ValidationRejectsUnexpectedOutput(t *testing.T) {
+	for _, mode := range []string{"silent", "argv"} {
+		t.Setenv(fakeModeEnv, mode)
+		helper := newFakeHelper(t)
+
+		if _, err := helper.ValidateApiKeyToday("testApiKey123", wrongCiphertext); !errors.Is(err, ErrUnexpectedOutput) {
+			t.Errorf("%s: Expected ErrUnexpectedOutput from ValidateApiKeyToday, got %v", mode, err)
+		}
+		if _, err := helper.ValidateApiKey("testApiKey123", wrongCiphertext, time.Now()); !errors.Is(err, ErrUnexpectedOutput) {
+			t.Errorf("%s: Expected ErrUnexpectedOutput from ValidateApiKey, got %v", mode, err)
+		}
+		if _, err := helper.ValidateApiKeyTodayWithTolerance("testApiKey123", wrongCiphertext, 5); !errors.Is(err, ErrUnexpectedOutput) {
+			t.Errorf("%s: Expected ErrUnexpectedOutput from ValidateApiKeyTodayWithTolerance, got %v", mode, err)
+		}
+	}
+}
+
+func TestKeyRotationHelper_RejectsInvalidKeys(t *testing.T) {
+	recorder := &eventRecorder{}
+	helper := newFakeHelper(t, WithLogger(recorder), WithMaxKeyLength(8))
+
+	if _, err := helper.EncryptApiKey(""); !errors.Is(err, ErrEmptyKey) {
+		t.Errorf("Expected ErrEmptyKey, got %v", err)
+	}
+	if _, err := helper.ValidateApiKeyToday("", wrongCiphertext); !errors.Is(err, ErrEmptyKey) {
+		t.Errorf("Expected ErrEmptyKey from validation, got %v", err)
+	}
+	if _, err := helper.EncryptApiKey("123456789"); !errors.Is(err, ErrKeyTooLong) {
+		t.Errorf("Expected ErrKeyTooLong, got %v", err)
+	}
+	var batchErr *BatchError
+	if _, err := helper.EncryptApiKeyBatch([]string{"short", "123456789"}); !errors.As(err, &batchErr) || batchErr.Index != 1 || !errors.Is(err, ErrKeyTooLong) {
+		t.Errorf("Expected ErrKeyTooLong at batch index 1, got %v", err)
+	}
+	if calls := len(recorder.recorded()); calls != 0 {
+		t.Errorf("Expected invalid keys to be rejected before calling the binary, got %d invocations", calls)
+	}
+
+	if _, err := helper.EncryptApiKey("12345678"); err != nil {
+		t.Errorf("Expected key at the limit to be accepted, got %v", err)
+	}
+	if _, err := NewWithOptions(WithMaxKeyLength(0)); err == nil {
+		t.Error("Expected a non-positive max key length to be rejected")
+	}
+	if New().maxKeyLen != DefaultMaxKeyLength {
+		t.Errorf("Expected default max key length %d, got %d", DefaultMaxKeyLength, New().maxKeyLen)
+	}
+}
+
+func TestNewWithBinaryPathChecked(t *testing.T) {
+	dir := t.TempDir()
+
+	if _, err := NewWithBinaryPathChecked(filepath.Join(dir, "missing")); !errors.Is(err, ErrBinaryNotFound) {
+		t.Errorf("Expected ErrBinaryNotFound, got %v", err)
+	}
+
+	if _, err := NewWithBinaryPathChecked(dir); !errors.Is(err, ErrBinaryNotExecutable) {
+		t.Errorf("Expected ErrBinaryNotExecutable for directory path, got %v", err)
+	}
+
+	plain := filepath.Join(dir, "plain")
+	if err := os.WriteFile(plain, []byte("#!/bin/sh\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	if _, err := NewWithBinaryPathChecked(plain); !errors.Is(err, ErrBinaryNotExecutable) {
+		t.Errorf("Expected ErrBinaryNotExecutable for non-executable file, got %v", err)
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("Failed to locate test executable: %v", err)
+	}
+	helper, err := NewWithBinaryPathChecked(exe, WithTimeout(time.Second))
+	if err != nil {
+		t.Fatalf("NewWithBinaryPathChecked failed: %v", err)
+	}
+	if helper.binaryPath != exe || helper.timeout != time.Second {
+		t.Errorf("Options not applied: %+v", helper)
+	}
+}
+
+func TestKeyRotationHelper_BinaryNotExecutable(t *testing.T) {
+	dir := t.TempDir()
+	plain := filepath.Join(dir, "plain")
+	if err := os.WriteFile(plain, []byte("#!/bin/sh\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	for _, path := range []string{dir, plain} {
+		_, err := NewWithBinaryPath(path).EncryptApiKey("testApiKey123")
+		if !errors.Is(err, ErrBinaryNotExecutable) || !errors.Is(err, ErrBinaryExecFailed) {
+			t.Errorf("Expected ErrBinaryNotExecutable for %s, got %v", path, err)
+		}
+		if err != nil && !strings.Contains(err.Error(), path) {
+			t.Errorf("Expected the error to name %s, got %v", path, err)
+		}
+		if _, err := NewPersistent(path); !errors.Is(err, ErrBinaryNotExecutable) {
+			t.Errorf("Expected NewPersistent to report ErrBinaryNotExecutable for %s, got %v", path, err)
+		}
+	}
+	if _, err := NewWithBinaryPath(dir).EncryptApiKey("testApiKey123"); err == nil || !strings.Contains(err.Error(), "is a directory") {
+		t.Errorf("Expected the error to say the path is a directory, got %v", err)
+	}
+}
+
+func TestKeyRotationHelper_ConcurrentUse(t *testing.T) {
+	helpers := map[string]*KeyRotationHelper{
+		"exec":       newFakeHelper(t),
+		"persistent": newPersistentFakeHelper(t),
+	}
+	for name, helper := range helpers {
+		t.Run(name, func(t *testing.T) {
+			var wg sync.WaitGroup
+			for i := 0; i < 32; i++ {
+				wg.Add(1)
+				go func(i int) {
+					defer wg.Done()
+					apiKey := "concurrentKey" + strconv.Itoa(i)
+					encrypted, err := helper.EncryptApiKey(apiKey)
+					if err != nil {
+						t.Errorf("EncryptApiKey failed: %v", err)
+						return
+					}
+					isValid, err := helper.ValidateApiKeyToday(apiKey, encrypted)
+					if err != nil {
+						t.Errorf("ValidateApiKeyToday failed: %v", err)
+						return
+					}
+					if !isValid {
+						t.Errorf("Expected %s to validate", apiKey)
+					}
+				}(i)
+			}
+			wg.Wait()
+		})
+	}
+}
+
+func TestKeyRotationHelper_ValidateApiKeyWithToleranceHonorsTolerance(t *testing.T) {
+	helper := newFakeHelper(t)
+	testApiKey := "testApiKey123"
+	nearMidnight := time.Date(2024, 1, 15, 23, 58, 0, 0, time.UTC)
+	nextDay := fakeHash(testApiKey, nearMidnight.AddDate(0, 0, 1))
+
+	isValid, err := helper.ValidateApiKeyWithTolerance(testApiKey, nextDay, nearMidnight, 5)
+	if err != nil {
+		t.Fatalf("ValidateApiKeyWithTolerance failed: %v", err)
+	}
+	if !isValid {
+		t.Error("Expected next day's key to validate within a 5-minute tolerance")
+	}
+
+	isValid, err = helper.ValidateApiKeyWithTolerance(testApiKey, nextDay, nearMidnight, 1)
+	if err != nil {
+		t.Fatalf("ValidateApiKeyWithTolerance failed: %v", err)
+	}
+	if isValid {
+		t.Error("Expected next day's key not to validate within a 1-minute tolerance")
+	}
+}
+
+func TestKeyRotationHelper_ValidateApiKeyWithToleranceEmulated(t *testing.T) {
+	t.Setenv(fakeModeEnv, "legacy")
+	recorder := &eventRecorder{}
+	helper := newFakeHelper(t, WithLegacyArgv(true), WithLogger(recorder))
+	testApiKey := "testApiKey123"
+	nearMidnight := time.Date(2024, 1, 15, 23, 58, 0, 0, time.UTC)
+	nextDay := fakeHash(testApiKey, nearMidnight.AddDate(0, 0, 1))
+
+	for _, tc := range []struct {
+		tolerance int
+		want      bool
+	}{{5, true}, {1, false}} {
+		isValid, err := helper.ValidateApiKeyWithTolerance(testApiKey, nextDay, nearMidnight, tc.tolerance)
+		if err != nil {
+			t.Fatalf("ValidateApiKeyWithTolerance failed: %v", err)
+		}
+		if isValid != tc.want {
+			t.Errorf("Expected next day's key within a %d-minute tolerance to validate %v, got %v", tc.tolerance, tc.want, isValid)
+		}
+	}
+	var operations []string
+	for _, e := range recorder.recorded() {
+		operations = append(operations, e.Operation)
+	}
+	// The 5-minute window crosses midnight, the 1-minute one stays on the 15th
+	if got := strings.Join(operations, " "); got != "capabilities encrypt-date encrypt-date encrypt-date" {
+		t.Errorf("Expected one encryption per rotation date in the window, got %s", got)
+	}
+
+	if _, err := helper.ValidateApiKeyWithTolerance(testApiKey, nextDay, nearMidnight, maxEmulatedToleranceMinutes+1); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected an over-wide tolerance to fail with ErrUnsupported, got %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := helper.ValidateApiKeyWithToleranceContext(ctx, testApiKey, nextDay, nearMidnight, 5); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestKeyRotationHelper_ValidateApiKeyWithToleranceNegative(t *testing.T) {
+	at := time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC)
+	// The legacy fake lacks validate-tolerance-date, so the check would be emulated
+	for _, mode := range []string{"", "legacy"} {
+		t.Setenv(fakeModeEnv, mode)
+		recorder := &eventRecorder{}
+		helper := newFakeHelper(t, WithLegacyArgv(mode == "legacy"), WithLogger(recorder))
+		if _, err := helper.ValidateApiKeyWithTolerance("testApiKey123", wrongCiphertext, at, -5); !errors.Is(err, ErrUnsafeArgument) {
+			t.Errorf("mode %q: Expected a negative tolerance to fail with ErrUnsafeArgument, got %v", mode, err)
+		}
+		if events := recorder.recorded(); len(events) != 0 {
+			t.Errorf("mode %q: Expected a negative tolerance never to reach the binary, got %d invocations", mode, len(events))
+		}
+	}
+}
+
+func TestKeyRotationHelper_ToleranceCoversEveryInterval(t *testing.T) {
+	at := time.Date(2024, 1, 15, 12, 10, 0, 0, time.UTC)
+	inMemory := NewInMemory(WithRotationInterval(time.Hour), WithClock(func() time.Time { return at }))
+	exec := newFakeHelper(t, WithRotationInterval(time.Hour), WithClock(func() time.Time { return at }))
+	testApiKey := "testApiKey123"
+
+	// A 3-hour tolerance around 12:10 spans the hours from 09:00 to 15:00, not only those of
+	// 09:10, 12:10 and 15:10
+	for _, tc := range []struct {
+		hour int
+		want bool
+	}{{8, false}, {9, true}, {10, true}, {11, true}, {13, true}, {14, true}, {15, true}, {16, false}} {
+		date := time.Date(2024, 1, 15, tc.hour, 0, 0, 0, time.UTC)
+		encrypted, err := inMemory.EncryptApiKeyWithDate(testApiKey, date)
+		if err != nil {
+			t.Fatalf("EncryptApiKeyWithDate failed: %v", err)
+		}
+		checks := map[string]func() (bool, error){
+			"in-memory": func() (bool, error) { return inMemory.ValidateApiKeyWithTolerance(testApiKey, encrypted, at, 180) },
+			"exec":      func() (bool, error) { return exec.ValidateApiKeyWithTolerance(testApiKey, encrypted, at, 180) },
+			"emulated": func() (bool, error) {
+				return inMemory.emulateTolerance(context.Background(), testApiKey, encrypted, at, 180)
+			},
+			"detailed": func() (bool, error) {
+				result, err := exec.ValidateApiKeyTodayWithToleranceDetailed(testApiKey, encrypted, 180)
+				if result.Valid && !result.MatchedDate.Equal(date) {
+					t.Errorf("Expected the detailed check to match %v, got %v", date, result.MatchedDate)
+				}
+				return result.Valid, err
+			},
+		}
+		for name, check := range checks {
+			isValid, err := check()
+			if err != nil {
+				t.Fatalf("%s: tolerance check failed: %v", name, err)
+			}
+			if isValid != tc.want {
+				t.Errorf("%s: Expected the key for %02d:00 to validate %v, got %v", name, tc.hour, tc.want, isValid)
+			}
+		}
+	}
+}
+
+func TestKeyRotationHelper_ValidateApiKeyYesterdayTomorrow(t *testing.T) {
+	now := time.Date(2024, 3, 1, 0, 30, 0, 0, time.UTC)
+	helper := newFakeHelper(t, WithClock(func() time.Time { return now }))
+	testApiKey := "testApiKey123"
+	yesterday, tomorrow := fakeHash(testApiKey, now.AddDate(0, 0, -1)), fakeHash(testApiKey, now.AddDate(0, 0, 1))
+
+	for _, tc := range []struct {
+		name      string
+		validate  func(apiKey, encryptedKey string) (bool, error)
+		encrypted string
+		want      bool
+	}{
+		{"yesterday's key for yesterday", helper.ValidateApiKeyYesterday, yesterday, true},
+		{"tomorrow's key for yesterday", helper.ValidateApiKeyYesterday, tomorrow, false},
+		{"tomorrow's key for tomorrow", helper.ValidateApiKeyTomorrow, tomorrow, true},
+		{"today's key for tomorrow", helper.ValidateApiKeyTomorrow, fakeHash(testApiKey, now), false},
+	} {
+		isValid, err := tc.validate(testApiKey, tc.encrypted)
+		if err != nil {
+			t.Fatalf("%s: validation failed: %v", tc.name, err)
+		}
+		if isValid != tc.want {
+			t.Errorf("%s: expected %v, got %v", tc.name, tc.want, isValid)
+		}
+	}
+}
+
+func TestKeyRotationHelper_ValidateApiKeyTodaySoft(t *testing.T) {
+	recorder := &eventRecorder{}
+	helper := newFakeHelper(t, WithLogger(recorder))
+	testApiKey := "testApiKey123"
+
+	if !helper.ValidateApiKeyTodaySoft(testApiKey, fakeHash(testApiKey, time.Now().UTC())) {
+		t.Error("Expected today's key to validate")
+	}
+	if helper.ValidateApiKeyTodaySoft(testApiKey, wrongCiphertext) {
+		t.Error("Expected a wrong key to be rejected")
+	}
+	if helper.ValidateApiKeyTodaySoft(testApiKey, "not-a-ciphertext") {
+		t.Error("Expected a malformed ciphertext to be rejected")
+	}
+	events := recorder.recorded()
+	if last := events[len(events)-1]; last.Operation != "validate-soft" || !errors.Is(last.Err, ErrMalformedCiphertext) {
+		t.Errorf("Expected the swallowed error to be logged, got %+v", last)
+	}
+
+	if NewWithBinaryPath("/nonexistent").ValidateApiKeyTodaySoft(testApiKey, wrongCiphertext) {
+		t.Error("Expected a missing binary to fail closed")
+	}
+}
+
+func TestKeyRotationHelper_WithLocationMidnightBoundary(t *testing.T) {
+	bangkok, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		t.Skipf("Time zone database unavailable: %v", err)
+	}
+	helper := newFakeHelper(t, WithLocation(bangkok))
+	testApiKey := "testApiKey123"
+
+	// 16:59 UTC is 23:59 in Bangkok on the 15th; one minute later it is already the 16th there
+	beforeMidnight := time.Date(2024, 1, 15, 16, 59, 0, 0, time.UTC)
+	afterMidnight := beforeMidnight.Add(time.Minute)
+
+	if got := helper.GetDateString(beforeMidnight); got != "20240115" {
+		t.Errorf("Expected 20240115 before Bangkok midnight, got %s", got)
+	}
+	if got := helper.GetDateString(afterMidnight); got != "20240116" {
+		t.Errorf("Expected 20240116 after Bangkok midnight, got %s", got)
+	}
+
+	encrypted, err := helper.EncryptApiKeyWithDate(testApiKey, afterMidnight)
+	if err != nil {
+		t.Fatalf("EncryptApiKeyWithDate failed: %v", err)
+	}
+	if expected := fakeHash(testApiKey, time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC)); encrypted != expected {
+		t.Errorf("Expected Bangkok date 2024-01-16 to be used, got %s", encrypted)
+	}
+
+	isValid, err := helper.ValidateApiKey(testApiKey, encrypted, beforeMidnight)
+	if err != nil {
+		t.Fatalf("ValidateApiKey failed: %v", err)
+	}
+	if isValid {
+		t.Error("Expected key for the 16th not to validate before Bangkok midnight")
+	}
+
+	isValid, err = helper.ValidateApiKeyWithTolerance(testApiKey, encrypted, beforeMidnight, 5)
+	if err != nil {
+		t.Fatalf("ValidateApiKeyWithTolerance failed: %v", err)
+	}
+	if !isValid {
+		t.Error("Expected key for the 16th to validate within tolerance of Bangkok midnight")
+	}
+}
+
+func TestKeyRotationHelper_WithLocationToday(t *testing.T) {
+	bangkok, err := time.LoadLocation("Asia/Bangkok")
+	if err != nil {
+		t.Skipf("Time zone database unavailable: %v", err)
+	}
+	helper := newFakeHelper(t, WithLocation(bangkok))
+	testApiKey := "testApiKey123"
+
+	encrypted, err := helper.EncryptApiKey(testApiKey)
+	if err != nil {
+		t.Fatalf("EncryptApiKey failed: %v", err)
+	}
+	today := time.Now().In(bangkok)
+	bangkokToday := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+	if encrypted != fakeHash(testApiKey, bangkokToday) {
+		t.Errorf("Expected today's date in Bangkok to be used")
+	}
+
+	isValid, err := helper.ValidateApiKeyToday(testApiKey, encrypted)
+	if err != nil {
+		t.Fatalf("ValidateApiKeyToday failed: %v", err)
+	}
+	if !isValid {
+		t.Error("Expected validation to succeed")
+	}
+}
+
+// installFakeOnPath links the fake binary into a fresh directory as name and puts only that directory on PATH
+func installFakeOnPath(t *testing.T, name string) {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("Failed to locate test executable: %v", err)
+	}
+	dir := t.TempDir()
+	if err := os.Symlink(exe, filepath.Join(dir, name)); err != nil {
+		t.Skipf("Symlinks unavailable: %v", err)
+	}
+	t.Setenv("PATH", dir)
+}
+
+func TestKeyRotationHelper_ResolvesBareNameFromPath(t *testing.T) {
+	installFakeOnPath(t, "custom-keyrotation")
+
+	encrypted, err := NewWithBinaryPath("custom-keyrotation").EncryptApiKey("testApiKey123")
+	if err != nil {
+		t.Fatalf("EncryptApiKey failed: %v", err)
+	}
+	if encrypted != fakeHash("testApiKey123", time.Now().UTC()) {
+		t.Errorf("Unexpected result %s", encrypted)
+	}
+
+	if _, err := NewWithBinaryPath("missing-keyrotation").EncryptApiKey("testApiKey123"); !errors.Is(err, ErrBinaryNotFound) {
+		t.Errorf("Expected ErrBinaryNotFound for name missing from PATH, got %v", err)
+	}
+}
+
+func TestKeyRotationHelper_DefaultFallsBackToPath(t *testing.T) {
+	installFakeOnPath(t, defaultBinaryName)
+	t.Chdir(t.TempDir())
+
+	if _, err := New().EncryptApiKey("testApiKey123"); err != nil {
+		t.Errorf("Expected default binary to be found on PATH, got %v", err)
+	}
+
+	t.Setenv("PATH", t.TempDir())
+	_, err := New().EncryptApiKey("testApiKey123")
+	if !errors.Is(err, ErrBinaryNotFound) {
+		t.Fatalf("Expected ErrBinaryNotFound, got %v", err)
+	}
+	if !strings.Contains(err.Error(), defaultBinaryPath) || !strings.Contains(err.Error(), "PATH") {
+		t.Errorf("Expected error to name both locations tried, got %q", err)
+	}
+}
+
+func TestNewWithBinaryPaths(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing")
+	plain := filepath.Join(dir, "plain")
+	if err := os.WriteFile(plain, []byte("#!/bin/sh\n"), 0o644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("Failed to locate test executable: %v", err)
+	}
+
+	helper, err := NewWithBinaryPaths(missing, plain, exe, missing)
+	if err != nil {
+		t.Fatalf("NewWithBinaryPaths failed: %v", err)
+	}
+	if helper.binaryPath != exe {
+		t.Errorf("Expected first usable path %s, got %s", exe, helper.binaryPath)
+	}
+	if _, err := helper.EncryptApiKey("testApiKey123"); err != nil {
+		t.Errorf("EncryptApiKey failed: %v", err)
+	}
+
+	_, err = NewWithBinaryPaths(missing, plain, dir)
+	if !errors.Is(err, ErrBinaryNotFound) {
+		t.Fatalf("Expected ErrBinaryNotFound, got %v", err)
+	}
+	for _, path := range []string{missing, plain, dir} {
+		if !strings.Contains(err.Error(), path) {
+			t.Errorf("Expected error to list %s, got %q", path, err)
+		}
+	}
+	if _, err := NewWithBinaryPaths(); err == nil {
+		t.Error("Expected error for no paths")
+	}
+}
+
+func TestKeyRotationHelper_Close(t *testing.T) {
+	helper := newFakeHelper(t, WithValidationCache(16))
+	testApiKey := "testApiKey123"
+	encrypted := fakeHash(testApiKey, time.Now().UTC())
+	if _, err := helper.ValidateApiKeyToday(testApiKey, encrypted); err != nil {
+		t.Fatalf("ValidateApiKeyToday failed: %v", err)
+	}
+
+	if err := helper.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := helper.Close(); err != nil {
+		t.Errorf("Expected second Close to be a no-op, got %v", err)
+	}
+	if helper.cache.len() != 0 {
+		t.Errorf("Expected Close to flush the validation cache, got %d entries", helper.cache.len())
+	}
+	if _, err := helper.ValidateApiKeyToday(testApiKey, encrypted); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed after Close, got %v", err)
+	}
+	if _, err := helper.EncryptApiKey(testApiKey); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed after Close, got %v", err)
+	}
+}
+
 // Package-level function tests
 
 func TestEncryptApiKey(t *testing.T) {
@@ -188,3 +878,95 @@ func TestGetDateString(t *testing.T) {
 		t.Errorf("Expected %s, got %s", expected, result)
 	}
 }
+
+func TestGetDateStringWithLayout(t *testing.T) {
+	testDate := time.Date(2024, 1, 15, 12, 30, 45, 0, time.UTC)
+
+	cases := map[string]string{
+		DateStringLayout: "20240115",
+		"2006-01-02":     "2024-01-15",
+		"02/01/2006":     "15/01/2024",
+	}
+	for layout, expected := range cases {
+		if result := GetDateStringWithLayout(testDate, layout); result != expected {
+			t.Errorf("Layout %q: expected %s, got %s", layout, expected, result)
+		}
+	}
+}
+
+func TestParseDateString(t *testing.T) {
+	testDate := time.Date(2024, 2, 29, 18, 5, 0, 0, time.UTC)
+
+	date, err := ParseDateString(GetDateString(testDate))
+	if err != nil {
+		t.Fatalf("ParseDateString failed: %v", err)
+	}
+	if want := time.Date(2024, 2, 29, 0, 0, 0, 0, time.UTC); !date.Equal(want) || date.Location() != time.UTC {
+		t.Errorf("Expected %v, got %v", want, date)
+	}
+	if GetDateString(date) != "20240229" {
+		t.Errorf("Expected round trip to be lossless, got %s", GetDateString(date))
+	}
+
+	for _, input := range []string{"", "2024011", "202401150", "2024-1-15", "2024O115", "20240230", "20241301"} {
+		if _, err := ParseDateString(input); err == nil {
+			t.Errorf("Expected error for malformed date string %q", input)
+		}
+	}
+}
+
+func TestKeyRotationHelper_ParseDateStringInLocation(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Skipf("Time zone database unavailable: %v", err)
+	}
+	helper := New(WithLocation(tokyo))
+
+	date, err := helper.ParseDateString("20240115")
+	if err != nil {
+		t.Fatalf("ParseDateString failed: %v", err)
+	}
+	if want := time.Date(2024, 1, 15, 0, 0, 0, 0, tokyo); !date.Equal(want) {
+		t.Errorf("Expected midnight in Tokyo %v, got %v", want, date)
+	}
+}
+
+func TestKeyRotationHelper_NormalizesCRLFOutput(t *testing.T) {
+	helper := newFakeHelper(t)
+	want, err := helper.EncryptApiKey("testApiKey123")
+	if err != nil {
+		t.Fatalf("EncryptApiKey failed: %v", err)
+	}
+
+	t.Setenv(fakeModeEnv, "crlf")
+	if got, err := helper.EncryptApiKey("testApiKey123"); err != nil || got != want {
+		t.Errorf("Expected %s, got %q, %v", want, got, err)
+	}
+	if valid, err := helper.ValidateApiKeyToday("testApiKey123", want); err != nil || !valid {
+		t.Errorf("Expected CRLF output to validate, got %v, %v", valid, err)
+	}
+	results, err := helper.ValidateApiKeyBatch([]KeyPair{{"testApiKey123", want}, {"testApiKey123", wrongCiphertext}})
+	if err != nil || !results[0] || results[1] {
+		t.Errorf("Expected [true false] from CRLF batch output, got %v, %v", results, err)
+	}
+}
+
+func TestKeyRotationHelper_NormalizesBOMOutput(t *testing.T) {
+	helper := newFakeHelper(t)
+	encrypted, err := helper.EncryptApiKey("testApiKey123")
+	if err != nil {
+		t.Fatalf("EncryptApiKey failed: %v", err)
+	}
+
+	t.Setenv(fakeModeEnv, "bom")
+	if valid, err := helper.ValidateApiKeyToday("testApiKey123", encrypted); err != nil || !valid {
+		t.Errorf("Expected BOM-prefixed TRUE to validate, got %v, %v", valid, err)
+	}
+	if valid, err := helper.ValidateApiKeyToday("testApiKey123", wrongCiphertext); err != nil || valid {
+		t.Errorf("Expected BOM-prefixed FALSE to be rejected, got %v, %v", valid, err)
+	}
+	results, err := helper.ValidateApiKeyBatch([]KeyPair{{"testApiKey123", encrypted}, {"testApiKey123", wrongCiphertext}})
+	if err != nil || !results[0] || results[1] {
+		t.Errorf("Expected [true false] from BOM-prefixed batch output, got %v, %v", results, err)
+	}
+}
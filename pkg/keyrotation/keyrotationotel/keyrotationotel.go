@@ -0,0 +1,67 @@
+// Package keyrotationotel traces keyrotation binary invocations with OpenTelemetry. It lives in
+// its own package so that services which do not use OpenTelemetry do not build against it.
+package keyrotationotel
+
+import (
+	"context"
+	"errors"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/pawincpe/key-rotation/pkg/keyrotation"
+)
+
+// instrumentationName identifies this package as the source of its spans
+const instrumentationName = "github.com/pawincpe/key-rotation/pkg/keyrotation"
+
+// Attribute keys recorded on every span
+const (
+	OperationKey = attribute.Key("keyrotation.operation")
+	ExitCodeKey  = attribute.Key("keyrotation.exit_code")
+)
+
+// NewTracer returns a keyrotation.Tracer that records each binary invocation made with a context
+// carrying an active span as a child span named "keyrotation.<operation>", such as
+// "keyrotation.encrypt". Invocations without a parent span are not traced, so background calls do
+// not start traces of their own. The span's duration is the invocation's, and failures are
+// recorded on it with an error status.
+func NewTracer(provider trace.TracerProvider) keyrotation.Tracer {
+	return tracer{provider.Tracer(instrumentationName)}
+}
+
+type tracer struct {
+	tracer trace.Tracer
+}
+
+func (t tracer) StartSpan(ctx context.Context, op string) (context.Context, keyrotation.Span) {
+	if !trace.SpanContextFromContext(ctx).IsValid() {
+		return ctx, nopSpan{}
+	}
+	ctx, s := t.tracer.Start(ctx, "keyrotation."+op,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(OperationKey.String(op)),
+	)
+	return ctx, span{s}
+}
+
+type span struct {
+	span trace.Span
+}
+
+func (s span) End(err error) {
+	if err != nil {
+		var binErr *keyrotation.BinaryError
+		if errors.As(err, &binErr) {
+			s.span.SetAttributes(ExitCodeKey.Int(binErr.ExitCode))
+		}
+		s.span.RecordError(err)
+		s.span.SetStatus(codes.Error, err.Error())
+	}
+	s.span.End()
+}
+
+type nopSpan struct{}
+
+func (nopSpan) End(error) {}
@@ -0,0 +1,83 @@
+package keyrotationotel
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/pawincpe/key-rotation/pkg/keyrotation"
+)
+
+// newRecordingTracer returns a Tracer whose ended spans are captured by the returned recorder,
+// and a context carrying an active parent span
+func newRecordingTracer(t *testing.T) (keyrotation.Tracer, *tracetest.SpanRecorder, context.Context) {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	t.Cleanup(func() { provider.Shutdown(context.Background()) })
+
+	ctx, parent := provider.Tracer("test").Start(context.Background(), "request")
+	t.Cleanup(func() { parent.End() })
+	return NewTracer(provider), recorder, ctx
+}
+
+func TestNewTracer_RecordsChildSpans(t *testing.T) {
+	tracer, recorder, ctx := newRecordingTracer(t)
+	helper := keyrotation.NewInMemory(keyrotation.WithTracer(tracer))
+
+	if _, err := helper.EncryptApiKeyContext(ctx, "testApiKey123"); err != nil {
+		t.Fatalf("EncryptApiKeyContext failed: %v", err)
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(spans))
+	}
+	s := spans[0]
+	if s.Name() != "keyrotation.encrypt" {
+		t.Errorf("Unexpected span name %q", s.Name())
+	}
+	if s.Parent().SpanID() != trace.SpanContextFromContext(ctx).SpanID() {
+		t.Error("Expected span to be a child of the request span")
+	}
+	if s.Status().Code == codes.Error {
+		t.Errorf("Expected successful span, got status %v", s.Status())
+	}
+	if len(s.Attributes()) == 0 || s.Attributes()[0] != OperationKey.String("encrypt") {
+		t.Errorf("Expected operation attribute, got %v", s.Attributes())
+	}
+}
+
+func TestNewTracer_RecordsErrors(t *testing.T) {
+	tracer, recorder, ctx := newRecordingTracer(t)
+	helper := keyrotation.NewWithBinaryPath(filepath.Join(t.TempDir(), "missing"), keyrotation.WithTracer(tracer))
+
+	if _, err := helper.EncryptApiKeyContext(ctx, "testApiKey123"); err == nil {
+		t.Fatal("Expected encryption with a missing binary to fail")
+	}
+
+	spans := recorder.Ended()
+	if len(spans) != 1 || spans[0].Status().Code != codes.Error {
+		t.Fatalf("Expected 1 span with an error status, got %d spans", len(spans))
+	}
+	if len(spans[0].Events()) == 0 {
+		t.Error("Expected the error to be recorded as a span event")
+	}
+}
+
+func TestNewTracer_SkipsCallsWithoutParent(t *testing.T) {
+	tracer, recorder, _ := newRecordingTracer(t)
+	helper := keyrotation.NewInMemory(keyrotation.WithTracer(tracer))
+
+	if _, err := helper.EncryptApiKeyContext(context.Background(), "testApiKey123"); err != nil {
+		t.Fatalf("EncryptApiKeyContext failed: %v", err)
+	}
+	if spans := recorder.Ended(); len(spans) != 0 {
+		t.Errorf("Expected no spans without a parent, got %d", len(spans))
+	}
+}
@@ -0,0 +1,26 @@
+// Package keyrotationtest provides helpers for testing code that depends on keyrotation without
+// deploying the private binary.
+package keyrotationtest
+
+import (
+	"time"
+
+	"github.com/pawincpe/key-rotation/pkg/keyrotation"
+)
+
+// NewFakeHelper returns a helper that computes keys in Go, deterministically and without the
+// binary. It has the full method set of a real helper and produces the same ciphertexts, so keys
+// generated in tests validate against the real binary and vice versa.
+func NewFakeHelper(opts ...keyrotation.Option) *keyrotation.KeyRotationHelper {
+	return keyrotation.NewInMemory(opts...)
+}
+
+// EncryptedKey returns the ciphertext apiKey rotates to on the date of t, for building fixtures.
+// It panics if apiKey cannot be encrypted, such as when it is empty.
+func EncryptedKey(apiKey string, t time.Time) string {
+	encrypted, err := NewFakeHelper().EncryptApiKeyWithDate(apiKey, t)
+	if err != nil {
+		panic(err)
+	}
+	return encrypted
+}
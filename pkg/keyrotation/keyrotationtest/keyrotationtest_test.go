@@ -0,0 +1,38 @@
+package keyrotationtest
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewFakeHelper_RoundTrip(t *testing.T) {
+	helper := NewFakeHelper()
+
+	encrypted, err := helper.EncryptApiKey("testApiKey123")
+	if err != nil {
+		t.Fatalf("EncryptApiKey failed: %v", err)
+	}
+	if valid, err := helper.ValidateApiKeyToday("testApiKey123", encrypted); err != nil || !valid {
+		t.Errorf("Expected key to validate, got %v, %v", valid, err)
+	}
+	if valid, err := helper.ValidateApiKeyToday("otherKey", encrypted); err != nil || valid {
+		t.Errorf("Expected other key to be rejected, got %v, %v", valid, err)
+	}
+}
+
+func TestEncryptedKey_MatchesFormula(t *testing.T) {
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	if got, want := EncryptedKey("abc", date), "267392d8b0035e47f02c98d68701371a923a8eee30761fd662a1f8bdcfb8239a"; got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
+
+func TestEncryptedKey_PanicsOnEmptyKey(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected EncryptedKey to panic on an empty key")
+		}
+	}()
+	EncryptedKey("", time.Now())
+}
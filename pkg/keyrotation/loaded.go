@@ -0,0 +1,134 @@
+package keyrotation
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+// loadedDatePrefix introduces the comment line that declares which rotation date a ciphertext
+// file was computed for
+const loadedDatePrefix = "# date:"
+
+// loadedCiphertexts holds the digests read with LoadValidCiphertexts
+type loadedCiphertexts struct {
+	mu   sync.Mutex
+	path string
+	// loadedFor is the rotation date string current when the file was last read, and date the one
+	// the file declares, or empty if it declares none
+	loadedFor string
+	date      string
+	digests   map[string]struct{} // nil until a file is loaded
+}
+
+// LoadValidCiphertexts reads the set of valid ciphertexts for the current rotation date from the
+// file at path, so ValidateAgainstLoaded can check membership without invoking the binary or
+// holding any plaintext key. The file lists one hex or base64 ciphertext per line; blank lines and
+// lines starting with # are ignored, except that a "# date: <yyyyMMdd>" line declares the date
+// string the ciphertexts were computed for, as GetDateString reports it. The file is read again by
+// the first validation of each later rotation date, so a file replaced daily is picked up at the
+// boundary. A file that cannot be read or parsed leaves the previously loaded set in place.
+func (k *KeyRotationHelper) LoadValidCiphertexts(path string) error {
+	s := &k.loaded
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := k.readCiphertexts(path); err != nil {
+		return fmt.Errorf("failed to load valid ciphertexts: %w", err)
+	}
+	return nil
+}
+
+// ValidateAgainstLoaded reports whether encryptedKey is among the ciphertexts loaded with
+// LoadValidCiphertexts. It fails with ErrStaleCiphertexts when the file declares a date other than
+// the current one, even after being read again at the boundary, rather than accept yesterday's
+// keys, and with ErrMalformedCiphertext for a value that is not a digest of the configured
+// algorithm unless WithCiphertextCheck(false) is set.
+func (k *KeyRotationHelper) ValidateAgainstLoaded(encryptedKey string) (bool, error) {
+	if k.configErr != nil {
+		return false, k.configErr
+	}
+	if k.closed.Load() {
+		return false, ErrClosed
+	}
+
+	s := &k.loaded
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.digests == nil {
+		return false, errors.New("keyrotation: no ciphertexts loaded; call LoadValidCiphertexts first")
+	}
+	today := k.GetDateString(k.now())
+	if s.loadedFor != today {
+		if err := k.readCiphertexts(s.path); err != nil {
+			return false, fmt.Errorf("failed to refresh loaded ciphertexts: %w", err)
+		}
+	}
+	if s.date != "" && s.date != today {
+		return false, fmt.Errorf("%w: %s lists ciphertexts for %s, not %s", ErrStaleCiphertexts, s.path, s.date, today)
+	}
+
+	digest, err := k.decodeCiphertext(encryptedKey)
+	if err != nil {
+		if k.skipCiphertextCheck {
+			return false, nil
+		}
+		return false, fmt.Errorf("failed to validate against loaded ciphertexts: %w", err)
+	}
+	_, ok := s.digests[string(digest)]
+	return ok, nil
+}
+
+// readCiphertexts replaces the loaded set with the contents of the file at path. The caller must
+// hold k.loaded.mu.
+func (k *KeyRotationHelper) readCiphertexts(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var date string
+	digests := make(map[string]struct{})
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasPrefix(line, loadedDatePrefix) {
+			date = strings.TrimSpace(strings.TrimPrefix(line, loadedDatePrefix))
+			continue
+		}
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		digest, err := k.decodeCiphertext(line)
+		if err != nil {
+			return fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		digests[string(digest)] = struct{}{}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	s := &k.loaded
+	s.path, s.loadedFor, s.date, s.digests = path, k.GetDateString(k.now()), date, digests
+	return nil
+}
+
+// expireLoaded makes the next ValidateAgainstLoaded read the loaded file again
+func (k *KeyRotationHelper) expireLoaded() {
+	s := &k.loaded
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loadedFor = ""
+}
+
+// clearLoaded discards the ciphertexts loaded with LoadValidCiphertexts
+func (k *KeyRotationHelper) clearLoaded() {
+	s := &k.loaded
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.path, s.loadedFor, s.date, s.digests = "", "", "", nil
+}
@@ -0,0 +1,95 @@
+package keyrotation
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeCiphertexts writes a ciphertext file for LoadValidCiphertexts and returns its path
+func writeCiphertexts(t *testing.T, path string, lines ...string) string {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write ciphertexts: %v", err)
+	}
+	return path
+}
+
+func TestValidateAgainstLoaded(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)}
+	// The binary is never invoked, so a missing one proves validation works without it
+	helper := NewWithBinaryPath(filepath.Join(t.TempDir(), "missing"), WithClock(clock.now))
+	day1, day2 := clock.now(), clock.now().AddDate(0, 0, 1)
+	path := writeCiphertexts(t, filepath.Join(t.TempDir(), "valid.txt"),
+		"# date: 20250310", "", fakeHash("keyA-123456", day1), strings.ToUpper(fakeHash("keyB-123456", day1)))
+
+	if _, err := helper.ValidateAgainstLoaded(fakeHash("keyA-123456", day1)); err == nil {
+		t.Error("Expected an error before any ciphertexts are loaded")
+	}
+	if err := helper.LoadValidCiphertexts(path); err != nil {
+		t.Fatalf("LoadValidCiphertexts failed: %v", err)
+	}
+	for encrypted, want := range map[string]bool{
+		fakeHash("keyA-123456", day1): true,
+		fakeHash("keyB-123456", day1): true,
+		fakeHash("keyC-123456", day1): false,
+		fakeHash("keyA-123456", day2): false,
+	} {
+		if got, err := helper.ValidateAgainstLoaded(encrypted); err != nil || got != want {
+			t.Errorf("Expected %s to validate %v, got %v, %v", encrypted, want, got, err)
+		}
+	}
+	if _, err := helper.ValidateAgainstLoaded("not-a-digest"); !errors.Is(err, ErrMalformedCiphertext) {
+		t.Errorf("Expected ErrMalformedCiphertext, got %v", err)
+	}
+
+	// Past the boundary the old file is stale until it is replaced
+	clock.set(day2)
+	if _, err := helper.ValidateAgainstLoaded(fakeHash("keyA-123456", day1)); !errors.Is(err, ErrStaleCiphertexts) {
+		t.Errorf("Expected ErrStaleCiphertexts, got %v", err)
+	}
+	clock.set(day2.Add(time.Hour))
+	writeCiphertexts(t, path, "# date: 20250311", fakeHash("keyA-123456", day2))
+	if err := helper.LoadValidCiphertexts(path); err != nil {
+		t.Fatalf("LoadValidCiphertexts failed: %v", err)
+	}
+	if got, err := helper.ValidateAgainstLoaded(fakeHash("keyA-123456", day2)); err != nil || !got {
+		t.Errorf("Expected the replaced file to validate, got %v, %v", got, err)
+	}
+}
+
+func TestValidateAgainstLoaded_RefreshesAtBoundary(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2025, 3, 10, 23, 59, 0, 0, time.UTC)}
+	helper := NewInMemory(WithClock(clock.now))
+	day1, day2 := clock.now(), clock.now().AddDate(0, 0, 1)
+	path := writeCiphertexts(t, filepath.Join(t.TempDir(), "valid.txt"), fakeHash("keyA-123456", day1))
+	if err := helper.LoadValidCiphertexts(path); err != nil {
+		t.Fatalf("LoadValidCiphertexts failed: %v", err)
+	}
+
+	writeCiphertexts(t, path, fakeHash("keyA-123456", day2))
+	if got, err := helper.ValidateAgainstLoaded(fakeHash("keyA-123456", day1)); err != nil || !got {
+		t.Errorf("Expected the loaded set to be kept within the day, got %v, %v", got, err)
+	}
+	clock.set(day2)
+	if got, err := helper.ValidateAgainstLoaded(fakeHash("keyA-123456", day2)); err != nil || !got {
+		t.Errorf("Expected the file to be reloaded at the boundary, got %v, %v", got, err)
+	}
+
+	os.Remove(path)
+	clock.set(day2.AddDate(0, 0, 1))
+	if _, err := helper.ValidateAgainstLoaded(fakeHash("keyA-123456", day2)); err == nil {
+		t.Error("Expected a failed refresh to be reported")
+	}
+}
+
+func TestLoadValidCiphertexts_RejectsMalformedLines(t *testing.T) {
+	path := writeCiphertexts(t, filepath.Join(t.TempDir(), "valid.txt"), wrongCiphertext, "garbage")
+	err := New().LoadValidCiphertexts(path)
+	if !errors.Is(err, ErrMalformedCiphertext) || !strings.Contains(err.Error(), ":2:") {
+		t.Errorf("Expected ErrMalformedCiphertext naming line 2, got %v", err)
+	}
+}
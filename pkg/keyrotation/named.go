@@ -0,0 +1,79 @@
+package keyrotation
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// NamedKeysError reports the names whose API keys EncryptNamedKeys could not encrypt
+type NamedKeysError struct {
+	// Errs maps every failed name to why it failed
+	Errs map[string]error
+}
+
+// Names returns the failed names in sorted order
+func (e *NamedKeysError) Names() []string {
+	return slices.Sorted(maps.Keys(e.Errs))
+}
+
+func (e *NamedKeysError) Error() string {
+	return fmt.Sprintf("keyrotation: named keys failed: %s", strings.Join(e.Names(), ", "))
+}
+
+// Unwrap returns the per-name errors, in the order of Names
+func (e *NamedKeysError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errs))
+	for _, name := range e.Names() {
+		errs = append(errs, e.Errs[name])
+	}
+	return errs
+}
+
+// EncryptNamedKeys encrypts a map of names (for example service names) to API keys with the current
+// date in a single binary invocation, and returns the ciphertexts under the same names. The date is
+// chosen as for EncryptApiKeyBatch. If some keys fail, the returned map still holds every
+// successful ciphertext and the error is a *NamedKeysError naming the failed keys.
+func (k *KeyRotationHelper) EncryptNamedKeys(keys map[string]string) (map[string]string, error) {
+	return k.EncryptNamedKeysContext(context.Background(), keys)
+}
+
+// EncryptNamedKeysContext is like EncryptNamedKeys but kills the binary if ctx is done first
+func (k *KeyRotationHelper) EncryptNamedKeysContext(ctx context.Context, keys map[string]string) (map[string]string, error) {
+	command, prefix := k.todayBatch("encrypt-batch")
+	results := make(map[string]string, len(keys))
+	failed := make(map[string]error)
+	var names, items, apiKeys []string
+	for _, name := range slices.Sorted(maps.Keys(keys)) {
+		key := keys[name]
+		if err := k.checkBatchKey(key); err != nil {
+			failed[name] = err
+			continue
+		}
+		names = append(names, name)
+		items = append(items, prefix+k.binaryKey(key))
+		apiKeys = append(apiKeys, key)
+	}
+
+	if len(items) > 0 {
+		lines, err := k.runTodayBatch(ctx, command, items, apiKeys)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt named keys: %w", err)
+		}
+		for i, line := range lines {
+			encrypted, err := k.batchCiphertext(line, apiKeys[i])
+			if err != nil {
+				failed[names[i]] = err
+				continue
+			}
+			results[names[i]] = encrypted
+		}
+	}
+	if len(failed) > 0 {
+		return results, fmt.Errorf("failed to encrypt named keys: %w", &NamedKeysError{Errs: failed})
+	}
+
+	return results, nil
+}
@@ -0,0 +1,89 @@
+package keyrotation
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestKeyRotationHelper_EncryptNamedKeys(t *testing.T) {
+	var events []Event
+	helper := newFakeHelper(t, WithLogger(LoggerFunc(func(e Event) { events = append(events, e) })))
+	keys := map[string]string{"billing": "billingKey", "search": "searchKey", "users": "usersKey"}
+
+	results, err := helper.EncryptNamedKeys(keys)
+	if err != nil {
+		t.Fatalf("EncryptNamedKeys failed: %v", err)
+	}
+	today := time.Now().UTC()
+	for name, key := range keys {
+		if results[name] != fakeHash(key, today) {
+			t.Errorf("%s: expected %s, got %s", name, fakeHash(key, today), results[name])
+		}
+	}
+	if len(results) != len(keys) {
+		t.Errorf("Expected %d results, got %d", len(keys), len(results))
+	}
+	if len(events) != 1 || events[0].Operation != "encrypt-batch" {
+		t.Errorf("Expected a single encrypt-batch invocation, got %+v", events)
+	}
+}
+
+func TestKeyRotationHelper_EncryptNamedKeysPartialFailure(t *testing.T) {
+	helper := newFakeHelper(t)
+
+	results, err := helper.EncryptNamedKeys(map[string]string{
+		"billing": "billingKey",
+		"empty":   "",
+		"newline": "bad\nkey",
+		"users":   "usersKey",
+	})
+	var namedErr *NamedKeysError
+	if !errors.As(err, &namedErr) {
+		t.Fatalf("Expected *NamedKeysError, got %v", err)
+	}
+	if names := namedErr.Names(); !slices.Equal(names, []string{"empty", "newline"}) {
+		t.Errorf("Expected empty and newline to fail, got %v", names)
+	}
+	if len(results) != 2 || results["billing"] == "" || results["users"] == "" {
+		t.Errorf("Expected the successful ciphertexts, got %q", results)
+	}
+}
+
+func TestKeyRotationHelper_EncryptNamedKeysEmpty(t *testing.T) {
+	results, err := NewWithBinaryPath("/nonexistent").EncryptNamedKeys(nil)
+	if err != nil {
+		t.Fatalf("Expected empty map not to invoke the binary, got %v", err)
+	}
+	if len(results) != 0 {
+		t.Errorf("Expected no results, got %q", results)
+	}
+}
+
+func TestKeyRotationHelper_EncryptNamedKeysBinaryFailure(t *testing.T) {
+	results, err := NewWithBinaryPath("/nonexistent").EncryptNamedKeys(map[string]string{"billing": "billingKey"})
+	if !errors.Is(err, ErrBinaryNotFound) {
+		t.Errorf("Expected ErrBinaryNotFound, got %v", err)
+	}
+	if results != nil {
+		t.Errorf("Expected no results when the binary fails, got %q", results)
+	}
+}
+
+func TestKeyRotationHelper_EncryptNamedKeysWithLocation(t *testing.T) {
+	loc := time.FixedZone("UTC+20", 20*60*60)
+	clock := func() time.Time { return time.Date(2024, 1, 15, 12, 0, 0, 0, time.UTC) }
+	for name, helper := range map[string]*KeyRotationHelper{
+		"exec":      newFakeHelper(t, WithLocation(loc), WithClock(clock)),
+		"in-memory": NewInMemory(WithLocation(loc), WithClock(clock)),
+	} {
+		results, err := helper.EncryptNamedKeys(map[string]string{"billing": "billingKey"})
+		if err != nil {
+			t.Fatalf("%s: EncryptNamedKeys failed: %v", name, err)
+		}
+		if valid, err := helper.ValidateApiKeyToday("billingKey", results["billing"]); err != nil || !valid {
+			t.Errorf("%s: Expected the named key to validate on the same helper, got %v, %v", name, valid, err)
+		}
+	}
+}
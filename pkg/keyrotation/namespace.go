@@ -0,0 +1,19 @@
+package keyrotation
+
+import "strconv"
+
+// namespacedKey returns apiKey scoped to the WithNamespace namespace as "<len>:<namespace>:<key>",
+// or apiKey itself without one. The length prefix keeps the encoding unambiguous, so no two
+// distinct namespace and key pairs produce the same input. An empty key stays empty so the binary
+// still rejects it.
+func (k *KeyRotationHelper) namespacedKey(apiKey string) string {
+	if k.namespace == "" || apiKey == "" {
+		return apiKey
+	}
+	return strconv.Itoa(len(k.namespace)) + ":" + k.namespace + ":" + apiKey
+}
+
+// binaryKey returns the key the binary receives for apiKey, after namespacing and pre-hashing
+func (k *KeyRotationHelper) binaryKey(apiKey string) string {
+	return k.preHashKey(k.namespacedKey(apiKey))
+}
@@ -0,0 +1,61 @@
+package keyrotation
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithNamespace_SeparatesTenants(t *testing.T) {
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	tenantA := newFakeHelper(t, WithNamespace("tenant-a"))
+	tenantB := newFakeHelper(t, WithNamespace("tenant-b"))
+
+	encrypted, err := tenantA.EncryptApiKeyWithDate("secret-key", date)
+	if err != nil {
+		t.Fatalf("EncryptApiKeyWithDate failed: %v", err)
+	}
+	if encrypted != fakeHash("8:tenant-a:secret-key", date) {
+		t.Errorf("Expected the key to be scoped to its namespace, got %s", encrypted)
+	}
+	if valid, err := tenantA.ValidateApiKey("secret-key", encrypted, date); err != nil || !valid {
+		t.Errorf("Expected key to validate in its own namespace, got %v, %v", valid, err)
+	}
+	if valid, err := tenantB.ValidateApiKey("secret-key", encrypted, date); err != nil || valid {
+		t.Errorf("Expected key not to validate in another namespace, got %v, %v", valid, err)
+	}
+	if valid, err := newFakeHelper(t).ValidateApiKey("secret-key", encrypted, date); err != nil || valid {
+		t.Errorf("Expected key not to validate without a namespace, got %v, %v", valid, err)
+	}
+	if got, err := NewInMemory(WithNamespace("tenant-a")).EncryptApiKeyWithDate("secret-key", date); err != nil || got != encrypted {
+		t.Errorf("Expected in-memory engine to match %s, got %s, %v", encrypted, got, err)
+	}
+
+	today, err := tenantA.EncryptApiKey("secret-key")
+	if err != nil {
+		t.Fatalf("EncryptApiKey failed: %v", err)
+	}
+	results, err := tenantA.ValidateApiKeyBatch([]KeyPair{{"secret-key", today}})
+	if err != nil || !results[0] {
+		t.Errorf("Expected batch validation to be scoped identically, got %v, %v", results, err)
+	}
+}
+
+func TestWithNamespace_Unambiguous(t *testing.T) {
+	a := NewInMemory(WithNamespace("a:"))
+	b := NewInMemory(WithNamespace("a"))
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	encA, _ := a.EncryptApiKeyWithDate("b", date)
+	encB, _ := b.EncryptApiKeyWithDate(":b", date)
+	if encA == encB {
+		t.Error("Expected namespace and key boundaries to stay distinct")
+	}
+}
+
+func TestWithNamespace_RejectsControlCharacters(t *testing.T) {
+	_, err := NewWithOptions(WithBinaryPath("/opt/keyrotation-binary"), WithNamespace("tenant\n"))
+	if err == nil || !strings.Contains(err.Error(), "namespace") {
+		t.Errorf("Expected a namespace configuration error, got %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package keyrotation
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"syscall"
+	"time"
+)
+
+// Event describes a single invocation of the binary
+type Event struct {
+	// Operation is the binary subcommand that was run, e.g. "encrypt" or "validate-date"
+	Operation string
+	// Duration is the wall-clock time the invocation took
+	Duration time.Duration
+	// Err is the failure, or nil if the invocation succeeded
+	Err error
+	// ExitCode is the binary's exit status: 0 on success, or -1 if the process did not exit
+	// normally (it could not be started, was killed, or failed a persistent-mode request)
+	ExitCode int
+	// Command is set in dry-run mode to the binary path and arguments that would have been executed
+	Command []string
+	// Usage is the CPU time the process consumed when WithResourceUsage is enabled, or nil. It is
+	// also nil for invocations that started no process of their own, such as requests to the
+	// persistent process or through a custom CommandRunner.
+	Usage *ResourceUsage
+}
+
+// Logger receives an Event after every binary invocation. It is called synchronously from the
+// goroutine making the call, so implementations must be safe for concurrent use and should not block.
+type Logger interface {
+	LogEvent(Event)
+}
+
+// LoggerFunc adapts an ordinary function to the Logger interface
+type LoggerFunc func(Event)
+
+// LogEvent calls f(e)
+func (f LoggerFunc) LogEvent(e Event) {
+	f(e)
+}
+
+// MetricsObserver is notified of the latency and outcome of every binary invocation, for export
+// to a metrics system such as a Prometheus histogram labelled by op. op is the binary subcommand,
+// as in Event.Operation, and err is nil on success. Implementations must be safe for concurrent use.
+type MetricsObserver interface {
+	ObserveDuration(op string, d time.Duration, err error)
+}
+
+// Tracer starts a span around every binary invocation, for distributed tracing. op is the binary
+// subcommand, as in Event.Operation. The keyrotationotel package adapts OpenTelemetry to it, so
+// the core package does not depend on a tracing library. Implementations must be safe for
+// concurrent use.
+type Tracer interface {
+	StartSpan(ctx context.Context, op string) (context.Context, Span)
+}
+
+// Span is an in-progress span started by a Tracer
+type Span interface {
+	// End finishes the span, recording err if the invocation failed
+	End(err error)
+}
+
+// nopLogger is the default Logger, which discards every event
+type nopLogger struct{}
+
+func (nopLogger) LogEvent(Event) {}
+
+// execute performs a binary invocation through call, retrying transient start failures as
+// configured by WithRetry. Every attempt is reported to the configured observers.
+func (k *KeyRotationHelper) execute(ctx context.Context, op string, call func(context.Context) (string, error)) (string, error) {
+	backoff := k.retryBackoff
+	for attempt := 1; ; attempt++ {
+		out, err := k.attempt(ctx, op, call)
+		if err == nil || attempt >= k.retryAttempts || !isTransient(err) {
+			return out, err
+		}
+		wait := backoff
+		if k.retryJitter {
+			wait = fullJitter(backoff)
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < wait {
+			return out, err
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return out, err
+		case <-timer.C:
+		}
+		backoff *= 2
+	}
+}
+
+// fullJitter returns a random wait between zero and backoff inclusive
+func fullJitter(backoff time.Duration) time.Duration {
+	if backoff <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(int64(backoff) + 1))
+}
+
+// attempt performs one invocation through call and reports it to the configured observers
+func (k *KeyRotationHelper) attempt(ctx context.Context, op string, call func(context.Context) (string, error)) (string, error) {
+	var span Span
+	if k.tracer != nil {
+		ctx, span = k.tracer.StartSpan(ctx, op)
+	}
+	var usage *ResourceUsage
+	if k.resourceUsage {
+		ctx = withUsageSlot(ctx, &usage)
+	}
+	start := time.Now()
+	out, err := call(ctx)
+	elapsed := time.Since(start)
+	if span != nil {
+		span.End(err)
+	}
+
+	k.logger.LogEvent(Event{
+		Operation: op,
+		Duration:  elapsed,
+		Err:       err,
+		ExitCode:  exitCode(err),
+		Usage:     usage,
+	})
+	if k.metrics != nil {
+		k.metrics.ObserveDuration(op, elapsed, err)
+		if observer, ok := k.metrics.(ResourceUsageObserver); ok && usage != nil {
+			observer.ObserveResourceUsage(op, *usage)
+		}
+	}
+	return out, err
+}
+
+// isTransient reports whether err is the operating system temporarily refusing to start the
+// binary, as under memory or file-descriptor pressure. Only these failures are retried: a
+// binary that ran and failed, or answered false, would do the same again.
+func isTransient(err error) bool {
+	return errors.Is(err, syscall.EAGAIN) ||
+		errors.Is(err, syscall.ENOMEM) ||
+		errors.Is(err, syscall.EMFILE) ||
+		errors.Is(err, syscall.ENFILE)
+}
+
+// exitCode extracts the binary exit status reported by err
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var binErr *BinaryError
+	if errors.As(err, &binErr) {
+		return binErr.ExitCode
+	}
+	return -1
+}
@@ -0,0 +1,222 @@
+package keyrotation
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// eventRecorder is a Logger that keeps every event it receives
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (r *eventRecorder) LogEvent(e Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, e)
+}
+
+func (r *eventRecorder) recorded() []Event {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]Event(nil), r.events...)
+}
+
+func TestWithLogger_RecordsEvents(t *testing.T) {
+	recorder := &eventRecorder{}
+	helper := newFakeHelper(t, WithLogger(recorder))
+
+	encrypted, err := helper.EncryptApiKey("testApiKey123")
+	if err != nil {
+		t.Fatalf("EncryptApiKey failed: %v", err)
+	}
+	if _, err := helper.ValidateApiKeyToday("testApiKey123", encrypted); err != nil {
+		t.Fatalf("ValidateApiKeyToday failed: %v", err)
+	}
+	helper.run(context.Background(), "no-such-command", "testApiKey123")
+
+	events := recorder.recorded()
+	if len(events) != 3 {
+		t.Fatalf("Expected 3 events, got %d", len(events))
+	}
+	if events[0].Operation != "encrypt" || events[0].Err != nil || events[0].ExitCode != 0 || events[0].Duration <= 0 {
+		t.Errorf("Unexpected success event %+v", events[0])
+	}
+	if events[1].Operation != "validate" {
+		t.Errorf("Expected validate operation, got %s", events[1].Operation)
+	}
+	if events[2].Err == nil || events[2].ExitCode != 1 {
+		t.Errorf("Expected failure event with exit code 1, got %+v", events[2])
+	}
+}
+
+func TestWithLogger_Func(t *testing.T) {
+	var operations []string
+	helper := newFakeHelper(t, WithLogger(LoggerFunc(func(e Event) {
+		operations = append(operations, e.Operation)
+	})))
+
+	if _, err := helper.EncryptApiKeyBatch([]string{"first", "second"}); err != nil {
+		t.Fatalf("EncryptApiKeyBatch failed: %v", err)
+	}
+	if len(operations) != 1 || operations[0] != "encrypt-batch" {
+		t.Errorf("Expected a single encrypt-batch event, got %v", operations)
+	}
+}
+
+func TestWithLogger_NilRestoresDefault(t *testing.T) {
+	helper := newFakeHelper(t, WithLogger(nil))
+	if _, err := helper.EncryptApiKey("testApiKey123"); err != nil {
+		t.Fatalf("EncryptApiKey failed: %v", err)
+	}
+}
+
+// observation is a single MetricsObserver callback
+type observation struct {
+	op  string
+	d   time.Duration
+	err error
+}
+
+type observationRecorder struct {
+	mu           sync.Mutex
+	observations []observation
+}
+
+func (r *observationRecorder) ObserveDuration(op string, d time.Duration, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.observations = append(r.observations, observation{op, d, err})
+}
+
+func TestWithMetricsObserver(t *testing.T) {
+	recorder := &observationRecorder{}
+	helper := newFakeHelper(t, WithMetricsObserver(recorder))
+
+	testDate := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	encrypted, err := helper.EncryptApiKeyWithDate("testApiKey123", testDate)
+	if err != nil {
+		t.Fatalf("EncryptApiKeyWithDate failed: %v", err)
+	}
+	if _, err := helper.ValidateApiKey("testApiKey123", encrypted, testDate); err != nil {
+		t.Fatalf("ValidateApiKey failed: %v", err)
+	}
+	helper.run(context.Background(), "no-such-command", "testApiKey123")
+
+	if len(recorder.observations) != 3 {
+		t.Fatalf("Expected 3 observations, got %d", len(recorder.observations))
+	}
+	expectedOps := []string{"encrypt-date", "validate-date", "no-such-command"}
+	for i, o := range recorder.observations {
+		if o.op != expectedOps[i] {
+			t.Errorf("Observation %d: expected op %s, got %s", i, expectedOps[i], o.op)
+		}
+		if o.d <= 0 {
+			t.Errorf("Observation %d: expected positive duration", i)
+		}
+	}
+	if recorder.observations[0].err != nil || recorder.observations[2].err == nil {
+		t.Errorf("Expected outcome to be reported, got %+v", recorder.observations)
+	}
+}
+
+// spanRecorder is a Tracer that records the operation and outcome of every span it starts
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []string
+}
+
+type ctxKey struct{}
+
+func (r *spanRecorder) StartSpan(ctx context.Context, op string) (context.Context, Span) {
+	return context.WithValue(ctx, ctxKey{}, op), recordedSpan{r, op}
+}
+
+type recordedSpan struct {
+	r  *spanRecorder
+	op string
+}
+
+func (s recordedSpan) End(err error) {
+	s.r.mu.Lock()
+	defer s.r.mu.Unlock()
+	s.r.spans = append(s.r.spans, s.op+":"+strconv.FormatBool(err == nil))
+}
+
+func TestWithTracer(t *testing.T) {
+	tracer := &spanRecorder{}
+	var sawSpanContext bool
+	helper := newFakeHelper(t, WithTracer(tracer))
+	helper.engine = engineFunc(func(ctx context.Context, r request) (string, error) {
+		sawSpanContext = ctx.Value(ctxKey{}) == r.command
+		return execEngine{helper}.do(ctx, r)
+	})
+
+	encrypted, err := helper.EncryptApiKey("testApiKey123")
+	if err != nil {
+		t.Fatalf("EncryptApiKey failed: %v", err)
+	}
+	helper.ValidateApiKeyToday("testApiKey123", encrypted)
+	helper.run(context.Background(), "no-such-command", "testApiKey123")
+
+	if got, want := strings.Join(tracer.spans, ","), "encrypt:true,validate:true,no-such-command:false"; got != want {
+		t.Errorf("Expected spans %q, got %q", want, got)
+	}
+	if !sawSpanContext {
+		t.Error("Expected the invocation to run with the span's context")
+	}
+}
+
+// usageRecorder is a MetricsObserver that also records resource usage
+type usageRecorder struct {
+	observationRecorder
+	usage []string
+}
+
+func (r *usageRecorder) ObserveResourceUsage(op string, usage ResourceUsage) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.usage = append(r.usage, op)
+}
+
+func TestWithResourceUsage(t *testing.T) {
+	var events []Event
+	recorder := &usageRecorder{}
+	helper := newFakeHelper(t, WithResourceUsage(true), WithMetricsObserver(recorder),
+		WithLogger(LoggerFunc(func(e Event) { events = append(events, e) })))
+
+	if _, err := helper.EncryptApiKey("testApiKey123"); err != nil {
+		t.Fatalf("EncryptApiKey failed: %v", err)
+	}
+	if len(events) != 1 || events[0].Usage == nil {
+		t.Fatalf("Expected an event with resource usage, got %+v", events)
+	}
+	if events[0].Usage.UserTime < 0 || events[0].Usage.SystemTime < 0 {
+		t.Errorf("Expected non-negative CPU times, got %+v", *events[0].Usage)
+	}
+	if len(recorder.usage) != 1 || recorder.usage[0] != "encrypt" {
+		t.Errorf("Expected usage to be observed for encrypt, got %v", recorder.usage)
+	}
+}
+
+func TestWithResourceUsage_OffByDefault(t *testing.T) {
+	var events []Event
+	recorder := &usageRecorder{}
+	helper := newFakeHelper(t, WithMetricsObserver(recorder),
+		WithLogger(LoggerFunc(func(e Event) { events = append(events, e) })))
+
+	if _, err := helper.EncryptApiKey("testApiKey123"); err != nil {
+		t.Fatalf("EncryptApiKey failed: %v", err)
+	}
+	if len(events) != 1 || events[0].Usage != nil {
+		t.Errorf("Expected no resource usage without the option, got %+v", events)
+	}
+	if len(recorder.usage) != 0 {
+		t.Errorf("Expected no usage observations, got %v", recorder.usage)
+	}
+}
@@ -0,0 +1,476 @@
+package keyrotation
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// Option configures a KeyRotationHelper
+type Option func(*KeyRotationHelper)
+
+// fail records err as the helper's configuration error unless an earlier option already failed
+func (k *KeyRotationHelper) fail(err error) {
+	if k.configErr == nil {
+		k.configErr = err
+	}
+}
+
+// WithBinaryPath sets the path of the private binary. The default is ./keyrotation-binary.
+func WithBinaryPath(binaryPath string) Option {
+	return func(k *KeyRotationHelper) {
+		if binaryPath == "" {
+			k.fail(errors.New("keyrotation: binary path must not be empty"))
+			return
+		}
+		k.binaryPath = binaryPath
+		k.embedded = nil
+	}
+}
+
+// WithBinaryPaths sets the binary to the first of paths that names an executable file, checking each
+// in order when the option is applied; bare names are looked up on PATH. If none is usable the
+// option fails with an error wrapping ErrBinaryNotFound that lists why each path was rejected.
+func WithBinaryPaths(paths ...string) Option {
+	return func(k *KeyRotationHelper) {
+		if len(paths) == 0 {
+			k.fail(errors.New("keyrotation: no binary paths given"))
+			return
+		}
+		var errs []error
+		for _, path := range paths {
+			resolved, err := resolveBinaryPath(path)
+			if err == nil {
+				err = checkBinary(resolved)
+			}
+			if err == nil {
+				k.binaryPath = resolved
+				k.embedded = nil
+				return
+			}
+			errs = append(errs, err)
+		}
+		k.fail(fmt.Errorf("%w: none of %d candidate paths is usable: %w", ErrBinaryNotFound, len(paths), errors.Join(errs...)))
+	}
+}
+
+// WithTimeout sets the maximum duration of a single binary invocation. Zero disables the timeout.
+func WithTimeout(timeout time.Duration) Option {
+	return func(k *KeyRotationHelper) {
+		k.timeout = timeout
+	}
+}
+
+// WithLegacyArgv passes the plaintext API key as a command-line argument, for older binaries
+// that do not support the stdin protocol. Without it the helper switches to argv by itself the
+// first time the binary rejects --stdin; enabling it only skips that failed attempt. Keys passed
+// this way are visible to other users of the host through ps and /proc, so only enable it when
+// the binary cannot be upgraded.
+func WithLegacyArgv(enabled bool) Option {
+	return func(k *KeyRotationHelper) {
+		k.legacyArgv = enabled
+	}
+}
+
+// WithAlgorithm sets the hash used to encrypt and validate keys. The default is AlgorithmSHA256.
+// Calls fail with an error wrapping ErrUnsupportedAlgorithm if the binary does not implement it.
+func WithAlgorithm(algorithm Algorithm) Option {
+	return func(k *KeyRotationHelper) {
+		if algorithm == "" {
+			k.fail(errors.New("keyrotation: algorithm must not be empty"))
+			return
+		}
+		k.algorithm = algorithm
+	}
+}
+
+// WithPreHash makes the helper send the binary the hex SHA256 of salt followed by the API key
+// instead of the key itself, so the plaintext never reaches the subprocess. Ciphertexts are then
+// computed over the digest: keys encrypted with one salt, or without pre-hashing, only validate
+// with a helper configured the same way.
+func WithPreHash(salt []byte) Option {
+	return func(k *KeyRotationHelper) {
+		k.preHashSalt = append([]byte{}, salt...)
+	}
+}
+
+// WithNamespace scopes every API key to namespace, such as a tenant ID, before it reaches the
+// binary, so the same key encrypted under different namespaces yields unrelated ciphertexts and a
+// ciphertext issued for one namespace never validates under another. Namespacing is applied before
+// WithPreHash. The namespace must not contain tabs or line breaks; an empty namespace disables it.
+func WithNamespace(namespace string) Option {
+	return func(k *KeyRotationHelper) {
+		if strings.ContainsAny(namespace, "\t\r\n") {
+			k.fail(fmt.Errorf("keyrotation: namespace %q must not contain tabs or line breaks", namespace))
+			return
+		}
+		k.namespace = namespace
+	}
+}
+
+// WithEarliestDate rejects encryption for dates before the date of t with ErrDateOutOfRange, to
+// catch date math that went backwards. There is no lower bound by default.
+func WithEarliestDate(t time.Time) Option {
+	return func(k *KeyRotationHelper) {
+		k.earliestDate = t
+	}
+}
+
+// WithMaxFutureDays rejects encryption for dates more than days after today with ErrDateOutOfRange,
+// to catch time zone and clock bugs that would produce ciphertexts nobody can verify. There is no
+// upper bound by default.
+func WithMaxFutureDays(days int) Option {
+	return func(k *KeyRotationHelper) {
+		if days < 1 {
+			k.fail(fmt.Errorf("keyrotation: max future days must be positive, got %d", days))
+			return
+		}
+		k.maxFutureDays = days
+	}
+}
+
+// WithPepper switches the helper to HMAC mode: keys are computed as an HMAC of the rotation date
+// and API key, with pepper as the HMAC key, so nobody who lacks the pepper can reproduce or
+// precompute them even knowing the scheme. The pepper reaches the binary through its environment,
+// never its arguments. Encrypting and validating must use the same pepper. The pepper must not be
+// empty, and binaries without HMAC mode fail with an error wrapping ErrUnsupported.
+func WithPepper(pepper []byte) Option {
+	return func(k *KeyRotationHelper) {
+		if len(pepper) == 0 {
+			k.fail(errors.New("keyrotation: pepper must not be empty"))
+			return
+		}
+		k.pepper = append([]byte{}, pepper...)
+	}
+}
+
+// WithCiphertextEncoding declares the encoding the binary emits ciphertexts in, EncodingHex by
+// default. Validation accepts stored ciphertexts in any encoding, converting them to this one
+// before handing them to the binary, so values stored across binary versions keep validating.
+func WithCiphertextEncoding(encoding Encoding) Option {
+	return func(k *KeyRotationHelper) {
+		if err := checkEncoding(encoding); err != nil {
+			k.fail(err)
+			return
+		}
+		k.encoding = encoding
+	}
+}
+
+// WithDigestEncoding selects the encoding the in-memory engine of NewInMemory emits ciphertexts
+// in, EncodingHex by default, so its output matches byte for byte what the binary prints in a
+// given environment. For helpers that run the binary it is the same as WithCiphertextEncoding.
+func WithDigestEncoding(encoding Encoding) Option {
+	return WithCiphertextEncoding(encoding)
+}
+
+// WithCiphertextCheck controls whether ciphertexts are checked before validation to have the
+// length and characters of a digest of the configured algorithm, in either encoding. Malformed
+// ciphertexts, such as corrupted stored values, then fail with ErrMalformedCiphertext instead of
+// validating as false. The check is on by default; disable it for binaries whose output has
+// another shape.
+func WithCiphertextCheck(enabled bool) Option {
+	return func(k *KeyRotationHelper) {
+		k.skipCiphertextCheck = !enabled
+	}
+}
+
+// WithResultTokens sets the outputs the binary prints for a valid and an invalid key, for forks
+// that print something other than "true" and "false". They are matched case-insensitively after
+// trimming whitespace; any other output still fails with ErrUnexpectedOutput.
+func WithResultTokens(trueToken, falseToken string) Option {
+	return func(k *KeyRotationHelper) {
+		if trueToken == "" || falseToken == "" || strings.EqualFold(trueToken, falseToken) {
+			k.fail(fmt.Errorf("keyrotation: result tokens %q and %q must be non-empty and distinct", trueToken, falseToken))
+			return
+		}
+		k.trueToken, k.falseToken = trueToken, falseToken
+	}
+}
+
+// WithMaxKeyLength sets the longest API key, in bytes, that operations accept. Longer keys fail
+// with ErrKeyTooLong without invoking the binary. The default is DefaultMaxKeyLength.
+func WithMaxKeyLength(n int) Option {
+	return func(k *KeyRotationHelper) {
+		if n < 1 {
+			k.fail(errors.New("keyrotation: max key length must be positive"))
+			return
+		}
+		k.maxKeyLen = n
+	}
+}
+
+// WithLocation sets the time zone in which rotation dates are determined. A key rotates at
+// midnight in loc: every date string is computed from the instant converted to loc, so two
+// instants share a ciphertext exactly when they fall on the same calendar day there.
+// The default, and a nil loc, is UTC.
+func WithLocation(loc *time.Location) Option {
+	return func(k *KeyRotationHelper) {
+		if loc == nil {
+			loc = time.UTC
+		}
+		k.location = loc
+	}
+}
+
+// WithClock makes the helper take the current time from clock instead of the system clock, so
+// tests can pin "today" and exercise day boundaries deterministically. The date is then computed
+// in Go and passed to the binary's explicit-date subcommands rather than left to the binary's own
+// clock. Batch calls send it too, through the date-taking batch subcommands, and fail with
+// ErrUnsupported on binaries without them. A nil clock restores the system clock.
+func WithClock(clock func() time.Time) Option {
+	return func(k *KeyRotationHelper) {
+		k.clock = clock
+	}
+}
+
+// WithLogger sets a Logger that is notified after every binary invocation. A nil logger
+// restores the default, which discards events.
+func WithLogger(logger Logger) Option {
+	return func(k *KeyRotationHelper) {
+		if logger == nil {
+			logger = nopLogger{}
+		}
+		k.logger = logger
+	}
+}
+
+// WithMetricsObserver sets a MetricsObserver that is notified after every binary invocation
+func WithMetricsObserver(observer MetricsObserver) Option {
+	return func(k *KeyRotationHelper) {
+		k.metrics = observer
+	}
+}
+
+// WithResourceUsage records the user and system CPU time of every binary invocation in
+// Event.Usage and reports it to a MetricsObserver that implements ResourceUsageObserver. It is
+// off by default.
+func WithResourceUsage(enabled bool) Option {
+	return func(k *KeyRotationHelper) {
+		k.resourceUsage = enabled
+	}
+}
+
+// WithTracer sets a Tracer that starts a span around every binary invocation. See the
+// keyrotationotel package for OpenTelemetry.
+func WithTracer(tracer Tracer) Option {
+	return func(k *KeyRotationHelper) {
+		k.tracer = tracer
+	}
+}
+
+// WithExtraArgs passes args to the binary on every invocation, as an escape hatch for flags the
+// binary supports before the wrapper exposes them. They are inserted after the wrapper's own
+// flags (such as --algo and --stdin) and immediately before the subcommand, and are also given
+// to the process started by NewPersistent.
+//
+// The wrapper does not interpret args, so a flag the binary does not recognize makes every call
+// fail, and one that changes the binary's output format breaks result parsing. Arguments are
+// visible to other users of the host through ps and /proc and appear in BinaryError.Command, so
+// never use them for secrets.
+func WithExtraArgs(args []string) Option {
+	return func(k *KeyRotationHelper) {
+		k.extraArgs = append([]string(nil), args...)
+	}
+}
+
+// WithExecWrapper runs the binary under a wrapper command, such as a sandbox, so that the
+// effective command line becomes the wrapper, its args, and then the binary with its arguments:
+// WithExecWrapper("firejail", "--quiet") runs "firejail --quiet <binary> ...". The wrapper must pass
+// the binary's stdin, stdout and exit status through. An empty command removes the wrapper.
+func WithExecWrapper(command string, args ...string) Option {
+	return func(k *KeyRotationHelper) {
+		k.wrapper = nil
+		if command != "" {
+			k.wrapper = append([]string{command}, args...)
+		}
+	}
+}
+
+// WithDryRun stops the helper from running the binary. Each call instead reports the command it
+// would have executed to the Logger, as an Event whose Command is the full argv, and returns a
+// stubbed result: a fixed ciphertext of zeros for encryptions and false for validations. It lets
+// the shape of every invocation be audited, for example to confirm no secret is placed on argv.
+func WithDryRun(enabled bool) Option {
+	return func(k *KeyRotationHelper) {
+		k.dryRun = enabled
+	}
+}
+
+// WithWorkingDir runs the binary in dir, so it can find sibling resources such as its
+// configuration regardless of where the service was started. A relative binary path still refers
+// to the service's own working directory. The directory must exist when the option is applied.
+func WithWorkingDir(dir string) Option {
+	return func(k *KeyRotationHelper) {
+		info, err := os.Stat(dir)
+		if err == nil && !info.IsDir() {
+			err = errors.New("not a directory")
+		}
+		if err != nil {
+			k.fail(fmt.Errorf("keyrotation: invalid working directory %s: %w", dir, err))
+			return
+		}
+		k.workDir = dir
+	}
+}
+
+// WithEnv replaces the environment the binary inherits from the service with env, a list of
+// "KEY=value" entries, so secrets in the service's environment do not leak into it. An empty list
+// runs the binary with no environment at all. By default the service's environment is inherited.
+func WithEnv(env []string) Option {
+	return func(k *KeyRotationHelper) {
+		k.env = append([]string{}, env...)
+	}
+}
+
+// WithRedaction controls whether the plaintext API key is replaced by RedactedKey in the commands
+// reported by BinaryError, timeout errors and dry-run events, and whether the key, pepper and
+// pre-hash salt are removed from error messages and the binary's stderr should it echo them;
+// secrets under 8 bytes are removed where they stand alone as a word. It is on by default;
+// disabling it exposes secrets wherever errors are logged, so only do so while debugging locally.
+func WithRedaction(enabled bool) Option {
+	return func(k *KeyRotationHelper) {
+		k.unredacted = !enabled
+	}
+}
+
+// WithStdoutTee copies everything the binary writes to stdout to w, while the output is still
+// parsed as usual. Writes from concurrent invocations are serialized but may interleave between
+// calls, and errors returned by w are ignored. A nil w disables the copy.
+func WithStdoutTee(w io.Writer) Option {
+	return func(k *KeyRotationHelper) {
+		k.stdoutTee = newTeeWriter(w)
+	}
+}
+
+// WithStderrTee copies everything the binary writes to stderr to w, in the same way as WithStdoutTee
+func WithStderrTee(w io.Writer) Option {
+	return func(k *KeyRotationHelper) {
+		k.stderrTee = newTeeWriter(w)
+	}
+}
+
+// WithRetry retries binary invocations that fail because the operating system could not start
+// the process (for example fork/exec returning EAGAIN under memory pressure). maxAttempts counts
+// the first attempt, so values below 2 disable retries. The wait before each retry starts at
+// backoff and doubles every time (see WithRetryJitter); no retry is attempted if it would outlast
+// the context deadline.
+// Failures of a binary that actually ran, including a false validation result, are never retried.
+func WithRetry(maxAttempts int, backoff time.Duration) Option {
+	return func(k *KeyRotationHelper) {
+		k.retryAttempts = maxAttempts
+		k.retryBackoff = backoff
+	}
+}
+
+// WithRetryJitter makes WithRetry wait a random time between zero and the current backoff before
+// each retry ("full jitter"), so many callers that hit the same transient failure do not retry in
+// lockstep. The backoff itself still doubles every time.
+func WithRetryJitter(enabled bool) Option {
+	return func(k *KeyRotationHelper) {
+		k.retryJitter = enabled
+	}
+}
+
+// WithValidationCache memoizes up to size validation results for the current rotation date, so
+// repeated validations of the same key skip the binary. Results are keyed on the API key, the
+// encrypted key and the date, and the whole cache is discarded when the date rolls over in the
+// helper's location, so a result is never reused across days. A size of zero or less disables it.
+func WithValidationCache(size int) Option {
+	return func(k *KeyRotationHelper) {
+		k.cache = nil
+		if size > 0 {
+			k.cache = newValidationCache(size)
+		}
+	}
+}
+
+// WithWindowConcurrency lets the windowed validations (ValidateApiKeyWithinDays,
+// ValidateAndResolveDate, ValidateApiKeyInRange and ValidateApiKeyWithGrace) check up to n dates
+// at once instead of one after another, cutting the latency of wide windows. The first match
+// cancels the invocations still in flight. Parallel checks may start more invocations than a
+// sequential search would have needed. Values below 1 are rejected; 1, the default, is sequential.
+func WithWindowConcurrency(n int) Option {
+	return func(k *KeyRotationHelper) {
+		if n < 1 {
+			k.fail(fmt.Errorf("keyrotation: window concurrency must be at least 1, got %d", n))
+			return
+		}
+		k.windowConcurrency = n
+	}
+}
+
+// WithCommandRunner runs per-call binary invocations through runner instead of os/exec, for tests
+// that exercise the wrapper without the binary or to run it in a sandbox. The runner receives the
+// binary path as configured, without a PATH lookup or existence check. WithWorkingDir and WithEnv
+// only apply to the default runner, so a runner used with WithPepper must pass KEYROTATION_PEPPER
+// on itself. NewPersistent keeps its long-lived process on os/exec.
+func WithCommandRunner(runner CommandRunner) Option {
+	return func(k *KeyRotationHelper) {
+		k.runner = runner
+	}
+}
+
+// WithRotationInterval rotates keys every interval instead of daily, so a ciphertext is only valid
+// within the interval it was computed for. The interval must be a whole number of hours dividing a
+// day (such as 1h, 6h or 12h), counted from midnight in the helper's location, and the date string
+// becomes yyyyMMddHH for the start of the interval. The binary must support sub-daily rotation;
+// otherwise calls fail with an error wrapping ErrUnsupported. Validation windows and rotation
+// schedules step in days and are rejected with a sub-daily interval.
+func WithRotationInterval(interval time.Duration) Option {
+	return func(k *KeyRotationHelper) {
+		if err := checkInterval(interval); err != nil {
+			k.fail(err)
+			return
+		}
+		k.interval = interval
+	}
+}
+
+// WithBinaryDateLayout sets the time.Format layout of the date arguments sent to the binary's
+// explicit-date subcommands, for binary variants that expect something other than the default
+// "2006-01-02" (or "2006-01-02T15" with a sub-daily WithRotationInterval), such as "20060102".
+// The layout must encode the year, month and day, and also the hour with a sub-daily interval,
+// which is checked once all options are applied, whatever their order. It only changes how dates
+// are passed, not the date string the binary hashes.
+func WithBinaryDateLayout(layout string) Option {
+	return func(k *KeyRotationHelper) {
+		date := time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC)
+		if parsed, err := time.Parse(layout, date.Format(layout)); err != nil || !parsed.Equal(date) {
+			k.fail(fmt.Errorf("keyrotation: binary date layout %q must encode the year, month and day", layout))
+			return
+		}
+		k.binaryDateLayout = layout
+	}
+}
+
+// checkOptions fails the helper for options that are only invalid in combination, once every
+// option has been applied
+func (k *KeyRotationHelper) checkOptions() {
+	if k.binaryDateLayout != "" && k.subDaily() {
+		date := time.Date(2024, 12, 31, 17, 0, 0, 0, time.UTC)
+		if parsed, err := time.Parse(k.binaryDateLayout, date.Format(k.binaryDateLayout)); err != nil || !parsed.Equal(date) {
+			k.fail(fmt.Errorf("keyrotation: binary date layout %q must encode the hour with a sub-daily rotation interval", k.binaryDateLayout))
+		}
+	}
+}
+
+// WithRequiredBinaryVersion makes the helper check the binary's version subcommand before its
+// first call and fail every call with ErrBinaryVersionMismatch unless the version satisfies
+// constraint: comma-separated comparisons such as ">=1.4.0, <2" using >=, >, <=, <, = or !=, where
+// a bare version must match exactly. Versions are compared as major.minor.patch. This turns a
+// rollout of an incompatible binary into a loud failure rather than ciphertexts that later fail
+// to validate.
+func WithRequiredBinaryVersion(constraint string) Option {
+	return func(k *KeyRotationHelper) {
+		c, err := parseVersionConstraint(constraint)
+		if err != nil {
+			k.fail(err)
+			return
+		}
+		k.requiredVersion = c
+	}
+}
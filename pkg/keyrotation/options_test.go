@@ -0,0 +1,239 @@
+package keyrotation
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNewWithOptions_Defaults(t *testing.T) {
+	helper, err := NewWithOptions()
+	if err != nil {
+		t.Fatalf("NewWithOptions failed: %v", err)
+	}
+	reference := New()
+	if helper.binaryPath != reference.binaryPath || helper.timeout != reference.timeout || helper.location != reference.location {
+		t.Errorf("Expected NewWithOptions() to match New(), got %+v and %+v", helper, reference)
+	}
+}
+
+func TestNewWithOptions_AppliesOptions(t *testing.T) {
+	bangkok := time.FixedZone("ICT", 7*60*60)
+	helper, err := NewWithOptions(
+		WithBinaryPath("/opt/keyrotation/keyrotation-binary"),
+		WithTimeout(3*time.Second),
+		WithLocation(bangkok),
+	)
+	if err != nil {
+		t.Fatalf("NewWithOptions failed: %v", err)
+	}
+	if helper.binaryPath != "/opt/keyrotation/keyrotation-binary" {
+		t.Errorf("Unexpected binary path %s", helper.binaryPath)
+	}
+	if helper.timeout != 3*time.Second {
+		t.Errorf("Unexpected timeout %s", helper.timeout)
+	}
+	if helper.location != bangkok {
+		t.Errorf("Unexpected location %s", helper.location)
+	}
+}
+
+func TestNewWithOptions_InvalidOption(t *testing.T) {
+	if _, err := NewWithOptions(WithBinaryPath("")); err == nil {
+		t.Error("Expected error for empty binary path")
+	}
+
+	// Constructors without an error return report it from every call instead
+	helper := NewWithBinaryPath("")
+	if _, err := helper.EncryptApiKey("testApiKey123"); err == nil {
+		t.Error("Expected configuration error from EncryptApiKey")
+	}
+}
+
+func TestNew_BinaryEnvVar(t *testing.T) {
+	t.Setenv(BinaryEnvVar, "/opt/keyrotation/from-env")
+	if helper := New(); helper.binaryPath != "/opt/keyrotation/from-env" {
+		t.Errorf("Expected binary path from %s, got %s", BinaryEnvVar, helper.binaryPath)
+	}
+	if helper := NewWithBinaryPath("/explicit/keyrotation-binary"); helper.binaryPath != "/explicit/keyrotation-binary" {
+		t.Errorf("Expected explicit path to take precedence over %s, got %s", BinaryEnvVar, helper.binaryPath)
+	}
+
+	t.Setenv(BinaryEnvVar, "")
+	os.Unsetenv(BinaryEnvVar)
+	if helper := New(); helper.binaryPath != defaultBinaryPath {
+		t.Errorf("Expected default path with %s unset, got %s", BinaryEnvVar, helper.binaryPath)
+	}
+}
+
+func TestWithExtraArgs(t *testing.T) {
+	t.Setenv(fakeModeEnv, "argv")
+	extra := []string{"--trace", "--format=plain"}
+	stdinHelper := newFakeHelper(t, WithAlgorithm(AlgorithmSHA512), WithExtraArgs(extra))
+	legacyHelper := newFakeHelper(t, WithLegacyArgv(true), WithExtraArgs(extra))
+	extra[0] = "--mutated"
+
+	cases := []struct {
+		name string
+		call func() (string, error)
+		want string
+	}{
+		{"stdin", func() (string, error) { return stdinHelper.EncryptApiKey("testApiKey123") }, "--algo sha512 --stdin --trace --format=plain encrypt"},
+		{"legacy", func() (string, error) { return legacyHelper.EncryptApiKey("testApiKey123") }, "--trace --format=plain encrypt testApiKey123"},
+		{"keyless", legacyHelper.BinaryVersion, "--trace --format=plain version"},
+	}
+	for _, c := range cases {
+		got, err := c.call()
+		if err != nil {
+			t.Fatalf("%s: call failed: %v", c.name, err)
+		}
+		if got != c.want {
+			t.Errorf("%s: expected argv %q, got %q", c.name, c.want, got)
+		}
+	}
+}
+
+func TestWithWorkingDir(t *testing.T) {
+	t.Setenv(fakeModeEnv, "pwd")
+	dir, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	relative, err := filepath.Rel(cwd, exe)
+	if err != nil {
+		t.Skipf("Test executable has no path relative to the working directory: %v", err)
+	}
+
+	helper := NewWithBinaryPath("./"+relative, WithWorkingDir(dir))
+	if got, err := helper.BinaryVersion(); err != nil || got != dir {
+		t.Errorf("Expected binary to run in %s, got %q, %v", dir, got, err)
+	}
+}
+
+func TestWithWorkingDir_Invalid(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(file, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, dir := range []string{filepath.Join(t.TempDir(), "missing"), file} {
+		if _, err := NewWithOptions(WithWorkingDir(dir)); err == nil {
+			t.Errorf("Expected WithWorkingDir(%s) to be rejected", dir)
+		}
+	}
+}
+
+func TestWithEnv(t *testing.T) {
+	t.Setenv(fakeModeEnv, "env")
+	t.Setenv("KEYROTATION_TEST_SECRET", "leaked")
+
+	// The fake binary needs fakeBinaryEnv and fakeModeEnv to behave as one
+	env := []string{fakeBinaryEnv + "=1", fakeModeEnv + "=env", "BINARY_CONFIG=/etc/keyrotation"}
+	helper := newFakeHelper(t, WithEnv(env))
+	env[2] = "BINARY_CONFIG=mutated"
+
+	got, err := helper.BinaryVersion()
+	if err != nil {
+		t.Fatalf("BinaryVersion failed: %v", err)
+	}
+	if want := fakeBinaryEnv + "=1," + fakeModeEnv + "=env,BINARY_CONFIG=/etc/keyrotation"; got != want {
+		t.Errorf("Expected environment %q, got %q", want, got)
+	}
+
+	inherited, err := newFakeHelper(t).BinaryVersion()
+	if err != nil || !strings.Contains(inherited, "KEYROTATION_TEST_SECRET=leaked") {
+		t.Errorf("Expected environment to be inherited by default, got %q, %v", inherited, err)
+	}
+}
+
+func TestWithResultTokens(t *testing.T) {
+	helper := newFakeHelper(t, WithResultTokens("1", "0"))
+	encrypted, err := helper.EncryptApiKey("testApiKey123")
+	if err != nil {
+		t.Fatalf("EncryptApiKey failed: %v", err)
+	}
+
+	t.Setenv(fakeModeEnv, "digits")
+	if valid, err := helper.ValidateApiKeyToday("testApiKey123", encrypted); err != nil || !valid {
+		t.Errorf("Expected 1 to be read as valid, got %v, %v", valid, err)
+	}
+	if valid, err := helper.ValidateApiKeyToday("testApiKey123", wrongCiphertext); err != nil || valid {
+		t.Errorf("Expected 0 to be read as invalid, got %v, %v", valid, err)
+	}
+	if _, err := newFakeHelper(t).ValidateApiKeyToday("testApiKey123", encrypted); !errors.Is(err, ErrUnexpectedOutput) {
+		t.Errorf("Expected default tokens to reject 1, got %v", err)
+	}
+
+	t.Setenv(fakeModeEnv, "")
+	if _, err := helper.ValidateApiKeyToday("testApiKey123", encrypted); !errors.Is(err, ErrUnexpectedOutput) {
+		t.Errorf("Expected custom tokens to reject true, got %v", err)
+	}
+	for _, tokens := range [][2]string{{"", "0"}, {"yes", "YES"}} {
+		if _, err := NewWithOptions(WithResultTokens(tokens[0], tokens[1])); err == nil {
+			t.Errorf("Expected tokens %q to be rejected", tokens)
+		}
+	}
+}
+
+func TestWithBinaryDateLayout(t *testing.T) {
+	t.Setenv(fakeModeEnv, "argv")
+	helper := newFakeHelper(t, WithBinaryDateLayout("20060102"), WithLegacyArgv(true))
+	date := time.Date(2024, 1, 15, 13, 0, 0, 0, time.UTC)
+
+	out, err := helper.EncryptApiKeyWithDate("testApiKey123", date)
+	if err != nil || out != "encrypt-date testApiKey123 20240115" {
+		t.Errorf("Expected the date in the configured layout, got %q, %v", out, err)
+	}
+	hourly := newFakeHelper(t, WithBinaryDateLayout("2006010215"), WithRotationInterval(6*time.Hour), WithLegacyArgv(true))
+	if out, err := hourly.EncryptApiKeyWithDate("testApiKey123", date); err != nil || !strings.HasSuffix(out, " 2024011512") {
+		t.Errorf("Expected the start of the interval in the configured layout, got %q, %v", out, err)
+	}
+	t.Setenv(fakeModeEnv, "")
+
+	inMemory := NewInMemory(WithBinaryDateLayout("20060102"))
+	encrypted, err := inMemory.EncryptApiKeyWithDate("testApiKey123", date)
+	if err != nil || encrypted != fakeHash("testApiKey123", date) {
+		t.Errorf("Expected the layout not to change the ciphertext, got %s, %v", encrypted, err)
+	}
+	if valid, err := inMemory.ValidateApiKey("testApiKey123", encrypted, date); err != nil || !valid {
+		t.Errorf("Expected key to validate, got %v, %v", valid, err)
+	}
+
+	for _, layout := range []string{"", "2006-01", "15:04"} {
+		if _, err := NewWithOptions(WithBinaryDateLayout(layout)); err == nil {
+			t.Errorf("Expected layout %q to be rejected", layout)
+		}
+	}
+}
+
+func TestWithBinaryDateLayout_RequiresHourWhenSubDaily(t *testing.T) {
+	for name, opts := range map[string][]Option{
+		"layout first":   {WithBinaryDateLayout("20060102"), WithRotationInterval(time.Hour)},
+		"interval first": {WithRotationInterval(time.Hour), WithBinaryDateLayout("20060102")},
+	} {
+		if _, err := NewWithOptions(opts...); err == nil {
+			t.Errorf("%s: Expected a layout without the hour to be rejected with an hourly interval", name)
+		}
+		if _, err := NewInMemory(opts...).EncryptApiKey("testApiKey123"); err == nil {
+			t.Errorf("%s: Expected the in-memory helper to report the invalid layout", name)
+		}
+	}
+
+	daily := NewInMemory(WithBinaryDateLayout("20060102"))
+	if _, err := daily.Clone(WithRotationInterval(time.Hour)).EncryptApiKey("testApiKey123"); err == nil {
+		t.Error("Expected a clone made hourly to report the invalid layout")
+	}
+	if _, err := NewWithOptions(WithBinaryDateLayout("2006010215"), WithRotationInterval(time.Hour)); err != nil {
+		t.Errorf("Expected a layout with the hour to be accepted, got %v", err)
+	}
+}
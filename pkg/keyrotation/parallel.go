@@ -0,0 +1,76 @@
+package keyrotation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// EncryptApiKeyBatchParallel encrypts many API keys with the current UTC date by running up to
+// concurrency binary invocations at once, for binaries without the encrypt-batch subcommand.
+// Every key is encrypted for the same date, even if the batch straddles midnight. Results are
+// aligned with keys; once a key fails no further invocations are started, and the error is a
+// *BatchError naming the failed index while the returned slice holds the ciphertexts that completed.
+func (k *KeyRotationHelper) EncryptApiKeyBatchParallel(keys []string, concurrency int) ([]string, error) {
+	return k.EncryptApiKeyBatchParallelContext(context.Background(), keys, concurrency)
+}
+
+// EncryptApiKeyBatchParallelContext is like EncryptApiKeyBatchParallel but stops starting
+// invocations, and kills those in flight, once ctx is done
+func (k *KeyRotationHelper) EncryptApiKeyBatchParallelContext(ctx context.Context, keys []string, concurrency int) ([]string, error) {
+	if concurrency < 1 {
+		return nil, errors.New("keyrotation: concurrency must be at least 1")
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	now := k.now()
+	results := make([]string, len(keys))
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		firstErr error
+	)
+	slots := make(chan struct{}, concurrency)
+launch:
+	for i, key := range keys {
+		select {
+		case slots <- struct{}{}:
+		case <-runCtx.Done():
+			break launch
+		}
+		if runCtx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			result, err := k.EncryptApiKeyWithDateContext(runCtx, key, now)
+			if err != nil {
+				mu.Lock()
+				if firstErr == nil {
+					firstErr = &BatchError{Index: i, Err: err}
+					cancel()
+				}
+				mu.Unlock()
+				return
+			}
+			results[i] = result
+		}()
+	}
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return results, err
+	}
+	if firstErr != nil {
+		return results, fmt.Errorf("failed to encrypt API key batch: %w", firstErr)
+	}
+	return results, nil
+}
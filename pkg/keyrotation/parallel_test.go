@@ -0,0 +1,107 @@
+package keyrotation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestKeyRotationHelper_EncryptApiKeyBatchParallel(t *testing.T) {
+	t.Setenv(fakeModeEnv, "legacy")
+	helper := newFakeHelper(t, WithLegacyArgv(true))
+	keys := make([]string, 20)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("key-%d", i)
+	}
+
+	results, err := helper.EncryptApiKeyBatchParallel(keys, 4)
+	if err != nil {
+		t.Fatalf("EncryptApiKeyBatchParallel failed: %v", err)
+	}
+	today := time.Now().UTC()
+	for i, key := range keys {
+		if results[i] != fakeHash(key, today) {
+			t.Errorf("Result %d is not aligned with its key", i)
+		}
+	}
+
+	if _, err := helper.EncryptApiKeyBatchParallel(keys, 0); err == nil {
+		t.Error("Expected error for zero concurrency")
+	}
+}
+
+// engineFunc adapts a function to the engine interface
+type engineFunc func(ctx context.Context, r request) (string, error)
+
+func (f engineFunc) do(ctx context.Context, r request) (string, error) {
+	return f(ctx, r)
+}
+
+// concurrencyTracker is a Logger that records the peak number of overlapping invocations
+type concurrencyTracker struct {
+	mu           sync.Mutex
+	active, peak int
+}
+
+func (c *concurrencyTracker) start() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.active++
+	c.peak = max(c.peak, c.active)
+}
+
+func (c *concurrencyTracker) LogEvent(Event) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.active--
+}
+
+func TestKeyRotationHelper_EncryptApiKeyBatchParallelBounded(t *testing.T) {
+	tracker := &concurrencyTracker{}
+	helper := NewInMemory(WithLogger(tracker))
+	helper.engine = engineFunc(func(ctx context.Context, r request) (string, error) {
+		tracker.start()
+		time.Sleep(5 * time.Millisecond)
+		return inMemoryEngine{algorithm: AlgorithmSHA256}.do(ctx, r)
+	})
+
+	if _, err := helper.EncryptApiKeyBatchParallel([]string{"a", "b", "c", "d", "e", "f", "g", "h"}, 3); err != nil {
+		t.Fatalf("EncryptApiKeyBatchParallel failed: %v", err)
+	}
+	if tracker.peak > 3 {
+		t.Errorf("Expected at most 3 concurrent invocations, got %d", tracker.peak)
+	}
+}
+
+func TestKeyRotationHelper_EncryptApiKeyBatchParallelStopsOnError(t *testing.T) {
+	recorder := &eventRecorder{}
+	helper := newFakeHelper(t, WithLogger(recorder))
+	keys := []string{"a", "", "c", "d", "e", "f", "g", "h"}
+
+	results, err := helper.EncryptApiKeyBatchParallel(keys, 1)
+	var batchErr *BatchError
+	if !errors.As(err, &batchErr) || batchErr.Index != 1 {
+		t.Fatalf("Expected BatchError at index 1, got %v", err)
+	}
+	if results[0] == "" || results[1] != "" {
+		t.Errorf("Expected completed results to be kept, got %q", results)
+	}
+	if !errors.Is(err, ErrEmptyKey) {
+		t.Errorf("Expected ErrEmptyKey, got %v", err)
+	}
+	if calls := len(recorder.recorded()); calls != 1 {
+		t.Errorf("Expected no invocations after the failure, got %d", calls)
+	}
+}
+
+func TestKeyRotationHelper_EncryptApiKeyBatchParallelCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := newFakeHelper(t).EncryptApiKeyBatchParallelContext(ctx, []string{"a", "b"}, 2); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
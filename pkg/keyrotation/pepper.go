@@ -0,0 +1,27 @@
+package keyrotation
+
+import (
+	"encoding/hex"
+	"os"
+)
+
+// hmacFlag switches the binary to HMAC mode. Like algoFlag it precedes the subcommand, and it is
+// only sent when a pepper is configured.
+const hmacFlag = "--hmac"
+
+// pepperEnvVar carries the hex-encoded pepper to the binary in HMAC mode. The environment of a
+// process is readable only by its owner, unlike its arguments.
+const pepperEnvVar = "KEYROTATION_PEPPER"
+
+// processEnv returns the environment to run the binary with: the one set with WithEnv, or nil to
+// inherit the service's, plus the pepper in HMAC mode
+func (k *KeyRotationHelper) processEnv() []string {
+	if k.pepper == nil {
+		return k.env
+	}
+	env := k.env
+	if env == nil {
+		env = os.Environ()
+	}
+	return append(env[:len(env):len(env)], pepperEnvVar+"="+hex.EncodeToString(k.pepper))
+}
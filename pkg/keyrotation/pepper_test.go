@@ -0,0 +1,67 @@
+package keyrotation
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithPepper_HMAC(t *testing.T) {
+	pepper := []byte("server-side-secret")
+	helper := newFakeHelper(t, WithPepper(pepper))
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	encrypted, err := helper.EncryptApiKeyWithDate("testApiKey123", date)
+	if err != nil {
+		t.Fatalf("EncryptApiKeyWithDate failed: %v", err)
+	}
+	mac := hmac.New(sha256.New, pepper)
+	mac.Write([]byte("20240115testApiKey123"))
+	if want := hex.EncodeToString(mac.Sum(nil)); encrypted != want {
+		t.Errorf("Expected HMAC %s, got %s", want, encrypted)
+	}
+
+	if valid, err := helper.ValidateApiKey("testApiKey123", encrypted, date); err != nil || !valid {
+		t.Errorf("Expected key to validate with the same pepper, got %v, %v", valid, err)
+	}
+	if valid, _ := newFakeHelper(t, WithPepper([]byte("other"))).ValidateApiKey("testApiKey123", encrypted, date); valid {
+		t.Error("Expected key not to validate with a different pepper")
+	}
+	if valid, _ := newFakeHelper(t).ValidateApiKey("testApiKey123", encrypted, date); valid {
+		t.Error("Expected key not to validate without a pepper")
+	}
+	if got, err := NewInMemory(WithPepper(pepper)).EncryptApiKeyWithDate("testApiKey123", date); err != nil || got != encrypted {
+		t.Errorf("Expected in-memory engine to match %s, got %s, %v", encrypted, got, err)
+	}
+}
+
+func TestWithPepper_NeverOnArgv(t *testing.T) {
+	recorder := &eventRecorder{}
+	helper := NewWithBinaryPath("/opt/keyrotation-binary", WithDryRun(true), WithPepper([]byte("server-side-secret")), WithLogger(recorder))
+
+	helper.EncryptApiKey("testApiKey123")
+	command := strings.Join(recorder.recorded()[0].Command, " ")
+	if command != "/opt/keyrotation-binary --hmac --stdin encrypt" {
+		t.Errorf("Unexpected command %q", command)
+	}
+	env := helper.processEnv()
+	if want := pepperEnvVar + "=" + hex.EncodeToString([]byte("server-side-secret")); env[len(env)-1] != want {
+		t.Errorf("Expected pepper in the environment, got %q", env[len(env)-1])
+	}
+}
+
+func TestWithPepper_Unsupported(t *testing.T) {
+	t.Setenv(fakeModeEnv, "legacy")
+	helper := newFakeHelper(t, WithPepper([]byte("server-side-secret")))
+
+	if _, err := helper.EncryptApiKey("testApiKey123"); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported from a binary without HMAC mode, got %v", err)
+	}
+	if _, err := NewWithOptions(WithPepper(nil)); err == nil {
+		t.Error("Expected an empty pepper to be rejected")
+	}
+}
@@ -0,0 +1,219 @@
+package keyrotation
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// persistentProcess is a long-lived binary started with the "serve" subcommand. Each request is
+// a single line of tab-separated fields (the subcommand, the API key, then the remaining
+// arguments) and each response a single "ok\t<output>" or "error\t<message>" line. Requests are
+// serialized, so the process handles one call at a time.
+type persistentProcess struct {
+	binaryPath string
+	flags      []string
+	wrapper    []string
+	dir        string
+	env        []string
+	timeout    time.Duration
+	stdoutTee  *teeWriter
+	stderrTee  *teeWriter
+	// batch carries out batch requests, whose multi-line input the serve protocol cannot carry
+	batch engine
+
+	mu     sync.Mutex
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	stderr bytes.Buffer
+	closed bool
+}
+
+// NewPersistent creates a helper that starts the binary once and reuses it for every call,
+// avoiding the fork/exec cost of the default mode. The binary must support the serve
+// subcommand. Call Close to shut the process down when the helper is no longer needed.
+func NewPersistent(binaryPath string, opts ...Option) (*KeyRotationHelper, error) {
+	k, err := NewWithOptions(append([]Option{WithBinaryPath(binaryPath)}, opts...)...)
+	if err != nil {
+		return nil, err
+	}
+	path, err := k.resolveBinary()
+	if err != nil {
+		return nil, err
+	}
+	p := &persistentProcess{
+		binaryPath: path,
+		flags:      append(k.algorithmFlags(), k.extraArgs...),
+		wrapper:    k.wrapper,
+		dir:        k.workDir,
+		env:        k.processEnv(),
+		timeout:    k.timeout,
+		stdoutTee:  k.stdoutTee,
+		stderrTee:  k.stderrTee,
+		batch:      k.engine,
+	}
+
+	out, err := p.call(context.Background(), k.timeout, []string{"ping"})
+	if err == nil && out != "pong" {
+		err = fmt.Errorf("%w: ping answered with %q", ErrUnexpectedOutput, out)
+	}
+	if err != nil {
+		p.close()
+		return nil, fmt.Errorf("failed to start persistent binary: %w", k.algorithmError(err))
+	}
+
+	k.engine = p
+	return k, nil
+}
+
+// do sends r over the serve protocol, handing batch and JSON requests and API keys containing
+// tabs, line breaks or NUL bytes, which it cannot carry, to the per-call engine
+func (p *persistentProcess) do(ctx context.Context, r request) (string, error) {
+	if r.input != "" || r.json || (r.keyed && (needsFraming(r.apiKey) || strings.Contains(r.apiKey, "\t"))) {
+		return p.batch.do(ctx, r)
+	}
+	return p.call(ctx, p.timeout, r.fields())
+}
+
+// start launches the serve process. The caller must hold p.mu.
+func (p *persistentProcess) start() error {
+	name, args := wrapCommand(p.wrapper, p.binaryPath, append(p.flags, "serve"))
+	cmd := exec.Command(name, args...)
+	cmd.Dir = p.dir
+	cmd.Env = p.env
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	p.stderr.Reset()
+	cmd.Stderr = tee(&p.stderr, p.stderrTee)
+
+	if err := cmd.Start(); err != nil {
+		return startError(name, err)
+	}
+	var r io.Reader = stdout
+	if p.stdoutTee != nil {
+		r = io.TeeReader(stdout, p.stdoutTee)
+	}
+	p.cmd, p.stdin, p.stdout = cmd, stdin, bufio.NewReader(r)
+	return nil
+}
+
+// stop kills the process and reaps it. The caller must hold p.mu.
+func (p *persistentProcess) stop() {
+	p.stdin.Close()
+	p.cmd.Process.Kill()
+	p.cmd.Wait()
+	p.cmd = nil
+}
+
+// call sends one request and waits for its response, restarting the process if a previous
+// call left it dead. If ctx is done or timeout elapses first the process is killed, since it
+// can no longer be trusted to answer the next request in order.
+func (p *persistentProcess) call(ctx context.Context, timeout time.Duration, fields []string) (string, error) {
+	for _, field := range fields {
+		if strings.ContainsAny(field, "\t\r\n") {
+			return "", fmt.Errorf("keyrotation: persistent mode cannot send arguments containing tabs or line breaks")
+		}
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return "", ErrClosed
+	}
+	if p.cmd == nil {
+		if err := p.start(); err != nil {
+			return "", err
+		}
+	}
+
+	callCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		callCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	type response struct {
+		line string
+		err  error
+	}
+	responses := make(chan response, 1)
+	go func() {
+		if _, err := io.WriteString(p.stdin, strings.Join(fields, "\t")+"\n"); err != nil {
+			responses <- response{err: err}
+			return
+		}
+		line, err := p.stdout.ReadString('\n')
+		responses <- response{line: line, err: err}
+	}()
+
+	select {
+	case r := <-responses:
+		if r.err != nil {
+			p.stop()
+			return "", fmt.Errorf("%w: persistent binary exited: %s", ErrBinaryExecFailed, strings.TrimSpace(p.stderr.String()))
+		}
+		status, out, _ := strings.Cut(strings.TrimRight(r.line, "\r\n"), "\t")
+		switch status {
+		case "ok":
+			return normalizeOutput(out), nil
+		case "error":
+			return "", fmt.Errorf("%w: %s: %s", ErrBinaryExecFailed, fields[0], out)
+		default:
+			p.stop()
+			return "", fmt.Errorf("%w: malformed persistent response %q", ErrUnexpectedOutput, r.line)
+		}
+	case <-callCtx.Done():
+		p.cmd.Process.Kill()
+		<-responses
+		p.stop()
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return "", ctxErr
+		}
+		return "", fmt.Errorf("%w after %s: %w", ErrTimeout, timeout, context.DeadlineExceeded)
+	}
+}
+
+// close asks the process to exit by closing its stdin, killing it if it does not exit promptly.
+// It is safe to call more than once.
+func (p *persistentProcess) close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.closed {
+		return nil
+	}
+	p.closed = true
+	if p.cmd == nil {
+		return nil
+	}
+
+	p.stdin.Close()
+	exited := make(chan error, 1)
+	go func() { exited <- p.cmd.Wait() }()
+
+	var err error
+	select {
+	case err = <-exited:
+	case <-time.After(waitDelay):
+		p.cmd.Process.Kill()
+		<-exited
+	}
+	p.cmd = nil
+	if err != nil {
+		return fmt.Errorf("failed to stop persistent binary: %w", err)
+	}
+	return nil
+}
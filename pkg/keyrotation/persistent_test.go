@@ -0,0 +1,115 @@
+package keyrotation
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newPersistentFakeHelper(t *testing.T, opts ...Option) *KeyRotationHelper {
+	t.Helper()
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("Failed to locate test executable: %v", err)
+	}
+	helper, err := NewPersistent(exe, opts...)
+	if err != nil {
+		t.Fatalf("NewPersistent failed: %v", err)
+	}
+	t.Cleanup(func() { helper.Close() })
+	return helper
+}
+
+func TestNewPersistent_RoundTrip(t *testing.T) {
+	helper := newPersistentFakeHelper(t)
+	testApiKey := "testApiKey123"
+	testDate := time.Date(2024, 1, 15, 12, 30, 0, 0, time.UTC)
+
+	encrypted, err := helper.EncryptApiKeyWithDate(testApiKey, testDate)
+	if err != nil {
+		t.Fatalf("EncryptApiKeyWithDate failed: %v", err)
+	}
+	if encrypted != fakeHash(testApiKey, testDate) {
+		t.Errorf("Expected %s, got %s", fakeHash(testApiKey, testDate), encrypted)
+	}
+
+	isValid, err := helper.ValidateApiKey(testApiKey, encrypted, testDate)
+	if err != nil {
+		t.Fatalf("ValidateApiKey failed: %v", err)
+	}
+	if !isValid {
+		t.Error("Expected validation to succeed")
+	}
+
+	if _, err := helper.run(context.Background(), "no-such-command", testApiKey); !errors.Is(err, ErrBinaryExecFailed) {
+		t.Errorf("Expected per-request error to wrap ErrBinaryExecFailed, got %v", err)
+	}
+	if _, err := helper.EncryptApiKey(testApiKey); err != nil {
+		t.Errorf("Expected process to keep serving after a request error, got %v", err)
+	}
+}
+
+func TestNewPersistent_Concurrent(t *testing.T) {
+	helper := newPersistentFakeHelper(t)
+	today := time.Now().UTC()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := "key-" + string(rune('a'+i))
+			encrypted, err := helper.EncryptApiKey(key)
+			if err != nil {
+				t.Errorf("EncryptApiKey failed: %v", err)
+				return
+			}
+			if encrypted != fakeHash(key, today) {
+				t.Errorf("Responses were interleaved: got %s for %s", encrypted, key)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestNewPersistent_TimeoutRestartsProcess(t *testing.T) {
+	t.Setenv(fakeModeEnv, "serve-hang")
+	helper := newPersistentFakeHelper(t, WithTimeout(100*time.Millisecond))
+
+	if _, err := helper.EncryptApiKey("testApiKey123"); !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Expected ErrTimeout, got %v", err)
+	}
+
+	t.Setenv(fakeModeEnv, "")
+	if _, err := helper.EncryptApiKey("testApiKey123"); err != nil {
+		t.Errorf("Expected process to be restarted after a timeout, got %v", err)
+	}
+}
+
+func TestNewPersistent_Unsupported(t *testing.T) {
+	t.Setenv(fakeModeEnv, "legacy")
+	exe, err := os.Executable()
+	if err != nil {
+		t.Fatalf("Failed to locate test executable: %v", err)
+	}
+	if _, err := NewPersistent(exe); !errors.Is(err, ErrBinaryExecFailed) {
+		t.Errorf("Expected ErrBinaryExecFailed for binary without serve, got %v", err)
+	}
+}
+
+func TestNewPersistent_Close(t *testing.T) {
+	helper := newPersistentFakeHelper(t)
+
+	if err := helper.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := helper.Close(); err != nil {
+		t.Errorf("Expected second Close to be a no-op, got %v", err)
+	}
+	if _, err := helper.EncryptApiKey("testApiKey123"); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed after Close, got %v", err)
+	}
+}
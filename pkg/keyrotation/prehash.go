@@ -0,0 +1,18 @@
+package keyrotation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// preHashKey returns the hex SHA256 of the configured salt followed by apiKey when WithPreHash is
+// set, or apiKey itself otherwise. An empty key stays empty so the binary still rejects it.
+func (k *KeyRotationHelper) preHashKey(apiKey string) string {
+	if k.preHashSalt == nil || apiKey == "" {
+		return apiKey
+	}
+	h := sha256.New()
+	h.Write(k.preHashSalt)
+	h.Write([]byte(apiKey))
+	return hex.EncodeToString(h.Sum(nil))
+}
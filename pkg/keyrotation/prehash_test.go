@@ -0,0 +1,60 @@
+package keyrotation
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWithPreHash_SendsDigest(t *testing.T) {
+	recorder := &eventRecorder{}
+	helper := NewWithBinaryPath("/opt/keyrotation-binary", WithDryRun(true), WithLegacyArgv(true), WithRedaction(false),
+		WithPreHash([]byte("pepper")), WithLogger(recorder))
+
+	helper.EncryptApiKey("secret-key")
+	sum := sha256.Sum256([]byte("pepper" + "secret-key"))
+	if got, want := strings.Join(recorder.recorded()[0].Command, " "), "/opt/keyrotation-binary encrypt "+hex.EncodeToString(sum[:]); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+func TestWithPreHash_RoundTrip(t *testing.T) {
+	helper := newFakeHelper(t, WithPreHash([]byte("pepper")))
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	encrypted, err := helper.EncryptApiKeyWithDate("secret-key", date)
+	if err != nil {
+		t.Fatalf("EncryptApiKeyWithDate failed: %v", err)
+	}
+	if valid, err := helper.ValidateApiKey("secret-key", encrypted, date); err != nil || !valid {
+		t.Errorf("Expected pre-hashed key to validate, got %v, %v", valid, err)
+	}
+	if valid, _ := newFakeHelper(t).ValidateApiKey("secret-key", encrypted, date); valid {
+		t.Error("Expected pre-hashed key not to validate without pre-hashing")
+	}
+	if valid, _ := newFakeHelper(t, WithPreHash([]byte("other"))).ValidateApiKey("secret-key", encrypted, date); valid {
+		t.Error("Expected pre-hashed key not to validate with a different salt")
+	}
+	if got, err := NewInMemory(WithPreHash([]byte("pepper"))).EncryptApiKeyWithDate("secret-key", date); err != nil || got != encrypted {
+		t.Errorf("Expected in-memory engine to match %s, got %s, %v", encrypted, got, err)
+	}
+}
+
+func TestWithPreHash_Batch(t *testing.T) {
+	helper := newFakeHelper(t, WithPreHash([]byte("pepper")))
+
+	encrypted, err := helper.EncryptApiKeyBatch([]string{"a", "b"})
+	if err != nil {
+		t.Fatalf("EncryptApiKeyBatch failed: %v", err)
+	}
+	single, err := helper.EncryptApiKey("a")
+	if err != nil || single != encrypted[0] {
+		t.Errorf("Expected batch to match single call %s, got %s, %v", encrypted[0], single, err)
+	}
+	results, err := helper.ValidateApiKeyBatch([]KeyPair{{"a", encrypted[0]}, {"b", encrypted[1]}})
+	if err != nil || !results[0] || !results[1] {
+		t.Errorf("Expected batch to validate, got %v, %v", results, err)
+	}
+}
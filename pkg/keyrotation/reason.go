@@ -0,0 +1,66 @@
+package keyrotation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Reason explains the outcome of a validation, for telling failure modes apart in metrics and alerts
+type Reason string
+
+const (
+	// ReasonMatch means the key matched the current rotation date
+	ReasonMatch Reason = "match"
+	// ReasonMismatch means the key matched neither the current rotation date nor its neighbours,
+	// most likely because it is the wrong key
+	ReasonMismatch Reason = "mismatch"
+	// ReasonMalformedCiphertext means the ciphertext is not a well-formed digest, so no key could
+	// ever match it
+	ReasonMalformedCiphertext Reason = "malformed_ciphertext"
+	// ReasonDateOutOfRange means the key is right but was encrypted for the previous or next
+	// rotation date, as when a client's clock is off or it has not picked up a rotation
+	ReasonDateOutOfRange Reason = "date_out_of_range"
+)
+
+// ValidateApiKeyTodayWithReason is like ValidateApiKeyToday but also reports why the key was
+// accepted or rejected. A rejected key is checked against the previous and next rotation dates
+// to tell a date that is off from a wrong key, costing up to two more binary invocations. A
+// malformed ciphertext is reported as ReasonMalformedCiphertext rather than an error. The reason
+// is empty when err is non-nil.
+func (k *KeyRotationHelper) ValidateApiKeyTodayWithReason(apiKey, encryptedKey string) (bool, Reason, error) {
+	return k.ValidateApiKeyTodayWithReasonContext(context.Background(), apiKey, encryptedKey)
+}
+
+// ValidateApiKeyTodayWithReasonContext is like ValidateApiKeyTodayWithReason but kills the binary
+// if ctx is done first
+func (k *KeyRotationHelper) ValidateApiKeyTodayWithReasonContext(ctx context.Context, apiKey, encryptedKey string) (bool, Reason, error) {
+	now := k.now()
+	isValid, err := k.ValidateApiKeyTodayContext(ctx, apiKey, encryptedKey)
+	if errors.Is(err, ErrMalformedCiphertext) {
+		return false, ReasonMalformedCiphertext, nil
+	}
+	if err != nil {
+		return false, "", err
+	}
+	if isValid {
+		return true, ReasonMatch, nil
+	}
+
+	neighbours := []time.Time{k.startOfInterval(now).Add(-time.Nanosecond), k.nextInterval(now)}
+	_, offDate, err := k.firstMatch(ctx, apiKey, encryptedKey, neighbours)
+	if err != nil {
+		return false, "", fmt.Errorf("failed to validate API key for today: %w", err)
+	}
+	if offDate {
+		return false, ReasonDateOutOfRange, nil
+	}
+	return false, ReasonMismatch, nil
+}
+
+// ValidateApiKeyTodayWithReason validates an API key for today, reporting why it was accepted or rejected
+func ValidateApiKeyTodayWithReason(apiKey, encryptedKey string) (bool, Reason, error) {
+	helper := Default()
+	return helper.ValidateApiKeyTodayWithReason(apiKey, encryptedKey)
+}
@@ -0,0 +1,47 @@
+package keyrotation
+
+import (
+	"testing"
+	"time"
+)
+
+func TestValidateApiKeyTodayWithReason(t *testing.T) {
+	now := time.Date(2025, 3, 10, 12, 0, 0, 0, time.UTC)
+	helper := newFakeHelper(t, WithClock(func() time.Time { return now }))
+	for _, tc := range []struct {
+		name, encrypted string
+		valid           bool
+		reason          Reason
+	}{
+		{"today", fakeHash("testApiKey123", now), true, ReasonMatch},
+		{"yesterday", fakeHash("testApiKey123", now.AddDate(0, 0, -1)), false, ReasonDateOutOfRange},
+		{"tomorrow", fakeHash("testApiKey123", now.AddDate(0, 0, 1)), false, ReasonDateOutOfRange},
+		{"last week", fakeHash("testApiKey123", now.AddDate(0, 0, -7)), false, ReasonMismatch},
+		{"wrong key", fakeHash("otherApiKey456", now), false, ReasonMismatch},
+		{"malformed", "not-a-digest", false, ReasonMalformedCiphertext},
+	} {
+		valid, reason, err := helper.ValidateApiKeyTodayWithReason("testApiKey123", tc.encrypted)
+		if err != nil {
+			t.Fatalf("%s: ValidateApiKeyTodayWithReason failed: %v", tc.name, err)
+		}
+		if valid != tc.valid || reason != tc.reason {
+			t.Errorf("%s: Expected %v, %s, got %v, %s", tc.name, tc.valid, tc.reason, valid, reason)
+		}
+	}
+}
+
+func TestNextInterval(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skipf("Time zone database unavailable: %v", err)
+	}
+	// 2024-10-27 is 25 hours long in Berlin
+	daily := New(WithLocation(berlin))
+	if got, want := daily.nextInterval(time.Date(2024, 10, 27, 12, 0, 0, 0, berlin)), time.Date(2024, 10, 28, 0, 0, 0, 0, berlin); !got.Equal(want) {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+	hourly := New(WithRotationInterval(6 * time.Hour))
+	if got, want := hourly.nextInterval(time.Date(2024, 1, 15, 19, 30, 0, 0, time.UTC)), time.Date(2024, 1, 16, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
@@ -0,0 +1,151 @@
+package keyrotation
+
+import (
+	"encoding/hex"
+	"errors"
+	"slices"
+	"strings"
+)
+
+// RedactedKey replaces the plaintext API key in commands reported by errors and dry-run events,
+// and any occurrence of the key, pepper or pre-hash salt in error messages and the binary's
+// reported stderr, unless redaction is disabled with WithRedaction(false)
+const RedactedKey = "<redacted>"
+
+// minRedactLength is the length below which secrets are only redacted where they stand alone as a
+// word: replacing them inside other words would mangle the message beyond use, including the
+// diagnostics errors are classified by, such as "Unknown command"
+const minRedactLength = 8
+
+// redactedError is an error whose message had secrets removed. The error it wraps remains
+// available to errors.Is and errors.As.
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string {
+	return e.msg
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
+
+// redactError returns err with apiKeys and the configured secrets removed from its message and
+// from the Stderr of any *BinaryError it wraps, so a binary that echoes its input cannot leak
+// them into logs
+func (k *KeyRotationHelper) redactError(err error, apiKeys ...string) error {
+	if err == nil || k.unredacted {
+		return err
+	}
+	var binErr *BinaryError
+	if errors.As(err, &binErr) {
+		binErr.Stderr = k.redact(binErr.Stderr, apiKeys...)
+	}
+	if msg := k.redact(err.Error(), apiKeys...); msg != err.Error() {
+		return &redactedError{msg: msg, err: err}
+	}
+	return err
+}
+
+// redact replaces every occurrence in s of each of apiKeys, the form the binary received it in,
+// and the configured pepper and pre-hash salt with RedactedKey, unless redaction is disabled.
+// Secrets shorter than minRedactLength are replaced only where they form a whole word.
+func (k *KeyRotationHelper) redact(s string, apiKeys ...string) string {
+	if k.unredacted {
+		return s
+	}
+	var secrets []string
+	for _, apiKey := range apiKeys {
+		secrets = append(secrets, apiKey, k.binaryKey(apiKey))
+	}
+	if len(k.pepper) > 0 {
+		secrets = append(secrets, hex.EncodeToString(k.pepper), string(k.pepper))
+	}
+	if len(k.preHashSalt) > 0 {
+		secrets = append(secrets, string(k.preHashSalt))
+	}
+	// Longest first, so a secret containing another is replaced whole. A single pass never
+	// matches inside an inserted RedactedKey.
+	slices.SortFunc(secrets, func(a, b string) int { return len(b) - len(a) })
+	var pairs, short []string
+	for _, secret := range secrets {
+		switch {
+		case len(secret) >= minRedactLength:
+			pairs = append(pairs, secret, RedactedKey)
+		case secret != "":
+			short = append(short, secret)
+		}
+	}
+	if len(pairs) > 0 {
+		s = strings.NewReplacer(pairs...).Replace(s)
+	}
+	for _, secret := range short {
+		s = replaceWord(s, secret, RedactedKey)
+	}
+	return s
+}
+
+// replaceWord replaces every occurrence of word in s that is not part of a longer run of letters
+// and digits with repl
+func replaceWord(s, word, repl string) string {
+	var b strings.Builder
+	start := 0
+	for i := 0; i+len(word) <= len(s); {
+		j := strings.Index(s[i:], word)
+		if j < 0 {
+			break
+		}
+		i += j
+		end := i + len(word)
+		if (i == 0 || !isWordByte(s[i-1])) && (end == len(s) || !isWordByte(s[end])) {
+			b.WriteString(s[start:i])
+			b.WriteString(repl)
+			start, i = end, end
+			continue
+		}
+		i++
+	}
+	if start == 0 {
+		return s
+	}
+	b.WriteString(s[start:])
+	return b.String()
+}
+
+// isWordByte reports whether c is an ASCII letter or digit
+func isWordByte(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// redactArgs returns a copy of argv, built for r, with the API key replaced by RedactedKey
+func (k *KeyRotationHelper) redactArgs(argv []string, r request) []string {
+	redacted := append([]string(nil), argv...)
+	if !r.keyed || k.unredacted {
+		return redacted
+	}
+	for i, arg := range redacted {
+		if arg == r.apiKey {
+			redacted[i] = RedactedKey
+		}
+	}
+	return redacted
+}
+
+// shellJoin renders argv as a command line that can be pasted into a POSIX shell, single-quoting
+// any argument containing characters the shell would interpret
+func shellJoin(argv []string) string {
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		quoted[i] = shellQuote(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+func shellQuote(arg string) string {
+	if arg != "" && strings.Trim(arg, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_./:=,+@%") == "" {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
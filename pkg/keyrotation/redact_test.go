@@ -0,0 +1,128 @@
+package keyrotation
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestBinaryError_LegacyArgvRedactsKey(t *testing.T) {
+	helper := newFakeHelper(t, WithLegacyArgv(true))
+
+	_, err := helper.run(context.Background(), "no-such-command", "secret-key", "extra")
+	var binErr *BinaryError
+	if !errors.As(err, &binErr) {
+		t.Fatalf("Expected *BinaryError, got %T: %v", err, err)
+	}
+	if got := strings.Join(binErr.Command[1:], " "); got != "no-such-command <redacted> extra" {
+		t.Errorf("Unexpected command %q", got)
+	}
+	if strings.Contains(err.Error(), "secret-key") {
+		t.Errorf("Expected API key to be redacted from %q", err.Error())
+	}
+}
+
+func TestBinaryError_RedactionDisabled(t *testing.T) {
+	helper := newFakeHelper(t, WithLegacyArgv(true), WithRedaction(false))
+
+	_, err := helper.run(context.Background(), "no-such-command", "secret-key")
+	var binErr *BinaryError
+	if !errors.As(err, &binErr) {
+		t.Fatalf("Expected *BinaryError, got %T: %v", err, err)
+	}
+	if got := strings.Join(binErr.Command[1:], " "); got != "no-such-command secret-key" {
+		t.Errorf("Unexpected command %q", got)
+	}
+}
+
+func TestBinaryError_CommandLine(t *testing.T) {
+	err := &BinaryError{Command: []string{"/opt/key rotation/bin", "validate", RedactedKey, "it's", "abc123"}}
+
+	want := `'/opt/key rotation/bin' validate '<redacted>' 'it'\''s' abc123`
+	if got := err.CommandLine(); got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+}
+
+func TestRedaction_EchoedSecrets(t *testing.T) {
+	t.Setenv(fakeModeEnv, "echo-key")
+	pepper := []byte("pepper-0123456789")
+	exe, _ := os.Executable()
+	persistent, err := NewPersistent(exe, WithPepper(pepper))
+	if err != nil {
+		t.Fatalf("NewPersistent failed: %v", err)
+	}
+	defer persistent.Close()
+	recorder := &eventRecorder{}
+
+	for name, helper := range map[string]*KeyRotationHelper{
+		"stdin":      newFakeHelper(t, WithPepper(pepper), WithLogger(recorder)),
+		"argv":       newFakeHelper(t, WithPepper(pepper), WithLegacyArgv(true)),
+		"pre-hash":   newFakeHelper(t, WithPepper(pepper), WithPreHash([]byte("salt-0123456789"))),
+		"persistent": persistent,
+	} {
+		_, err := helper.EncryptApiKey("secret-api-key")
+		if err == nil {
+			t.Fatalf("%s: Expected the echoing binary to fail", name)
+		}
+		assertRedacted(t, name, err.Error(), "secret-api-key", hex.EncodeToString(pepper), helper.binaryKey("secret-api-key"))
+		var binErr *BinaryError
+		if errors.As(err, &binErr) {
+			assertRedacted(t, name+" stderr", binErr.Stderr, "secret-api-key", hex.EncodeToString(pepper))
+		}
+		if !errors.Is(err, ErrBinaryExecFailed) {
+			t.Errorf("%s: Expected the redacted error to still match ErrBinaryExecFailed, got %v", name, err)
+		}
+	}
+	for _, e := range recorder.recorded() {
+		assertRedacted(t, "logged event", e.Err.Error(), "secret-api-key")
+	}
+
+	helper := newFakeHelper(t)
+	_, err = helper.EncryptApiKeyBatch([]string{"secret-api-key"})
+	assertRedacted(t, "encrypt batch", err.Error(), "secret-api-key")
+	_, err = helper.ValidateApiKeyBatch([]KeyPair{{"secret-api-key", wrongCiphertext}})
+	assertRedacted(t, "validate batch", err.Error(), "secret-api-key")
+
+	_, err = newFakeHelper(t, WithRedaction(false)).EncryptApiKey("secret-api-key")
+	if err == nil || !strings.Contains(err.Error(), "secret-api-key") {
+		t.Errorf("Expected WithRedaction(false) to keep the key in %v", err)
+	}
+}
+
+func TestRedaction_ShortSecrets(t *testing.T) {
+	t.Setenv(fakeModeEnv, "echo-key")
+	pepper := []byte("pep")
+	helper := newFakeHelper(t, WithPepper(pepper))
+
+	_, err := helper.EncryptApiKey("k3y")
+	if err == nil {
+		t.Fatal("Expected the echoing binary to fail")
+	}
+	assertRedacted(t, "short key", err.Error(), "k3y", hex.EncodeToString(pepper))
+	var binErr *BinaryError
+	if errors.As(err, &binErr) {
+		assertRedacted(t, "short key stderr", binErr.Stderr, "k3y", hex.EncodeToString(pepper))
+	}
+
+	// Inside other words a short secret is left alone, so the message stays readable
+	if got, want := helper.redact("k3y:xk3y k3y2 k3yk3y k3y", "k3y"), "<redacted>:xk3y k3y2 k3yk3y <redacted>"; got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
+
+// assertRedacted fails the test if msg contains any of secrets
+func assertRedacted(t *testing.T, name, msg string, secrets ...string) {
+	t.Helper()
+	if !strings.Contains(msg, RedactedKey) {
+		t.Errorf("%s: Expected %q to contain %s", name, msg, RedactedKey)
+	}
+	for _, secret := range secrets {
+		if strings.Contains(msg, secret) {
+			t.Errorf("%s: Expected %q to be redacted from %q", name, secret, msg)
+		}
+	}
+}
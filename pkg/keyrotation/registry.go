@@ -0,0 +1,257 @@
+package keyrotation
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+// keyRegistry holds the API keys added with RegisterKeys and their ciphertext digests for one
+// rotation date. The digests are recomputed by the first lookup after the date changes, outside
+// the lock, so lookups of other keys are never held up behind the binary.
+type keyRegistry struct {
+	mu      sync.RWMutex
+	date    string
+	digests map[string][]byte // by API key; nil until computed for date
+	// gen counts expiries, so a recomputation started before one does not store stale digests
+	gen int
+	// refresh is the recomputation in progress, if any, shared by every lookup waiting for it
+	refresh *registryRefresh
+}
+
+// registryRefresh is one recomputation of the registered digests
+type registryRefresh struct {
+	done chan struct{}
+	err  error
+}
+
+// RegisterKeys adds apiKeys to the set of known keys whose ciphertexts for today are precomputed,
+// once per rotation date, so ValidateApiKeyToday checks them with an in-memory comparison instead
+// of a binary invocation. It encrypts the keys not yet registered before returning, in a single
+// encrypt-date-batch invocation where the binary supports it, so a misconfigured binary is
+// reported at registration; on error none of apiKeys is added. Registered plaintext keys are held
+// in memory until Close, since they are needed to recompute the ciphertexts at each date boundary.
+func (k *KeyRotationHelper) RegisterKeys(apiKeys []string) error {
+	return k.RegisterKeysContext(context.Background(), apiKeys)
+}
+
+// RegisterKeysContext is like RegisterKeys but kills the binary if ctx is done first
+func (k *KeyRotationHelper) RegisterKeysContext(ctx context.Context, apiKeys []string) error {
+	for _, apiKey := range apiKeys {
+		if err := k.checkKey(apiKey); err != nil {
+			return fmt.Errorf("failed to register API keys: %w", err)
+		}
+	}
+
+	r := &k.registry
+	var added []string
+	r.mu.RLock()
+	for _, apiKey := range apiKeys {
+		if _, ok := r.digests[apiKey]; !ok && !slices.Contains(added, apiKey) {
+			added = append(added, apiKey)
+		}
+	}
+	r.mu.RUnlock()
+	if len(added) == 0 {
+		return nil
+	}
+
+	now := k.now()
+	digests, err := k.registryDigests(ctx, added, now)
+	if err != nil {
+		return fmt.Errorf("failed to register API keys: %w", err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.digests == nil {
+		r.digests = make(map[string][]byte, len(added))
+	}
+	date := k.GetDateString(now)
+	if r.date == "" {
+		// Every digest is nil once expired, so the registry can move to this date
+		r.date = date
+	}
+	for i, apiKey := range added {
+		if _, ok := r.digests[apiKey]; ok {
+			continue
+		}
+		if r.date == date {
+			r.digests[apiKey] = digests[i]
+		} else {
+			r.digests[apiKey] = nil
+		}
+	}
+	return nil
+}
+
+// registryDigests returns the ciphertext digest of each of apiKeys for the rotation date of now,
+// computed with one encrypt-date-batch invocation, or one encrypt-date invocation per key on
+// binaries without it and for keys a batch line cannot carry
+func (k *KeyRotationHelper) registryDigests(ctx context.Context, apiKeys []string, now time.Time) ([][]byte, error) {
+	encrypted, err := k.encryptKeysWithDate(ctx, apiKeys, now)
+	if err != nil {
+		return nil, err
+	}
+	digests := make([][]byte, len(apiKeys))
+	for i, ciphertext := range encrypted {
+		if digests[i], err = k.decodeCiphertext(ciphertext); err != nil {
+			return nil, fmt.Errorf("%w: encrypt printed %q: %w", ErrUnexpectedOutput, k.redact(ciphertext, apiKeys[i]), err)
+		}
+	}
+	return digests, nil
+}
+
+// encryptKeysWithDate encrypts each of apiKeys for the rotation date of now, in one batch where
+// possible
+func (k *KeyRotationHelper) encryptKeysWithDate(ctx context.Context, apiKeys []string, now time.Time) ([]string, error) {
+	if !slices.ContainsFunc(apiKeys, func(apiKey string) bool { return k.checkBatchKey(apiKey) != nil }) {
+		date := k.binaryDate(now)
+		items := make([]string, len(apiKeys))
+		for i, apiKey := range apiKeys {
+			items[i] = date + "\t" + k.binaryKey(apiKey)
+		}
+		lines, err := k.runBatch(ctx, "encrypt-date-batch", items, apiKeys)
+		if err == nil {
+			for i, line := range lines {
+				if lines[i], err = k.batchCiphertext(line, apiKeys[i]); err != nil {
+					return nil, &BatchError{Index: i, Err: err}
+				}
+			}
+			return lines, nil
+		}
+		if !isUnknownCommand(err) {
+			return nil, err
+		}
+	}
+
+	encrypted := make([]string, len(apiKeys))
+	for i, apiKey := range apiKeys {
+		var err error
+		if encrypted[i], err = k.encryptWithDate(ctx, apiKey, now); err != nil {
+			return nil, err
+		}
+	}
+	return encrypted, nil
+}
+
+// registeredValidation checks encryptedKey against the precomputed ciphertext of apiKey for the
+// rotation date of now, reporting ok=false if apiKey is not registered or its ciphertext could not
+// be recomputed, in which case the caller should ask the binary instead
+func (k *KeyRotationHelper) registeredValidation(ctx context.Context, apiKey, encryptedKey string, now time.Time) (isValid, ok bool, err error) {
+	date := k.GetDateString(now)
+	digest, registered := k.registeredDigest(apiKey, date)
+	if !registered {
+		return false, false, nil
+	}
+	if digest == nil {
+		if err := k.awaitRegistryRefresh(ctx, now); err != nil {
+			return false, false, nil
+		}
+		if digest, _ = k.registeredDigest(apiKey, date); digest == nil {
+			return false, false, nil
+		}
+	}
+
+	got, err := k.decodeCiphertext(encryptedKey)
+	if err != nil {
+		if k.skipCiphertextCheck {
+			return false, true, nil
+		}
+		return false, true, err
+	}
+	return subtle.ConstantTimeCompare(got, digest) == 1, true, nil
+}
+
+// registeredDigest reports whether apiKey is registered and returns its digest for date, or nil if
+// it has not been computed for that date
+func (k *KeyRotationHelper) registeredDigest(apiKey, date string) ([]byte, bool) {
+	r := &k.registry
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	digest, registered := r.digests[apiKey]
+	if r.date != date {
+		digest = nil
+	}
+	return digest, registered
+}
+
+// awaitRegistryRefresh waits for the registered digests to be recomputed for the rotation date of
+// now, starting the recomputation unless one is already in progress. The recomputation is shared
+// by every waiting lookup, so it runs detached from ctx: a lookup whose ctx is done stops waiting,
+// but the recomputation carries on for the others.
+func (k *KeyRotationHelper) awaitRegistryRefresh(ctx context.Context, now time.Time) error {
+	r := &k.registry
+	r.mu.Lock()
+	f := r.refresh
+	if f == nil {
+		f = &registryRefresh{done: make(chan struct{})}
+		r.refresh = f
+		date := k.GetDateString(now)
+		var apiKeys []string
+		for apiKey, digest := range r.digests {
+			if r.date != date || digest == nil {
+				apiKeys = append(apiKeys, apiKey)
+			}
+		}
+		go k.refreshRegistry(context.WithoutCancel(ctx), f, apiKeys, now, r.gen)
+	}
+	r.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// refreshRegistry computes the digests of apiKeys for the rotation date of now and stores them,
+// discarding digests computed for an earlier date, unless the registry was expired meanwhile
+func (k *KeyRotationHelper) refreshRegistry(ctx context.Context, f *registryRefresh, apiKeys []string, now time.Time, gen int) {
+	digests, err := k.registryDigests(ctx, apiKeys, now)
+
+	r := &k.registry
+	r.mu.Lock()
+	r.refresh = nil
+	if err == nil && r.gen == gen {
+		if date := k.GetDateString(now); r.date != date {
+			r.date = date
+			for apiKey := range r.digests {
+				r.digests[apiKey] = nil
+			}
+		}
+		for i, apiKey := range apiKeys {
+			if _, ok := r.digests[apiKey]; ok {
+				r.digests[apiKey] = digests[i]
+			}
+		}
+	}
+	f.err = err
+	r.mu.Unlock()
+	close(f.done)
+}
+
+// expireRegistry discards the precomputed ciphertexts, so they are computed again on next use,
+// but keeps the registered keys
+func (k *KeyRotationHelper) expireRegistry() {
+	k.registry.mu.Lock()
+	defer k.registry.mu.Unlock()
+	k.registry.date = ""
+	k.registry.gen++
+	for apiKey := range k.registry.digests {
+		k.registry.digests[apiKey] = nil
+	}
+}
+
+// clearRegistry forgets every registered key
+func (k *KeyRotationHelper) clearRegistry() {
+	k.registry.mu.Lock()
+	defer k.registry.mu.Unlock()
+	k.registry.date = ""
+	k.registry.gen++
+	k.registry.digests = nil
+}
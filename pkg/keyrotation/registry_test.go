@@ -0,0 +1,157 @@
+package keyrotation
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRegisterKeys_ValidatesInMemory(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2025, 3, 10, 23, 59, 0, 0, time.UTC)}
+	recorder := &eventRecorder{}
+	helper := newFakeHelper(t, WithClock(clock.now), WithLogger(recorder))
+
+	if err := helper.RegisterKeys([]string{"key-a", "key-b"}); err != nil {
+		t.Fatalf("RegisterKeys failed: %v", err)
+	}
+	if got := len(recorder.recorded()); got != 1 {
+		t.Fatalf("Expected the registered keys to be encrypted in one batch, got %d invocations", got)
+	}
+
+	for range 3 {
+		if valid, err := helper.ValidateApiKeyToday("key-a", fakeHash("key-a", clock.now())); err != nil || !valid {
+			t.Errorf("Expected registered key to validate, got %v, %v", valid, err)
+		}
+		if valid, err := helper.ValidateApiKeyToday("key-b", fakeHash("key-a", clock.now())); err != nil || valid {
+			t.Errorf("Expected another key's ciphertext to be rejected, got %v, %v", valid, err)
+		}
+	}
+	if got := len(recorder.recorded()); got != 1 {
+		t.Errorf("Expected registered keys to validate without the binary, got %d invocations", got)
+	}
+	if _, err := helper.ValidateApiKeyToday("key-a", "short"); !errors.Is(err, ErrMalformedCiphertext) {
+		t.Errorf("Expected ErrMalformedCiphertext, got %v", err)
+	}
+
+	yesterday := fakeHash("key-a", clock.now())
+	clock.set(clock.now().Add(2 * time.Minute))
+	if valid, err := helper.ValidateApiKeyToday("key-a", yesterday); err != nil || valid {
+		t.Errorf("Expected yesterday's ciphertext to be rejected after midnight, got %v, %v", valid, err)
+	}
+	if valid, err := helper.ValidateApiKeyToday("key-a", fakeHash("key-a", clock.now())); err != nil || !valid {
+		t.Errorf("Expected the refreshed ciphertext to validate, got %v, %v", valid, err)
+	}
+	if got := len(recorder.recorded()); got != 2 {
+		t.Errorf("Expected the registered keys to be recomputed once at the day boundary, got %d invocations", got)
+	}
+
+	if valid, err := helper.ValidateApiKeyToday("unregistered", fakeHash("unregistered", clock.now())); err != nil || !valid {
+		t.Errorf("Expected an unregistered key to validate through the binary, got %v, %v", valid, err)
+	}
+	if got := len(recorder.recorded()); got != 3 {
+		t.Errorf("Expected an unregistered key to invoke the binary, got %d invocations", got)
+	}
+}
+
+func TestRegisterKeys_Errors(t *testing.T) {
+	if err := NewInMemory().RegisterKeys([]string{"key-a", ""}); !errors.Is(err, ErrEmptyKey) {
+		t.Errorf("Expected ErrEmptyKey, got %v", err)
+	}
+
+	helper := NewWithBinaryPath("/nonexistent/keyrotation-binary")
+	if err := helper.RegisterKeys([]string{"key-a"}); !errors.Is(err, ErrBinaryNotFound) {
+		t.Errorf("Expected ErrBinaryNotFound, got %v", err)
+	}
+	if _, ok, _ := helper.registeredValidation(t.Context(), "key-a", wrongCiphertext, time.Now()); ok {
+		t.Error("Expected a failed registration to leave the key unregistered")
+	}
+}
+
+func TestRegisterKeys_ClearedOnClose(t *testing.T) {
+	helper := NewInMemory()
+	if err := helper.RegisterKeys([]string{"key-a"}); err != nil {
+		t.Fatalf("RegisterKeys failed: %v", err)
+	}
+	helper.Close()
+	if _, err := helper.ValidateApiKeyToday("key-a", wrongCiphertext); !errors.Is(err, ErrClosed) {
+		t.Errorf("Expected ErrClosed after Close, got %v", err)
+	}
+}
+
+// gatedRunner runs the binary with next, holding encrypt-date-batch invocations until gate is
+// closed once armed
+type gatedRunner struct {
+	next    CommandRunner
+	armed   atomic.Bool
+	gate    chan struct{}
+	batches atomic.Int32
+}
+
+func (g *gatedRunner) Run(ctx context.Context, name string, args []string, stdin io.Reader) ([]byte, []byte, error) {
+	if g.armed.Load() && slices.Contains(args, "encrypt-date-batch") {
+		g.batches.Add(1)
+		<-g.gate
+	}
+	return g.next.Run(ctx, name, args, stdin)
+}
+
+func TestRegisterKeys_ConcurrentRollover(t *testing.T) {
+	clock := &fakeClock{t: time.Date(2025, 3, 10, 23, 59, 0, 0, time.UTC)}
+	base := newFakeHelper(t, WithClock(clock.now))
+	runner := &gatedRunner{next: execRunner{base}, gate: make(chan struct{})}
+	helper := base.Clone(WithCommandRunner(runner))
+	if err := helper.RegisterKeys([]string{"key-a", "key-b"}); err != nil {
+		t.Fatalf("RegisterKeys failed: %v", err)
+	}
+
+	clock.set(clock.now().Add(2 * time.Minute))
+	runner.armed.Store(true)
+	today := fakeHash("key-a", clock.now())
+
+	// The first lookup after midnight starts the refresh, then gives up waiting for it
+	ctx, cancel := context.WithCancel(context.Background())
+	abandoned := make(chan error, 1)
+	go func() {
+		_, err := helper.ValidateApiKeyTodayContext(ctx, "key-a", today)
+		abandoned <- err
+	}()
+	for deadline := time.Now().Add(10 * time.Second); runner.batches.Load() == 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the lookup to start recomputing the registered keys")
+		}
+	}
+	cancel()
+	if err := <-abandoned; !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the abandoned lookup to report its cancellation, got %v", err)
+	}
+
+	// Unregistered keys are validated through the binary while the refresh is held up
+	if valid, err := helper.ValidateApiKeyToday("unregistered", fakeHash("unregistered", clock.now())); err != nil || !valid {
+		t.Errorf("Expected an unregistered key to validate during the refresh, got %v, %v", valid, err)
+	}
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if valid, err := helper.ValidateApiKeyToday("key-a", today); err != nil || !valid {
+				t.Errorf("Expected the registered key to validate after the refresh, got %v, %v", valid, err)
+			}
+		}()
+	}
+	close(runner.gate)
+	wg.Wait()
+
+	if got := runner.batches.Load(); got != 1 {
+		t.Errorf("Expected one shared refresh surviving the cancelled lookup, got %d batches", got)
+	}
+	if digest, _ := helper.registeredDigest("key-b", helper.GetDateString(clock.now())); digest == nil {
+		t.Error("Expected every registered key to be recomputed for the new date")
+	}
+}
@@ -0,0 +1,103 @@
+package keyrotation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// flakyCall fails with err for the first failures attempts, then succeeds
+func flakyCall(failures int, err error) (func(context.Context) (string, error), *int) {
+	calls := 0
+	return func(context.Context) (string, error) {
+		calls++
+		if calls <= failures {
+			return "", err
+		}
+		return "ok", nil
+	}, &calls
+}
+
+func TestWithRetry_RetriesTransientFailures(t *testing.T) {
+	recorder := &eventRecorder{}
+	helper := New(WithRetry(3, time.Millisecond), WithLogger(recorder))
+	transient := fmt.Errorf("%w: fork/exec: %w", ErrBinaryExecFailed, syscall.EAGAIN)
+	call, calls := flakyCall(2, transient)
+
+	out, err := helper.execute(context.Background(), "encrypt", call)
+	if err != nil || out != "ok" {
+		t.Fatalf("Expected success after retries, got %q, %v", out, err)
+	}
+	if *calls != 3 {
+		t.Errorf("Expected 3 attempts, got %d", *calls)
+	}
+	if len(recorder.recorded()) != 3 {
+		t.Errorf("Expected every attempt to be logged, got %d events", len(recorder.recorded()))
+	}
+}
+
+func TestWithRetry_GivesUpAfterMaxAttempts(t *testing.T) {
+	helper := New(WithRetry(2, time.Millisecond))
+	call, calls := flakyCall(5, syscall.EAGAIN)
+
+	if _, err := helper.execute(context.Background(), "encrypt", call); !errors.Is(err, syscall.EAGAIN) {
+		t.Errorf("Expected last error to be returned, got %v", err)
+	}
+	if *calls != 2 {
+		t.Errorf("Expected 2 attempts, got %d", *calls)
+	}
+}
+
+func TestWithRetry_DoesNotRetryBinaryFailures(t *testing.T) {
+	helper := New(WithRetry(3, time.Millisecond))
+	call, calls := flakyCall(1, &BinaryError{ExitCode: 1})
+
+	if _, err := helper.execute(context.Background(), "encrypt", call); err == nil {
+		t.Error("Expected binary failure to be returned")
+	}
+	if *calls != 1 {
+		t.Errorf("Expected no retries, got %d attempts", *calls)
+	}
+}
+
+func TestWithRetry_RespectsDeadline(t *testing.T) {
+	helper := New(WithRetry(3, time.Hour))
+	call, calls := flakyCall(1, syscall.EAGAIN)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := helper.execute(ctx, "encrypt", call); !errors.Is(err, syscall.EAGAIN) {
+		t.Errorf("Expected transient error when backoff outlasts the deadline, got %v", err)
+	}
+	if *calls != 1 {
+		t.Errorf("Expected no retry past the deadline, got %d attempts", *calls)
+	}
+}
+
+func TestWithRetryJitter_RetriesTransientFailures(t *testing.T) {
+	helper := New(WithRetry(3, time.Millisecond), WithRetryJitter(true))
+	call, calls := flakyCall(2, syscall.EAGAIN)
+
+	out, err := helper.execute(context.Background(), "encrypt", call)
+	if err != nil || out != "ok" {
+		t.Fatalf("Expected success after jittered retries, got %q, %v", out, err)
+	}
+	if *calls != 3 {
+		t.Errorf("Expected 3 attempts, got %d", *calls)
+	}
+}
+
+func TestFullJitter(t *testing.T) {
+	backoff := 10 * time.Millisecond
+	for i := 0; i < 1000; i++ {
+		if wait := fullJitter(backoff); wait < 0 || wait > backoff {
+			t.Fatalf("Expected a wait between 0 and %v, got %v", backoff, wait)
+		}
+	}
+	if wait := fullJitter(0); wait != 0 {
+		t.Errorf("Expected no wait for a zero backoff, got %v", wait)
+	}
+}
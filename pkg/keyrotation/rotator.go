@@ -0,0 +1,16 @@
+package keyrotation
+
+import "time"
+
+// KeyRotator is the set of operations services typically depend on. *KeyRotationHelper
+// implements it; depend on KeyRotator instead to substitute fakes in unit tests.
+type KeyRotator interface {
+	EncryptApiKey(apiKey string) (string, error)
+	EncryptApiKeyWithDate(apiKey string, utcDateTime time.Time) (string, error)
+	ValidateApiKey(apiKey, encryptedKey string, utcDateTime time.Time) (bool, error)
+	ValidateApiKeyToday(apiKey, encryptedKey string) (bool, error)
+	ValidateApiKeyWithTolerance(apiKey, encryptedKey string, utcDateTime time.Time, toleranceMinutes int) (bool, error)
+	ValidateApiKeyTodayWithTolerance(apiKey, encryptedKey string, toleranceMinutes int) (bool, error)
+}
+
+var _ KeyRotator = (*KeyRotationHelper)(nil)
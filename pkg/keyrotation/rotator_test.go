@@ -0,0 +1,40 @@
+package keyrotation
+
+import (
+	"testing"
+	"time"
+)
+
+// stubRotator is the kind of fake consumers substitute for a helper
+type stubRotator struct{ valid bool }
+
+func (s stubRotator) EncryptApiKey(string) (string, error)                    { return "stub", nil }
+func (s stubRotator) EncryptApiKeyWithDate(string, time.Time) (string, error) { return "stub", nil }
+func (s stubRotator) ValidateApiKey(string, string, time.Time) (bool, error)  { return s.valid, nil }
+func (s stubRotator) ValidateApiKeyToday(string, string) (bool, error)        { return s.valid, nil }
+func (s stubRotator) ValidateApiKeyWithTolerance(string, string, time.Time, int) (bool, error) {
+	return s.valid, nil
+}
+func (s stubRotator) ValidateApiKeyTodayWithTolerance(string, string, int) (bool, error) {
+	return s.valid, nil
+}
+
+func TestKeyRotator_AcceptsHelperAndFakes(t *testing.T) {
+	authorize := func(r KeyRotator) bool {
+		valid, err := r.ValidateApiKeyToday("testApiKey123", "stored")
+		return err == nil && valid
+	}
+
+	if !authorize(stubRotator{valid: true}) {
+		t.Error("Expected stub to authorize")
+	}
+
+	helper := NewInMemory()
+	stored, err := helper.EncryptApiKey("testApiKey123")
+	if err != nil {
+		t.Fatalf("EncryptApiKey failed: %v", err)
+	}
+	if valid, err := KeyRotator(helper).ValidateApiKeyToday("testApiKey123", stored); err != nil || !valid {
+		t.Errorf("Expected helper to validate through the interface, got %v, %v", valid, err)
+	}
+}
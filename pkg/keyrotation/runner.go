@@ -0,0 +1,108 @@
+package keyrotation
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os/exec"
+	"sync"
+)
+
+// CommandRunner runs one binary invocation: the program name with args, reading stdin, and returns
+// what it wrote to stdout and stderr. It must stop the program once ctx is done. A program that ran
+// but exited unsuccessfully is reported with an error that has an ExitCode() int method, as
+// *exec.ExitError does, and becomes a *BinaryError; any other error is treated as a failure to
+// start it.
+//
+// Replacing the runner lets tests exercise argument construction, output parsing and error
+// handling without the binary, and lets the binary be run in a sandbox.
+type CommandRunner interface {
+	Run(ctx context.Context, name string, args []string, stdin io.Reader) (stdout, stderr []byte, err error)
+}
+
+// exitCoder is implemented by runner errors reporting a program that exited unsuccessfully
+type exitCoder interface {
+	ExitCode() int
+}
+
+// execRunner is the default CommandRunner, which runs the binary with os/exec in the helper's
+// working directory and environment, streaming its output to the configured tees as it arrives
+type execRunner struct {
+	k *KeyRotationHelper
+}
+
+func (e execRunner) Run(ctx context.Context, name string, args []string, stdin io.Reader) ([]byte, []byte, error) {
+	var stdout, stderr bytes.Buffer
+	err := e.run(ctx, name, args, stdin, &stdout, &stderr)
+	return stdout.Bytes(), stderr.Bytes(), err
+}
+
+// run is Run capturing the output in the given buffers, so the caller can reuse them
+func (e execRunner) run(ctx context.Context, name string, args []string, stdin io.Reader, stdout, stderr *bytes.Buffer) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = e.k.workDir
+	cmd.Env = e.k.processEnv()
+	cmd.WaitDelay = waitDelay
+	cmd.Stdin = stdin
+	cmd.Stdout = tee(stdout, e.k.stdoutTee)
+	cmd.Stderr = tee(stderr, e.k.stderrTee)
+	err := cmd.Run()
+	reportUsage(ctx, cmd.ProcessState)
+	return err
+}
+
+// maxPooledBuffer is the largest output buffer returned to outputBuffers, so one large batch does
+// not pin its memory for the life of the process
+const maxPooledBuffer = 64 << 10
+
+// outputBuffers recycles the buffers invocations capture the binary's output in
+var outputBuffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// getBuffer returns an empty buffer from outputBuffers
+func getBuffer() *bytes.Buffer {
+	return outputBuffers.Get().(*bytes.Buffer)
+}
+
+// putBuffer resets b and returns it to outputBuffers. b must not be used afterwards.
+func putBuffer(b *bytes.Buffer) {
+	if b.Cap() > maxPooledBuffer {
+		return
+	}
+	b.Reset()
+	outputBuffers.Put(b)
+}
+
+// runCommand runs one invocation on the configured runner, capturing its output in stdout and
+// stderr. The default runner writes straight into them; a custom runner's output is copied in and
+// sent to the configured tees.
+func (k *KeyRotationHelper) runCommand(ctx context.Context, name string, args []string, stdin io.Reader, stdout, stderr *bytes.Buffer) error {
+	if k.runner == nil {
+		return execRunner{k}.run(ctx, name, args, stdin, stdout, stderr)
+	}
+	out, errOut, err := k.runner.Run(ctx, name, args, stdin)
+	stdout.Write(out)
+	stderr.Write(errOut)
+	k.teeOutput(out, errOut)
+	return err
+}
+
+// wrapCommand prefixes the binary invocation with the wrapper command and its arguments, if any,
+// returning the program to run and its arguments
+func wrapCommand(wrapper []string, binaryPath string, argv []string) (string, []string) {
+	if len(wrapper) == 0 {
+		return binaryPath, argv
+	}
+	args := append(append(append([]string(nil), wrapper[1:]...), binaryPath), argv...)
+	return wrapper[0], args
+}
+
+// teeOutput copies a custom runner's output to the configured tees, which the default runner
+// streams to as the binary writes
+func (k *KeyRotationHelper) teeOutput(stdout, stderr []byte) {
+	if k.stdoutTee != nil {
+		k.stdoutTee.Write(stdout)
+	}
+	if k.stderrTee != nil {
+		k.stderrTee.Write(stderr)
+	}
+}
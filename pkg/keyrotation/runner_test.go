@@ -0,0 +1,151 @@
+package keyrotation
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// scriptedRunner is a CommandRunner that records each invocation and answers with canned output
+type scriptedRunner struct {
+	stdout, stderr string
+	err            error
+
+	name  string
+	args  []string
+	stdin string
+}
+
+func (s *scriptedRunner) Run(ctx context.Context, name string, args []string, stdin io.Reader) ([]byte, []byte, error) {
+	input, _ := io.ReadAll(stdin)
+	s.name, s.args, s.stdin = name, args, string(input)
+	return []byte(s.stdout), []byte(s.stderr), s.err
+}
+
+// exitStatus is a runner error reporting an unsuccessful exit
+type exitStatus int
+
+func (e exitStatus) Error() string { return "exit status" }
+func (e exitStatus) ExitCode() int { return int(e) }
+
+func TestWithCommandRunner_BuildsInvocation(t *testing.T) {
+	runner := &scriptedRunner{stdout: "TRUE\r\n"}
+	helper := NewWithBinaryPath("/sandbox/keyrotation-binary", WithCommandRunner(runner))
+
+	isValid, err := helper.ValidateApiKeyToday("testApiKey123", wrongCiphertext)
+	if err != nil || !isValid {
+		t.Fatalf("Expected the runner's output to be parsed as valid, got %v, %v", isValid, err)
+	}
+	if runner.name != "/sandbox/keyrotation-binary" {
+		t.Errorf("Expected the configured path to be passed unresolved, got %q", runner.name)
+	}
+	if want := []string{stdinFlag, "validate", wrongCiphertext}; !reflect.DeepEqual(runner.args, want) {
+		t.Errorf("Expected args %q, got %q", want, runner.args)
+	}
+	if runner.stdin != "testApiKey123\n" {
+		t.Errorf("Expected the key on stdin, got %q", runner.stdin)
+	}
+
+	runner.stdout = "usage"
+	if _, err := helper.ValidateApiKeyToday("testApiKey123", wrongCiphertext); !errors.Is(err, ErrUnexpectedOutput) {
+		t.Errorf("Expected ErrUnexpectedOutput, got %v", err)
+	}
+}
+
+func TestWithCommandRunner_Errors(t *testing.T) {
+	var stderrTee bytes.Buffer
+	runner := &scriptedRunner{stderr: "boom\n", err: exitStatus(3)}
+	helper := NewWithBinaryPath("keyrotation-binary", WithCommandRunner(runner), WithStderrTee(&stderrTee))
+
+	_, err := helper.EncryptApiKey("testApiKey123")
+	var binErr *BinaryError
+	if !errors.As(err, &binErr) || binErr.ExitCode != 3 || binErr.Stderr != "boom" {
+		t.Fatalf("Expected a BinaryError with exit code 3, got %v", err)
+	}
+	if stderrTee.String() != "boom\n" {
+		t.Errorf("Expected stderr to reach the tee, got %q", stderrTee.String())
+	}
+
+	runner.err = errors.New("sandbox unavailable")
+	if _, err := helper.EncryptApiKey("testApiKey123"); !errors.Is(err, ErrBinaryExecFailed) {
+		t.Errorf("Expected ErrBinaryExecFailed, got %v", err)
+	}
+}
+
+func TestWithExecWrapper_PrefixesCommand(t *testing.T) {
+	runner := &scriptedRunner{stdout: wrongCiphertext}
+	helper := NewWithBinaryPath("/opt/keyrotation-binary", WithCommandRunner(runner), WithExecWrapper("firejail", "--quiet"))
+
+	if _, err := helper.EncryptApiKey("testApiKey123"); err != nil {
+		t.Fatalf("EncryptApiKey failed: %v", err)
+	}
+	if want := []string{"--quiet", "/opt/keyrotation-binary", stdinFlag, "encrypt"}; runner.name != "firejail" || !reflect.DeepEqual(runner.args, want) {
+		t.Errorf("Expected firejail %q, got %s %q", want, runner.name, runner.args)
+	}
+
+	recorder := &eventRecorder{}
+	dryRun := NewWithBinaryPath("/opt/keyrotation-binary", WithDryRun(true), WithLogger(recorder), WithExecWrapper("firejail", "--quiet"))
+	dryRun.EncryptApiKey("testApiKey123")
+	if got := strings.Join(recorder.recorded()[0].Command, " "); got != "firejail --quiet /opt/keyrotation-binary --stdin encrypt" {
+		t.Errorf("Unexpected dry-run command %q", got)
+	}
+}
+
+func TestWithExecWrapper_RunsBinary(t *testing.T) {
+	if _, err := exec.LookPath("env"); err != nil {
+		t.Skip("env is not available")
+	}
+	helper := newFakeHelper(t, WithExecWrapper("env", "KEYROTATION_WRAPPED=1"))
+	want := fakeHash("testApiKey123", time.Now().UTC())
+	if got, err := helper.EncryptApiKey("testApiKey123"); err != nil || got != want {
+		t.Errorf("Expected %s through the wrapper, got %s, %v", want, got, err)
+	}
+
+	exe, _ := os.Executable()
+	persistent, err := NewPersistent(exe, WithExecWrapper("env", "KEYROTATION_WRAPPED=1"))
+	if err != nil {
+		t.Fatalf("NewPersistent failed under the wrapper: %v", err)
+	}
+	defer persistent.Close()
+	if got, err := persistent.EncryptApiKey("testApiKey123"); err != nil || got != want {
+		t.Errorf("Expected %s from the wrapped persistent process, got %s, %v", want, got, err)
+	}
+
+	t.Setenv(fakeModeEnv, "env")
+	if out, err := newFakeHelper(t, WithExecWrapper("env", "KEYROTATION_WRAPPED=1")).run(context.Background(), "encrypt", "testApiKey123"); err != nil || !strings.Contains(out, "KEYROTATION_WRAPPED=1") {
+		t.Errorf("Expected the binary to run under the wrapper, got %q, %v", out, err)
+	}
+}
+
+// BenchmarkExecRunner_Validate compares the default runner, which captures output in pooled
+// buffers, with the same runner behind WithCommandRunner, which allocates fresh buffers per call as
+// the default one did before pooling. Compare the two with -benchmem.
+func BenchmarkExecRunner_Validate(b *testing.B) {
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	encrypted := fakeHash("testApiKey123", date)
+	base := newFakeHelper(b)
+
+	for _, bc := range []struct {
+		name   string
+		helper *KeyRotationHelper
+	}{
+		{"pooled", base},
+		{"unpooled", base.Clone(WithCommandRunner(execRunner{base}))},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if _, err := bc.helper.ValidateApiKey("testApiKey123", encrypted, date); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package keyrotation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ScheduledKey is the ciphertext an API key rotates to on one date
+type ScheduledKey struct {
+	// Date is the rotation date, as midnight in the helper's location
+	Date time.Time
+	// EncryptedKey is the ciphertext valid on Date
+	EncryptedKey string
+}
+
+// RotationSchedule returns the ciphertext apiKey rotates to on every date from the date of from
+// through the date of to, inclusive, in the helper's location, in date order, so upcoming
+// ciphertexts can be precomputed and published. Binaries with the encrypt-date-batch subcommand
+// compute the whole schedule in one invocation; others are invoked once per date. Ranges of more
+// than MaxRangeDays days, or where to precedes from, are rejected.
+func (k *KeyRotationHelper) RotationSchedule(apiKey string, from, to time.Time) ([]ScheduledKey, error) {
+	return k.RotationScheduleContext(context.Background(), apiKey, from, to)
+}
+
+// RotationScheduleContext is like RotationSchedule but kills the binary if ctx is done first
+func (k *KeyRotationHelper) RotationScheduleContext(ctx context.Context, apiKey string, from, to time.Time) ([]ScheduledKey, error) {
+	if err := k.requireDaily("rotation schedule"); err != nil {
+		return nil, err
+	}
+	dates, err := k.datesInRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+	if err := k.checkKey(apiKey); err != nil {
+		return nil, fmt.Errorf("failed to compute rotation schedule: %w", err)
+	}
+	for _, date := range []time.Time{from, to} {
+		if err := k.checkDate(date); err != nil {
+			return nil, fmt.Errorf("failed to compute rotation schedule: %w", err)
+		}
+	}
+	if strings.ContainsAny(apiKey, "\r\n") {
+		return nil, errors.New("failed to compute rotation schedule: API key contains a line break")
+	}
+
+	// Each line is "<date>\t<apikey>": the date never contains a tab, so the key may
+	items := make([]string, len(dates))
+	for i, date := range dates {
+		items[i] = k.binaryDate(date) + "\t" + k.binaryKey(apiKey)
+	}
+	lines, err := k.runBatch(ctx, "encrypt-date-batch", items, []string{apiKey})
+	if isUnknownCommand(err) {
+		return k.scheduleEach(ctx, apiKey, dates)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to compute rotation schedule: %w", err)
+	}
+
+	schedule := make([]ScheduledKey, len(dates))
+	for i, line := range lines {
+		if msg, failed := strings.CutPrefix(line, batchErrorPrefix); failed || line == "" {
+			if !failed {
+				msg = "empty ciphertext"
+			}
+			return nil, fmt.Errorf("failed to compute rotation schedule: %w", &BatchError{Index: i, Err: errors.New(k.redact(msg, apiKey))})
+		}
+		schedule[i] = ScheduledKey{Date: dates[i], EncryptedKey: line}
+	}
+	return schedule, nil
+}
+
+// scheduleEach computes a rotation schedule with one encrypt-date invocation per date
+func (k *KeyRotationHelper) scheduleEach(ctx context.Context, apiKey string, dates []time.Time) ([]ScheduledKey, error) {
+	schedule := make([]ScheduledKey, len(dates))
+	for i, date := range dates {
+		encrypted, err := k.EncryptApiKeyWithDateContext(ctx, apiKey, date)
+		if err != nil {
+			return nil, fmt.Errorf("failed to compute rotation schedule: %w", &BatchError{Index: i, Err: err})
+		}
+		schedule[i] = ScheduledKey{Date: date, EncryptedKey: encrypted}
+	}
+	return schedule, nil
+}
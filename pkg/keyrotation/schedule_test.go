@@ -0,0 +1,66 @@
+package keyrotation
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestKeyRotationHelper_RotationSchedule(t *testing.T) {
+	from := time.Date(2024, 2, 27, 15, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 3, 2, 9, 0, 0, 0, time.UTC)
+	testApiKey := "testApiKey123"
+
+	t.Run("batch", func(t *testing.T) {
+		recorder := &eventRecorder{}
+		helper := newFakeHelper(t, WithLogger(recorder))
+		checkSchedule(t, helper, testApiKey, from, to)
+		if calls := len(recorder.recorded()); calls != 1 {
+			t.Errorf("Expected a single batch invocation, got %d", calls)
+		}
+	})
+	t.Run("fallback", func(t *testing.T) {
+		t.Setenv(fakeModeEnv, "legacy")
+		helper := newFakeHelper(t, WithLegacyArgv(true))
+		checkSchedule(t, helper, testApiKey, from, to)
+	})
+	t.Run("in-memory", func(t *testing.T) {
+		checkSchedule(t, NewInMemory(), testApiKey, from, to)
+	})
+}
+
+// checkSchedule asserts helper's schedule for the five days from 2024-02-27 through 2024-03-02
+func checkSchedule(t *testing.T, helper *KeyRotationHelper, apiKey string, from, to time.Time) {
+	t.Helper()
+	schedule, err := helper.RotationSchedule(apiKey, from, to)
+	if err != nil {
+		t.Fatalf("RotationSchedule failed: %v", err)
+	}
+	if len(schedule) != 5 {
+		t.Fatalf("Expected 5 scheduled keys, got %d", len(schedule))
+	}
+	for i, entry := range schedule {
+		date := time.Date(2024, 2, 27+i, 0, 0, 0, 0, time.UTC)
+		if !entry.Date.Equal(date) {
+			t.Errorf("Entry %d: expected date %v, got %v", i, date, entry.Date)
+		}
+		if entry.EncryptedKey != fakeHash(apiKey, date) {
+			t.Errorf("Entry %d: unexpected ciphertext %s", i, entry.EncryptedKey)
+		}
+	}
+}
+
+func TestKeyRotationHelper_RotationScheduleRejectsInvalidInput(t *testing.T) {
+	helper := NewInMemory()
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if _, err := helper.RotationSchedule("testApiKey123", from, from.AddDate(0, 0, MaxRangeDays)); err == nil {
+		t.Error("Expected error for a range longer than MaxRangeDays")
+	}
+	if _, err := helper.RotationSchedule("", from, from); !errors.Is(err, ErrEmptyKey) {
+		t.Errorf("Expected ErrEmptyKey, got %v", err)
+	}
+	if _, err := helper.RotationSchedule("line\nbreak", from, from); err == nil {
+		t.Error("Expected error for a key containing a line break")
+	}
+}
@@ -0,0 +1,56 @@
+package keyrotation
+
+import (
+	"context"
+	"sync"
+)
+
+// streamConcurrency is how many invocations EncryptApiKeyStream runs at once
+const streamConcurrency = 8
+
+// EncryptResult is the outcome of encrypting one API key received by EncryptApiKeyStream
+type EncryptResult struct {
+	ApiKey       string
+	EncryptedKey string
+	Err          error
+}
+
+// EncryptApiKeyStream encrypts each API key received from in with the current date, running up to
+// 8 invocations at once, and sends one EncryptResult per key on the returned channel. Results
+// arrive in completion order, not input order. The channel is closed once in is closed and every
+// received key has been answered, or as soon as ctx is done, in which case keys still in flight
+// are abandoned without a result. A failed key does not stop the stream.
+func (k *KeyRotationHelper) EncryptApiKeyStream(ctx context.Context, in <-chan string) <-chan EncryptResult {
+	out := make(chan EncryptResult)
+	var wg sync.WaitGroup
+	wg.Add(streamConcurrency)
+	for range streamConcurrency {
+		go func() {
+			defer wg.Done()
+			for {
+				var apiKey string
+				select {
+				case key, ok := <-in:
+					if !ok {
+						return
+					}
+					apiKey = key
+				case <-ctx.Done():
+					return
+				}
+
+				encrypted, err := k.EncryptApiKeyContext(ctx, apiKey)
+				select {
+				case out <- EncryptResult{ApiKey: apiKey, EncryptedKey: encrypted, Err: err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
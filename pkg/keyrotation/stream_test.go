@@ -0,0 +1,67 @@
+package keyrotation
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestKeyRotationHelper_EncryptApiKeyStream(t *testing.T) {
+	tracker := &concurrencyTracker{}
+	helper := NewInMemory(WithLogger(tracker))
+	helper.engine = engineFunc(func(ctx context.Context, r request) (string, error) {
+		tracker.start()
+		time.Sleep(2 * time.Millisecond)
+		return inMemoryEngine{algorithm: AlgorithmSHA256}.do(ctx, r)
+	})
+
+	in := make(chan string)
+	go func() {
+		defer close(in)
+		for _, key := range []string{"a", "b", "", "c", "d", "e", "f", "g", "h", "i", "j"} {
+			in <- key
+		}
+	}()
+
+	seen := map[string]bool{}
+	for result := range helper.EncryptApiKeyStream(context.Background(), in) {
+		seen[result.ApiKey] = true
+		if result.ApiKey == "" {
+			if !errors.Is(result.Err, ErrEmptyKey) {
+				t.Errorf("Expected ErrEmptyKey for the empty key, got %v", result.Err)
+			}
+			continue
+		}
+		if want := fakeHash(result.ApiKey, time.Now().UTC()); result.Err != nil || result.EncryptedKey != want {
+			t.Errorf("Expected %s for %q, got %s, %v", want, result.ApiKey, result.EncryptedKey, result.Err)
+		}
+	}
+	if len(seen) != 11 {
+		t.Errorf("Expected one result per key, got %d", len(seen))
+	}
+	if tracker.peak > streamConcurrency {
+		t.Errorf("Expected at most %d concurrent invocations, got %d", streamConcurrency, tracker.peak)
+	}
+}
+
+func TestKeyRotationHelper_EncryptApiKeyStreamCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	in := make(chan string)
+	out := NewInMemory().EncryptApiKeyStream(ctx, in)
+
+	in <- "a"
+	if result := <-out; result.Err != nil {
+		t.Fatalf("Expected a result before cancellation, got %v", result.Err)
+	}
+	cancel()
+
+	select {
+	case _, ok := <-out:
+		if ok {
+			t.Error("Expected no results after cancellation")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Expected the output channel to close when ctx is done")
+	}
+}
@@ -0,0 +1,37 @@
+package keyrotation
+
+import (
+	"io"
+	"sync"
+)
+
+// teeWriter duplicates binary output to a caller-supplied writer for debugging. Writes are
+// serialized, since concurrent invocations share it, and its errors are swallowed so a failing
+// debug writer never fails the call it is observing.
+type teeWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (t *teeWriter) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.w.Write(p)
+	return len(p), nil
+}
+
+// newTeeWriter returns a teeWriter for w, or nil if w is nil
+func newTeeWriter(w io.Writer) *teeWriter {
+	if w == nil {
+		return nil
+	}
+	return &teeWriter{w: w}
+}
+
+// tee returns dst, duplicated to t if t is non-nil
+func tee(dst io.Writer, t *teeWriter) io.Writer {
+	if t == nil {
+		return dst
+	}
+	return io.MultiWriter(dst, t)
+}
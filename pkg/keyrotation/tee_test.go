@@ -0,0 +1,63 @@
+package keyrotation
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// failingWriter is a writer that rejects every write
+type failingWriter struct{}
+
+func (failingWriter) Write(p []byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestWithStdoutTee(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	helper := newFakeHelper(t, WithStdoutTee(&stdout), WithStderrTee(&stderr))
+
+	encrypted, err := helper.EncryptApiKey("testApiKey123")
+	if err != nil {
+		t.Fatalf("EncryptApiKey failed: %v", err)
+	}
+	if encrypted != fakeHash("testApiKey123", time.Now().UTC()) {
+		t.Errorf("Expected output to be parsed as usual, got %s", encrypted)
+	}
+	if stdout.String() != encrypted+"\n" {
+		t.Errorf("Expected raw stdout %q to be teed, got %q", encrypted+"\n", stdout.String())
+	}
+
+	helper.run(context.Background(), "no-such-command", "testApiKey123")
+	if !strings.Contains(stderr.String(), "Unknown command: no-such-command") {
+		t.Errorf("Expected raw stderr to be teed, got %q", stderr.String())
+	}
+}
+
+func TestWithStdoutTee_Persistent(t *testing.T) {
+	var stdout bytes.Buffer
+	helper := newPersistentFakeHelper(t, WithStdoutTee(&stdout))
+
+	encrypted, err := helper.EncryptApiKey("testApiKey123")
+	if err != nil {
+		t.Fatalf("EncryptApiKey failed: %v", err)
+	}
+	if !strings.Contains(stdout.String(), "ok\t"+encrypted+"\n") {
+		t.Errorf("Expected serve responses to be teed, got %q", stdout.String())
+	}
+}
+
+func TestWithStdoutTee_NilAndFailingWriters(t *testing.T) {
+	helpers := map[string]*KeyRotationHelper{
+		"nil":     newFakeHelper(t, WithStdoutTee(nil), WithStderrTee(nil)),
+		"failing": newFakeHelper(t, WithStdoutTee(failingWriter{}), WithStderrTee(failingWriter{})),
+	}
+	for name, helper := range helpers {
+		if _, err := helper.EncryptApiKey("testApiKey123"); err != nil {
+			t.Errorf("%s: Expected tee writer not to affect the call, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package keyrotation
+
+import "time"
+
+// EncryptApiKeyTimed is like EncryptApiKey but also returns how long the call took, for ad-hoc
+// benchmarking or response metadata without configuring a Logger or MetricsObserver. The duration
+// is the wall-clock time of the whole call, including any retries.
+func (k *KeyRotationHelper) EncryptApiKeyTimed(apiKey string) (string, time.Duration, error) {
+	start := time.Now()
+	encrypted, err := k.EncryptApiKey(apiKey)
+	return encrypted, time.Since(start), err
+}
+
+// EncryptApiKeyWithDateTimed is like EncryptApiKeyWithDate but also returns how long the call took
+func (k *KeyRotationHelper) EncryptApiKeyWithDateTimed(apiKey string, utcDateTime time.Time) (string, time.Duration, error) {
+	start := time.Now()
+	encrypted, err := k.EncryptApiKeyWithDate(apiKey, utcDateTime)
+	return encrypted, time.Since(start), err
+}
+
+// ValidateApiKeyTimed is like ValidateApiKey but also returns how long the call took
+func (k *KeyRotationHelper) ValidateApiKeyTimed(apiKey, encryptedKey string, utcDateTime time.Time) (bool, time.Duration, error) {
+	start := time.Now()
+	isValid, err := k.ValidateApiKey(apiKey, encryptedKey, utcDateTime)
+	return isValid, time.Since(start), err
+}
+
+// ValidateApiKeyTodayTimed is like ValidateApiKeyToday but also returns how long the call took
+func (k *KeyRotationHelper) ValidateApiKeyTodayTimed(apiKey, encryptedKey string) (bool, time.Duration, error) {
+	start := time.Now()
+	isValid, err := k.ValidateApiKeyToday(apiKey, encryptedKey)
+	return isValid, time.Since(start), err
+}
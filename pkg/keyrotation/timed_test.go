@@ -0,0 +1,41 @@
+package keyrotation
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestKeyRotationHelper_Timed(t *testing.T) {
+	helper := newFakeHelper(t)
+	date := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+
+	encrypted, d, err := helper.EncryptApiKeyTimed("testApiKey123")
+	if err != nil || d <= 0 {
+		t.Fatalf("EncryptApiKeyTimed = %s, %s, %v", encrypted, d, err)
+	}
+	if valid, d, err := helper.ValidateApiKeyTodayTimed("testApiKey123", encrypted); err != nil || !valid || d <= 0 {
+		t.Errorf("ValidateApiKeyTodayTimed = %v, %s, %v", valid, d, err)
+	}
+
+	dated, d, err := helper.EncryptApiKeyWithDateTimed("testApiKey123", date)
+	if err != nil || d <= 0 {
+		t.Fatalf("EncryptApiKeyWithDateTimed = %s, %s, %v", dated, d, err)
+	}
+	if valid, d, err := helper.ValidateApiKeyTimed("testApiKey123", dated, date); err != nil || !valid || d <= 0 {
+		t.Errorf("ValidateApiKeyTimed = %v, %s, %v", valid, d, err)
+	}
+}
+
+func TestKeyRotationHelper_TimedReportsFailures(t *testing.T) {
+	t.Setenv(fakeModeEnv, "hang")
+	helper := newFakeHelper(t, WithTimeout(50*time.Millisecond))
+
+	_, d, err := helper.EncryptApiKeyTimed("testApiKey123")
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("Expected ErrTimeout, got %v", err)
+	}
+	if d < 50*time.Millisecond {
+		t.Errorf("Expected the duration to cover the timeout, got %s", d)
+	}
+}
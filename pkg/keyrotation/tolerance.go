@@ -0,0 +1,51 @@
+package keyrotation
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// maxEmulatedToleranceMinutes bounds the tolerance emulateTolerance accepts, a week either side,
+// so a mistyped tolerance cannot turn one validation into thousands of binary invocations
+const maxEmulatedToleranceMinutes = 7 * 24 * 60
+
+// checkTolerance rejects a negative tolerance with ErrUnsafeArgument, the error the binary path
+// reports for it since the minutes would be parsed as a flag, so every path fails alike
+func checkTolerance(toleranceMinutes int) error {
+	if toleranceMinutes < 0 {
+		return fmt.Errorf("%w: negative tolerance of %d minutes", ErrUnsafeArgument, toleranceMinutes)
+	}
+	return nil
+}
+
+// emulateTolerance stands in for the validate-tolerance-date subcommand on binaries without it. It
+// encrypts apiKey once for each rotation date within toleranceMinutes either side of at, the date
+// of at first, and compares the result with encryptedKey. The search stops at the first match or
+// once ctx is done.
+func (k *KeyRotationHelper) emulateTolerance(ctx context.Context, apiKey, encryptedKey string, at time.Time, toleranceMinutes int) (bool, error) {
+	if err := checkTolerance(toleranceMinutes); err != nil {
+		return false, err
+	}
+	if toleranceMinutes > maxEmulatedToleranceMinutes {
+		return false, fmt.Errorf("%w: validate-tolerance-date: tolerance of %d minutes exceeds the %d that can be emulated",
+			ErrUnsupported, toleranceMinutes, maxEmulatedToleranceMinutes)
+	}
+	if err := k.checkCiphertext(encryptedKey); err != nil {
+		return false, err
+	}
+
+	for _, date := range toleranceDates(at.In(k.location), toleranceMinutes, k.RotationInterval()) {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		encrypted, err := k.encryptWithDate(ctx, apiKey, date)
+		if err != nil {
+			return false, err
+		}
+		if CiphertextEqual(encrypted, encryptedKey) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,40 @@
+package keyrotation
+
+import (
+	"context"
+	"os"
+	"time"
+)
+
+// ResourceUsage is the CPU time a binary invocation consumed, as reported by the operating system
+// once the process exits
+type ResourceUsage struct {
+	// UserTime is the CPU time spent in the binary's own code
+	UserTime time.Duration
+	// SystemTime is the CPU time the kernel spent on the binary's behalf, including starting it
+	SystemTime time.Duration
+}
+
+// ResourceUsageObserver is implemented by a MetricsObserver that also wants the CPU time of each
+// invocation when WithResourceUsage is enabled. op is the binary subcommand, as in Event.Operation,
+// and usage is only reported for invocations that started a process.
+type ResourceUsageObserver interface {
+	ObserveResourceUsage(op string, usage ResourceUsage)
+}
+
+// usageKey is the context key under which attempt passes the slot execRunner reports usage in
+type usageKey struct{}
+
+// withUsageSlot returns a context from which reportUsage records into slot
+func withUsageSlot(ctx context.Context, slot **ResourceUsage) context.Context {
+	return context.WithValue(ctx, usageKey{}, slot)
+}
+
+// reportUsage records the usage of the exited process state in the slot carried by ctx, if any
+func reportUsage(ctx context.Context, state *os.ProcessState) {
+	slot, ok := ctx.Value(usageKey{}).(**ResourceUsage)
+	if !ok || state == nil {
+		return
+	}
+	*slot = &ResourceUsage{UserTime: state.UserTime(), SystemTime: state.SystemTime()}
+}
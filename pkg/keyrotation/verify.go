@@ -0,0 +1,79 @@
+package keyrotation
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// pingKey is the sentinel API key Ping round-trips, and pingDate the date it is encrypted for.
+// The date is fixed in the past so the round-trip is never answered from the validation cache.
+var (
+	pingKey  = "keyrotation-ping"
+	pingDate = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+)
+
+// VerifyBinary runs the binary's selftest subcommand and returns nil only if it reports a healthy
+// result ("ok"). It is intended for readiness probes. Binaries without the subcommand yield an
+// error wrapping ErrUnsupported.
+func (k *KeyRotationHelper) VerifyBinary() error {
+	result, err := k.runKeyless(context.Background(), "selftest")
+	if isUnknownCommand(err) {
+		err = fmt.Errorf("%w: selftest: %w", ErrUnsupported, err)
+	}
+	if err == nil && result != "ok" {
+		err = fmt.Errorf("%w: selftest reported %q", ErrUnexpectedOutput, result)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to verify binary: %w", err)
+	}
+
+	return nil
+}
+
+// BinaryVersion returns the version string reported by the binary's version subcommand.
+// Binaries without the subcommand yield an error wrapping ErrUnsupported.
+func (k *KeyRotationHelper) BinaryVersion() (string, error) {
+	result, err := k.runKeyless(context.Background(), "version")
+	if isUnknownCommand(err) {
+		err = fmt.Errorf("%w: version: %w", ErrUnsupported, err)
+	}
+	if err == nil && result == "" {
+		err = fmt.Errorf("%w: empty version", ErrUnexpectedOutput)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get binary version: %w", err)
+	}
+
+	return result, nil
+}
+
+// Ping encrypts a fixed sentinel key and validates the result, returning nil only if the binary
+// accepts the ciphertext for its date and rejects it for another. It is intended for readiness
+// probes: it catches a missing or non-executable binary as well as one that answers inconsistently.
+func (k *KeyRotationHelper) Ping(ctx context.Context) error {
+	// The sentinel date predates any sensible WithEarliestDate, so it bypasses the bounds
+	encrypted, err := k.encryptWithDate(ctx, pingKey, pingDate)
+	if err != nil {
+		return fmt.Errorf("ping failed: %w", err)
+	}
+	if _, err := k.decodeCiphertext(encrypted); err != nil {
+		return fmt.Errorf("ping failed: %w: encrypt printed %q: %w", ErrUnexpectedOutput, encrypted, err)
+	}
+
+	isValid, err := k.ValidateApiKeyContext(ctx, pingKey, encrypted, pingDate)
+	if err == nil && !isValid {
+		err = fmt.Errorf("%w: sentinel key did not validate for its own date", ErrUnexpectedOutput)
+	}
+	if err == nil {
+		isValid, err = k.ValidateApiKeyContext(ctx, pingKey, encrypted, pingDate.AddDate(0, 0, 1))
+		if err == nil && isValid {
+			err = fmt.Errorf("%w: sentinel key validated for the wrong date", ErrUnexpectedOutput)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("ping failed: %w", err)
+	}
+
+	return nil
+}
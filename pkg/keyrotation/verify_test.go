@@ -0,0 +1,63 @@
+package keyrotation
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestKeyRotationHelper_VerifyBinary(t *testing.T) {
+	if err := newFakeHelper(t).VerifyBinary(); err != nil {
+		t.Errorf("Expected healthy binary, got %v", err)
+	}
+	if err := newPersistentFakeHelper(t).VerifyBinary(); err != nil {
+		t.Errorf("Expected healthy persistent binary, got %v", err)
+	}
+
+	t.Setenv(fakeModeEnv, "selftest-fail")
+	if err := newFakeHelper(t).VerifyBinary(); !errors.Is(err, ErrBinaryExecFailed) {
+		t.Errorf("Expected failing selftest to be reported, got %v", err)
+	}
+}
+
+func TestKeyRotationHelper_BinaryVersion(t *testing.T) {
+	version, err := newFakeHelper(t).BinaryVersion()
+	if err != nil {
+		t.Fatalf("BinaryVersion failed: %v", err)
+	}
+	if version != fakeVersion {
+		t.Errorf("Expected version %s, got %s", fakeVersion, version)
+	}
+}
+
+func TestKeyRotationHelper_VerifyBinaryUnsupported(t *testing.T) {
+	t.Setenv(fakeModeEnv, "legacy")
+	helper := newFakeHelper(t)
+
+	if err := helper.VerifyBinary(); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported from VerifyBinary, got %v", err)
+	}
+	if _, err := helper.BinaryVersion(); !errors.Is(err, ErrUnsupported) {
+		t.Errorf("Expected ErrUnsupported from BinaryVersion, got %v", err)
+	}
+}
+
+func TestKeyRotationHelper_Ping(t *testing.T) {
+	if err := newFakeHelper(t).Ping(context.Background()); err != nil {
+		t.Errorf("Expected healthy binary to answer ping, got %v", err)
+	}
+	if err := NewInMemory().Ping(context.Background()); err != nil {
+		t.Errorf("Expected in-memory helper to answer ping, got %v", err)
+	}
+
+	missing := NewWithBinaryPath(filepath.Join(t.TempDir(), "keyrotation-binary"))
+	if err := missing.Ping(context.Background()); !errors.Is(err, ErrBinaryNotFound) {
+		t.Errorf("Expected ErrBinaryNotFound, got %v", err)
+	}
+
+	t.Setenv(fakeModeEnv, "always-true")
+	if err := newFakeHelper(t).Ping(context.Background()); !errors.Is(err, ErrUnexpectedOutput) {
+		t.Errorf("Expected inconsistent binary to fail ping, got %v", err)
+	}
+}
@@ -0,0 +1,124 @@
+package keyrotation
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// versionConstraint is a parsed WithRequiredBinaryVersion constraint: comparisons that must all hold
+type versionConstraint struct {
+	text  string
+	terms []versionTerm
+}
+
+// versionTerm is one comparison of a constraint, such as ">=1.4"
+type versionTerm struct {
+	op      string
+	version [3]int
+}
+
+// versionOps are the supported comparison operators, longest first so prefixes match correctly
+var versionOps = []string{">=", "<=", "!=", ">", "<", "="}
+
+// parseVersionConstraint parses comma-separated comparisons such as ">=1.4.0, <2". A version
+// without an operator must match exactly.
+func parseVersionConstraint(s string) (*versionConstraint, error) {
+	c := &versionConstraint{text: s}
+	for _, field := range strings.Split(s, ",") {
+		field = strings.TrimSpace(field)
+		op := "="
+		for _, candidate := range versionOps {
+			if rest, ok := strings.CutPrefix(field, candidate); ok {
+				op, field = candidate, strings.TrimSpace(rest)
+				break
+			}
+		}
+		version, err := parseVersion(field)
+		if err != nil {
+			return nil, fmt.Errorf("keyrotation: invalid version constraint %q: %w", s, err)
+		}
+		c.terms = append(c.terms, versionTerm{op: op, version: version})
+	}
+	return c, nil
+}
+
+// parseVersion parses a major[.minor[.patch]] version with an optional "v" prefix; missing parts
+// are zero, and any pre-release or build suffix after a dash or plus is ignored
+func parseVersion(s string) ([3]int, error) {
+	var version [3]int
+	core, _, _ := strings.Cut(strings.TrimPrefix(s, "v"), "+")
+	core, _, _ = strings.Cut(core, "-")
+	parts := strings.Split(core, ".")
+	if len(parts) > len(version) {
+		return version, fmt.Errorf("version %q has more than three parts", s)
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return version, fmt.Errorf("version %q is not of the form major.minor.patch", s)
+		}
+		version[i] = n
+	}
+	return version, nil
+}
+
+// allows reports whether version satisfies every comparison of the constraint
+func (c *versionConstraint) allows(version [3]int) bool {
+	for _, term := range c.terms {
+		cmp := slices.Compare(version[:], term.version[:])
+		var ok bool
+		switch term.op {
+		case ">=":
+			ok = cmp >= 0
+		case "<=":
+			ok = cmp <= 0
+		case "!=":
+			ok = cmp != 0
+		case ">":
+			ok = cmp > 0
+		case "<":
+			ok = cmp < 0
+		default:
+			ok = cmp == 0
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// checkBinaryVersion runs the version subcommand before the first call that needs the binary and
+// fails with ErrBinaryVersionMismatch if its answer does not satisfy WithRequiredBinaryVersion.
+// The outcome is kept once the binary has answered, so the check costs one invocation per helper;
+// failures to run the subcommand are returned and retried on the next call. Dry-run and in-memory
+// helpers have no binary to check.
+func (k *KeyRotationHelper) checkBinaryVersion(ctx context.Context, r request) error {
+	if k.requiredVersion == nil || k.dryRun || r.command == "version" {
+		return nil
+	}
+	if _, ok := k.engine.(inMemoryEngine); ok {
+		return nil
+	}
+
+	k.versionMu.Lock()
+	defer k.versionMu.Unlock()
+	if k.versionChecked {
+		return k.versionErr
+	}
+	result, err := k.runKeyless(ctx, "version")
+	if err != nil && !isUnknownCommand(err) {
+		return fmt.Errorf("failed to check binary version: %w", err)
+	}
+
+	k.versionChecked = true
+	if err != nil {
+		k.versionErr = fmt.Errorf("%w: binary has no version subcommand to check against %q: %w", ErrBinaryVersionMismatch, k.requiredVersion.text, err)
+	} else if version, err := parseVersion(result); err != nil || !k.requiredVersion.allows(version) {
+		k.versionErr = fmt.Errorf("%w: binary reported version %q, which does not satisfy %q", ErrBinaryVersionMismatch, result, k.requiredVersion.text)
+	}
+	return k.versionErr
+}
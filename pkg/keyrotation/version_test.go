@@ -0,0 +1,79 @@
+package keyrotation
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestVersionConstraint(t *testing.T) {
+	for _, tc := range []struct {
+		constraint, version string
+		want                bool
+	}{
+		{">=1.4.0, <2", "1.4.2", true},
+		{">=1.4.0, <2", "2.0.0", false},
+		{">1.4.2", "1.4.2", false},
+		{"<=1.4", "1.4.0", true},
+		{"1.4.2", "v1.4.2", true},
+		{"=1.4.2", "1.4.2-rc1", true},
+		{"!=1.4.2", "1.4.3", true},
+		{">=1.10", "1.9.9", false},
+	} {
+		c, err := parseVersionConstraint(tc.constraint)
+		if err != nil {
+			t.Fatalf("parseVersionConstraint(%q) failed: %v", tc.constraint, err)
+		}
+		version, err := parseVersion(tc.version)
+		if err != nil {
+			t.Fatalf("parseVersion(%q) failed: %v", tc.version, err)
+		}
+		if got := c.allows(version); got != tc.want {
+			t.Errorf("Expected %q allows %q to be %v", tc.constraint, tc.version, tc.want)
+		}
+	}
+	for _, constraint := range []string{"", ">=", "~1.4", "1.4.2.1", ">=1.x"} {
+		if _, err := NewWithOptions(WithRequiredBinaryVersion(constraint)); err == nil {
+			t.Errorf("Expected constraint %q to be rejected", constraint)
+		}
+	}
+}
+
+func TestWithRequiredBinaryVersion(t *testing.T) {
+	recorder := &eventRecorder{}
+	helper := newFakeHelper(t, WithRequiredBinaryVersion(">=1.4, <2"), WithLogger(recorder))
+	for range 2 {
+		if _, err := helper.EncryptApiKey("testApiKey123"); err != nil {
+			t.Fatalf("Expected fake binary %s to satisfy the constraint, got %v", fakeVersion, err)
+		}
+	}
+	if got := len(recorder.recorded()); got != 3 {
+		t.Errorf("Expected the version to be checked once, got %d invocations", got)
+	}
+
+	recorder = &eventRecorder{}
+	stale := newFakeHelper(t, WithRequiredBinaryVersion(">=2.0"), WithLogger(recorder))
+	for range 2 {
+		if _, err := stale.EncryptApiKey("testApiKey123"); !errors.Is(err, ErrBinaryVersionMismatch) {
+			t.Errorf("Expected ErrBinaryVersionMismatch, got %v", err)
+		}
+	}
+	if got := len(recorder.recorded()); got != 1 {
+		t.Errorf("Expected a mismatch to be remembered, got %d invocations", got)
+	}
+	if version, err := stale.BinaryVersion(); err != nil || version != fakeVersion {
+		t.Errorf("Expected BinaryVersion to stay usable, got %q, %v", version, err)
+	}
+
+	t.Setenv(fakeModeEnv, "legacy")
+	if _, err := newFakeHelper(t, WithRequiredBinaryVersion(">=1.0")).EncryptApiKey("testApiKey123"); !errors.Is(err, ErrBinaryVersionMismatch) {
+		t.Errorf("Expected a binary without a version subcommand to fail the check, got %v", err)
+	}
+	t.Setenv(fakeModeEnv, "")
+
+	if _, err := NewWithBinaryPath("/nonexistent/keyrotation-binary", WithRequiredBinaryVersion(">=1.0")).EncryptApiKey("testApiKey123"); !errors.Is(err, ErrBinaryNotFound) || errors.Is(err, ErrBinaryVersionMismatch) {
+		t.Errorf("Expected a missing binary to be reported as such, got %v", err)
+	}
+	if _, err := NewInMemory(WithRequiredBinaryVersion(">=2.0")).EncryptApiKey("testApiKey123"); err != nil {
+		t.Errorf("Expected in-memory helpers to skip the check, got %v", err)
+	}
+}
@@ -0,0 +1,241 @@
+package keyrotation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ValidateApiKeyWithinDays validates an encrypted API key against today and each of the previous
+// daysBack days, in the helper's location. Dates are checked most recent first and the search stops
+// at the first match, so the cost is between 1 and daysBack+1 binary invocations. With
+// WithWindowConcurrency the dates are checked in parallel instead. Windows of more than
+// MaxRangeDays days are rejected.
+func (k *KeyRotationHelper) ValidateApiKeyWithinDays(apiKey, encryptedKey string, daysBack int) (bool, error) {
+	return k.ValidateApiKeyWithinDaysContext(context.Background(), apiKey, encryptedKey, daysBack)
+}
+
+// ValidateApiKeyWithinDaysContext is like ValidateApiKeyWithinDays but stops checking once ctx is done
+func (k *KeyRotationHelper) ValidateApiKeyWithinDaysContext(ctx context.Context, apiKey, encryptedKey string, daysBack int) (bool, error) {
+	_, isValid, err := k.ValidateAndResolveDateContext(ctx, apiKey, encryptedKey, daysBack)
+	return isValid, err
+}
+
+// ValidateAndResolveDate is like ValidateApiKeyWithinDays but also returns the date the key matched,
+// as midnight in the helper's location, so callers can tell how far behind rotation a client is.
+// The date is the zero time when the key does not match any day in the window.
+func (k *KeyRotationHelper) ValidateAndResolveDate(apiKey, encryptedKey string, daysBack int) (time.Time, bool, error) {
+	return k.ValidateAndResolveDateContext(context.Background(), apiKey, encryptedKey, daysBack)
+}
+
+// ValidateAndResolveDateContext is like ValidateAndResolveDate but stops checking once ctx is done
+func (k *KeyRotationHelper) ValidateAndResolveDateContext(ctx context.Context, apiKey, encryptedKey string, daysBack int) (time.Time, bool, error) {
+	if daysBack < 0 {
+		return time.Time{}, false, errors.New("keyrotation: daysBack must not be negative")
+	}
+	if daysBack >= MaxRangeDays {
+		return time.Time{}, false, fmt.Errorf("keyrotation: window spans more than %d days", MaxRangeDays)
+	}
+	if err := k.requireDaily("windowed validation"); err != nil {
+		return time.Time{}, false, err
+	}
+
+	today := k.startOfDay(k.now())
+	dates := make([]time.Time, daysBack+1)
+	for i := range dates {
+		dates[i] = today.AddDate(0, 0, -i)
+	}
+	return k.firstMatch(ctx, apiKey, encryptedKey, dates)
+}
+
+// ValidateApiKeyWithinIntervals validates an encrypted API key against the current rotation interval
+// and each of the previous n, as configured with WithRotationInterval: n days with the default daily
+// rotation, or n hours with hourly rotation. Intervals are checked most recent first, stopping at the
+// first match, so the cost is between 1 and n+1 binary invocations. With WithWindowConcurrency the
+// intervals are checked in parallel instead. Windows spanning more than MaxRangeDays days are
+// rejected.
+func (k *KeyRotationHelper) ValidateApiKeyWithinIntervals(apiKey, encryptedKey string, n int) (bool, error) {
+	return k.ValidateApiKeyWithinIntervalsContext(context.Background(), apiKey, encryptedKey, n)
+}
+
+// ValidateApiKeyWithinIntervalsContext is like ValidateApiKeyWithinIntervals but stops checking once ctx is done
+func (k *KeyRotationHelper) ValidateApiKeyWithinIntervalsContext(ctx context.Context, apiKey, encryptedKey string, n int) (bool, error) {
+	if n < 0 {
+		return false, errors.New("keyrotation: n must not be negative")
+	}
+	// Compared as a count of intervals, since n*interval may overflow
+	if n >= int(MaxRangeDays*24*time.Hour/k.RotationInterval()) {
+		return false, fmt.Errorf("keyrotation: window spans more than %d days", MaxRangeDays)
+	}
+
+	// Step back from the start of each interval, so days with a DST transition are still
+	// divided as startOfInterval divides them
+	dates := make([]time.Time, n+1)
+	dates[0] = k.startOfInterval(k.now())
+	for i := 1; i < len(dates); i++ {
+		dates[i] = k.startOfInterval(dates[i-1].Add(-time.Nanosecond))
+	}
+	_, isValid, err := k.firstMatch(ctx, apiKey, encryptedKey, dates)
+	return isValid, err
+}
+
+// ValidateApiKeyWithGrace validates an encrypted API key against today's date and, when now is within
+// grace of a day boundary in the helper's location, also against the date on the other side of it.
+// With WithRotationInterval the boundaries are those of the interval instead.
+// This smooths rotation cutover for clients that do not refresh exactly at midnight: shortly after
+// midnight yesterday's ciphertext is still accepted, and shortly before it tomorrow's already is.
+func (k *KeyRotationHelper) ValidateApiKeyWithGrace(apiKey, encryptedKey string, grace time.Duration) (bool, error) {
+	return k.ValidateApiKeyWithGraceContext(context.Background(), apiKey, encryptedKey, grace)
+}
+
+// ValidateApiKeyWithGraceContext is like ValidateApiKeyWithGrace but stops checking once ctx is done
+func (k *KeyRotationHelper) ValidateApiKeyWithGraceContext(ctx context.Context, apiKey, encryptedKey string, grace time.Duration) (bool, error) {
+	return k.validateWithGrace(ctx, apiKey, encryptedKey, k.now(), grace)
+}
+
+// validateWithGrace validates against the interval containing at and any adjacent interval whose boundary is within grace of at
+func (k *KeyRotationHelper) validateWithGrace(ctx context.Context, apiKey, encryptedKey string, at time.Time, grace time.Duration) (bool, error) {
+	if grace < 0 {
+		return false, errors.New("keyrotation: grace must not be negative")
+	}
+
+	current := k.startOfInterval(at)
+	next := k.startOfInterval(current.Add(k.RotationInterval()))
+	dates := []time.Time{current}
+	if at.Sub(current) < grace {
+		dates = append(dates, k.startOfInterval(current.Add(-time.Nanosecond)))
+	}
+	if next.Sub(at) <= grace {
+		dates = append(dates, next)
+	}
+
+	_, isValid, err := k.firstMatch(ctx, apiKey, encryptedKey, dates)
+	return isValid, err
+}
+
+// firstMatch validates an encrypted API key against each of dates and returns the first that
+// matches. Dates are checked in order, stopping at the first match or error, unless
+// WithWindowConcurrency allows checking several at once.
+func (k *KeyRotationHelper) firstMatch(ctx context.Context, apiKey, encryptedKey string, dates []time.Time) (time.Time, bool, error) {
+	if k.windowConcurrency > 1 && len(dates) > 1 {
+		return k.firstMatchParallel(ctx, apiKey, encryptedKey, dates)
+	}
+	for _, date := range dates {
+		isValid, err := k.ValidateApiKeyContext(ctx, apiKey, encryptedKey, date)
+		if err != nil {
+			return time.Time{}, false, err
+		}
+		if isValid {
+			return date, true, nil
+		}
+	}
+	return time.Time{}, false, nil
+}
+
+// firstMatchParallel is firstMatch running up to windowConcurrency validations at once. The first
+// match or error cancels the invocations still in flight and stops new ones from starting; a
+// match found by any of them wins over an error, and among several matches the earliest date in
+// dates is returned.
+func (k *KeyRotationHelper) firstMatchParallel(ctx context.Context, apiKey, encryptedKey string, dates []time.Time) (time.Time, bool, error) {
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		matched  = -1
+		firstErr error
+	)
+	slots := make(chan struct{}, k.windowConcurrency)
+launch:
+	for i, date := range dates {
+		select {
+		case slots <- struct{}{}:
+		case <-runCtx.Done():
+			break launch
+		}
+		if runCtx.Err() != nil {
+			break
+		}
+
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-slots
+				wg.Done()
+			}()
+			isValid, err := k.ValidateApiKeyContext(runCtx, apiKey, encryptedKey, date)
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case isValid:
+				if matched < 0 || i < matched {
+					matched = i
+				}
+				cancel()
+			case err != nil && firstErr == nil:
+				firstErr = err
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if matched >= 0 {
+		return dates[matched], true, nil
+	}
+	if err := ctx.Err(); err != nil {
+		return time.Time{}, false, err
+	}
+	return time.Time{}, false, firstErr
+}
+
+// MaxRangeDays is the largest number of days ValidateApiKeyInRange, RotationSchedule and the
+// windowed validations will cover.
+// Each day costs binary work, so wider ranges are rejected rather than risk an accidental denial of service.
+const MaxRangeDays = 366
+
+// ValidateApiKeyInRange validates an encrypted API key against every rotation date from the date of
+// from through the date of to, inclusive, in the helper's location. It accepts clients that are
+// ahead of rotation as well as behind. The search stops at the first match, and ranges of more
+// than MaxRangeDays days, or where to precedes from, are rejected.
+func (k *KeyRotationHelper) ValidateApiKeyInRange(apiKey, encryptedKey string, from, to time.Time) (bool, error) {
+	return k.ValidateApiKeyInRangeContext(context.Background(), apiKey, encryptedKey, from, to)
+}
+
+// ValidateApiKeyInRangeContext is like ValidateApiKeyInRange but stops checking once ctx is done
+func (k *KeyRotationHelper) ValidateApiKeyInRangeContext(ctx context.Context, apiKey, encryptedKey string, from, to time.Time) (bool, error) {
+	if err := k.requireDaily("range validation"); err != nil {
+		return false, err
+	}
+	dates, err := k.datesInRange(from, to)
+	if err != nil {
+		return false, err
+	}
+	_, isValid, err := k.firstMatch(ctx, apiKey, encryptedKey, dates)
+	return isValid, err
+}
+
+// datesInRange returns midnight of every rotation date from the date of from through the date of
+// to, inclusive, in the helper's location. Ranges of more than MaxRangeDays days, or where to
+// precedes from, are rejected.
+func (k *KeyRotationHelper) datesInRange(from, to time.Time) ([]time.Time, error) {
+	first, last := k.startOfDay(from), k.startOfDay(to)
+	if last.Before(first) {
+		return nil, errors.New("keyrotation: range end precedes its start")
+	}
+	// Count calendar days in UTC so daylight saving transitions cannot skew the result
+	span := time.Date(last.Year(), last.Month(), last.Day(), 0, 0, 0, 0, time.UTC).
+		Sub(time.Date(first.Year(), first.Month(), first.Day(), 0, 0, 0, 0, time.UTC))
+	if span >= MaxRangeDays*24*time.Hour {
+		return nil, fmt.Errorf("keyrotation: range spans more than %d days", MaxRangeDays)
+	}
+
+	var dates []time.Time
+	for date := first; !date.After(last); date = date.AddDate(0, 0, 1) {
+		dates = append(dates, date)
+	}
+	return dates, nil
+}
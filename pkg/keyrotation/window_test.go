@@ -0,0 +1,288 @@
+package keyrotation
+
+import (
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+)
+
+func TestKeyRotationHelper_ValidateApiKeyWithinDays(t *testing.T) {
+	recorder := &eventRecorder{}
+	helper := newFakeHelper(t, WithLogger(recorder))
+	testApiKey := "testApiKey123"
+	twoDaysAgo := fakeHash(testApiKey, time.Now().UTC().AddDate(0, 0, -2))
+
+	isValid, err := helper.ValidateApiKeyWithinDays(testApiKey, twoDaysAgo, 3)
+	if err != nil {
+		t.Fatalf("ValidateApiKeyWithinDays failed: %v", err)
+	}
+	if !isValid {
+		t.Error("Expected key from two days ago to validate within a 3-day window")
+	}
+	if calls := len(recorder.recorded()); calls != 3 {
+		t.Errorf("Expected search to stop after 3 invocations, got %d", calls)
+	}
+
+	isValid, err = helper.ValidateApiKeyWithinDays(testApiKey, twoDaysAgo, 1)
+	if err != nil {
+		t.Fatalf("ValidateApiKeyWithinDays failed: %v", err)
+	}
+	if isValid {
+		t.Error("Expected key from two days ago not to validate within a 1-day window")
+	}
+
+	if _, err := helper.ValidateApiKeyWithinDays(testApiKey, twoDaysAgo, -1); err == nil {
+		t.Error("Expected error for negative daysBack")
+	}
+}
+
+func TestKeyRotationHelper_ValidateApiKeyWithGrace(t *testing.T) {
+	helper := newFakeHelper(t)
+	testApiKey := "testApiKey123"
+	midnight := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	yesterday := fakeHash(testApiKey, midnight.AddDate(0, 0, -1))
+	today := fakeHash(testApiKey, midnight)
+	tomorrow := fakeHash(testApiKey, midnight.AddDate(0, 0, 1))
+
+	tests := []struct {
+		name      string
+		at        time.Time
+		encrypted string
+		want      bool
+	}{
+		{"today at noon", midnight.Add(12 * time.Hour), today, true},
+		{"yesterday at noon", midnight.Add(12 * time.Hour), yesterday, false},
+		{"yesterday just after midnight", midnight.Add(5 * time.Minute), yesterday, true},
+		{"yesterday after the grace", midnight.Add(20 * time.Minute), yesterday, false},
+		{"tomorrow just before midnight", midnight.Add(24*time.Hour - 5*time.Minute), tomorrow, true},
+		{"tomorrow before the grace", midnight.Add(24*time.Hour - 20*time.Minute), tomorrow, false},
+	}
+	for _, tt := range tests {
+		got, err := helper.validateWithGrace(context.Background(), testApiKey, tt.encrypted, tt.at, 15*time.Minute)
+		if err != nil || got != tt.want {
+			t.Errorf("%s: got %v, %v; want %v", tt.name, got, err, tt.want)
+		}
+	}
+
+	if isValid, err := helper.ValidateApiKeyWithGrace(testApiKey, fakeHash(testApiKey, time.Now().UTC()), time.Minute); err != nil || !isValid {
+		t.Errorf("Expected today's key to validate, got %v, %v", isValid, err)
+	}
+	if _, err := helper.ValidateApiKeyWithGrace(testApiKey, today, -time.Minute); err == nil {
+		t.Error("Expected error for negative grace")
+	}
+}
+
+// blockUnlessValid wraps the in-memory engine so that only matching validations answer; the rest
+// block until their context is done, like a slow binary
+func blockUnlessValid(tracker *concurrencyTracker) engineFunc {
+	return func(ctx context.Context, r request) (string, error) {
+		tracker.start()
+		out, err := inMemoryEngine{algorithm: AlgorithmSHA256}.do(ctx, r)
+		if err != nil || out == "true" {
+			return out, err
+		}
+		<-ctx.Done()
+		return "", ctx.Err()
+	}
+}
+
+func TestWithWindowConcurrency_CancelsAfterMatch(t *testing.T) {
+	tracker := &concurrencyTracker{}
+	helper := NewInMemory(WithLogger(tracker), WithWindowConcurrency(4))
+	helper.engine = blockUnlessValid(tracker)
+	testApiKey := "testApiKey123"
+	twoDaysAgo := helper.startOfDay(time.Now()).AddDate(0, 0, -2)
+
+	date, isValid, err := helper.ValidateAndResolveDate(testApiKey, fakeHash(testApiKey, twoDaysAgo), 10)
+	if err != nil || !isValid || !date.Equal(twoDaysAgo) {
+		t.Fatalf("Expected a match at %s, got %v, %v, %v", twoDaysAgo, date, isValid, err)
+	}
+	if tracker.peak > 4 {
+		t.Errorf("Expected at most 4 concurrent invocations, got %d", tracker.peak)
+	}
+}
+
+func TestWithWindowConcurrency_NoMatch(t *testing.T) {
+	recorder := &eventRecorder{}
+	helper := newFakeHelper(t, WithLogger(recorder), WithWindowConcurrency(3))
+	testApiKey := "testApiKey123"
+
+	isValid, err := helper.ValidateApiKeyWithinDays(testApiKey, fakeHash("otherKey", time.Now().UTC()), 5)
+	if err != nil || isValid {
+		t.Errorf("Expected no match, got %v, %v", isValid, err)
+	}
+	if calls := len(recorder.recorded()); calls != 6 {
+		t.Errorf("Expected every date to be checked, got %d invocations", calls)
+	}
+	if _, err := helper.ValidateApiKeyWithinDays("", wrongCiphertext, 5); !errors.Is(err, ErrEmptyKey) {
+		t.Errorf("Expected ErrEmptyKey, got %v", err)
+	}
+	if _, err := NewWithOptions(WithWindowConcurrency(0)); err == nil {
+		t.Error("Expected a window concurrency below 1 to be rejected")
+	}
+}
+
+func TestWithWindowConcurrency_Canceled(t *testing.T) {
+	tracker := &concurrencyTracker{}
+	helper := NewInMemory(WithLogger(tracker), WithWindowConcurrency(4))
+	helper.engine = blockUnlessValid(tracker)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	if _, err := helper.ValidateApiKeyWithinDaysContext(ctx, "testApiKey123", wrongCiphertext, 10); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Expected context.DeadlineExceeded, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected cancellation to stop in-flight checks promptly, took %s", elapsed)
+	}
+}
+
+func TestKeyRotationHelper_ValidateAndResolveDate(t *testing.T) {
+	helper := newFakeHelper(t)
+	testApiKey := "testApiKey123"
+	now := time.Now().UTC()
+	yesterday := time.Date(now.Year(), now.Month(), now.Day()-1, 0, 0, 0, 0, time.UTC)
+
+	date, isValid, err := helper.ValidateAndResolveDate(testApiKey, fakeHash(testApiKey, yesterday), 5)
+	if err != nil {
+		t.Fatalf("ValidateAndResolveDate failed: %v", err)
+	}
+	if !isValid {
+		t.Fatal("Expected yesterday's key to validate")
+	}
+	if !date.Equal(yesterday) {
+		t.Errorf("Expected matched date %s, got %s", yesterday, date)
+	}
+
+	date, isValid, err = helper.ValidateAndResolveDate(testApiKey, fakeHash("otherKey", yesterday), 5)
+	if err != nil {
+		t.Fatalf("ValidateAndResolveDate failed: %v", err)
+	}
+	if isValid || !date.IsZero() {
+		t.Errorf("Expected no match, got %t at %s", isValid, date)
+	}
+}
+
+func TestKeyRotationHelper_ValidateApiKeyWithinDaysCapped(t *testing.T) {
+	recorder := &eventRecorder{}
+	helper := newFakeHelper(t, WithLogger(recorder))
+
+	for _, daysBack := range []int{MaxRangeDays, math.MaxInt32, math.MaxInt} {
+		if _, _, err := helper.ValidateAndResolveDate("testApiKey123", wrongCiphertext, daysBack); err == nil {
+			t.Errorf("Expected an error for a window of %d days", daysBack)
+		}
+		if _, err := helper.ValidateApiKeyWithinDays("testApiKey123", wrongCiphertext, daysBack); err == nil {
+			t.Errorf("Expected an error for a window of %d days", daysBack)
+		}
+	}
+	if calls := len(recorder.recorded()); calls != 0 {
+		t.Errorf("Expected oversized windows to be rejected before calling the binary, got %d invocations", calls)
+	}
+
+	if _, err := NewInMemory().ValidateApiKeyWithinDays("testApiKey123", wrongCiphertext, MaxRangeDays-1); err != nil {
+		t.Errorf("Expected a window of exactly MaxRangeDays days to be accepted, got %v", err)
+	}
+}
+
+func TestKeyRotationHelper_ValidateApiKeyInRange(t *testing.T) {
+	helper := newFakeHelper(t)
+	testApiKey := "testApiKey123"
+	now := time.Now().UTC()
+	tomorrow := fakeHash(testApiKey, now.AddDate(0, 0, 1))
+
+	isValid, err := helper.ValidateApiKeyInRange(testApiKey, tomorrow, now.AddDate(0, 0, -1), now.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("ValidateApiKeyInRange failed: %v", err)
+	}
+	if !isValid {
+		t.Error("Expected key from tomorrow to validate within the range")
+	}
+
+	isValid, err = helper.ValidateApiKeyInRange(testApiKey, tomorrow, now.AddDate(0, 0, -2), now)
+	if err != nil {
+		t.Fatalf("ValidateApiKeyInRange failed: %v", err)
+	}
+	if isValid {
+		t.Error("Expected key from tomorrow not to validate in a range ending today")
+	}
+
+	if _, err := helper.ValidateApiKeyInRange(testApiKey, tomorrow, now, now.AddDate(0, 0, -1)); err == nil {
+		t.Error("Expected error for a range ending before it starts")
+	}
+}
+
+func TestKeyRotationHelper_ValidateApiKeyInRangeCapped(t *testing.T) {
+	recorder := &eventRecorder{}
+	helper := newFakeHelper(t, WithLogger(recorder))
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if _, err := helper.ValidateApiKeyInRange("testApiKey123", wrongCiphertext, from, from.AddDate(0, 0, MaxRangeDays)); err == nil {
+		t.Error("Expected error for a range longer than MaxRangeDays")
+	}
+	if _, err := helper.ValidateApiKeyInRange("testApiKey123", wrongCiphertext, from, from.AddDate(1000, 0, 0)); err == nil {
+		t.Error("Expected error for an absurdly long range")
+	}
+	if calls := len(recorder.recorded()); calls != 0 {
+		t.Errorf("Expected oversized ranges to be rejected before calling the binary, got %d invocations", calls)
+	}
+
+	if _, err := NewInMemory().ValidateApiKeyInRange("testApiKey123", wrongCiphertext, from, from.AddDate(0, 0, MaxRangeDays-1)); err != nil {
+		t.Errorf("Expected a range of exactly MaxRangeDays days to be accepted, got %v", err)
+	}
+}
+
+func TestKeyRotationHelper_ValidateApiKeyWithinIntervals(t *testing.T) {
+	now := time.Date(2025, 3, 10, 1, 30, 0, 0, time.UTC)
+	for name, tc := range map[string]struct {
+		interval time.Duration
+		inside   time.Time
+		outside  time.Time
+	}{
+		"daily":  {24 * time.Hour, now.AddDate(0, 0, -2), now.AddDate(0, 0, -3)},
+		"hourly": {time.Hour, now.Add(-2 * time.Hour), now.Add(-3 * time.Hour)},
+		"6h":     {6 * time.Hour, now.Add(-12 * time.Hour), now.Add(-18 * time.Hour)},
+	} {
+		helper := NewInMemory(WithRotationInterval(tc.interval), WithClock(func() time.Time { return now }))
+		inside, _ := helper.EncryptApiKeyWithDate("testApiKey123", tc.inside)
+		outside, _ := helper.EncryptApiKeyWithDate("testApiKey123", tc.outside)
+
+		if valid, err := helper.ValidateApiKeyWithinIntervals("testApiKey123", inside, 2); err != nil || !valid {
+			t.Errorf("%s: Expected the key from 2 intervals ago to validate, got %v, %v", name, valid, err)
+		}
+		if valid, err := helper.ValidateApiKeyWithinIntervals("testApiKey123", outside, 2); err != nil || valid {
+			t.Errorf("%s: Expected the key from 3 intervals ago to be rejected, got %v, %v", name, valid, err)
+		}
+	}
+
+	if _, err := NewInMemory().ValidateApiKeyWithinIntervals("testApiKey123", wrongCiphertext, -1); err == nil {
+		t.Error("Expected an error for a negative n")
+	}
+}
+
+func TestKeyRotationHelper_ValidateApiKeyWithinIntervalsCapped(t *testing.T) {
+	for name, tc := range map[string]struct {
+		interval time.Duration
+		max      int
+	}{
+		"daily":  {24 * time.Hour, MaxRangeDays},
+		"hourly": {time.Hour, MaxRangeDays * 24},
+		"6h":     {6 * time.Hour, MaxRangeDays * 4},
+	} {
+		recorder := &eventRecorder{}
+		helper := newFakeHelper(t, WithRotationInterval(tc.interval), WithLogger(recorder))
+		for _, n := range []int{tc.max, math.MaxInt32, math.MaxInt} {
+			if _, err := helper.ValidateApiKeyWithinIntervals("testApiKey123", wrongCiphertext, n); err == nil {
+				t.Errorf("%s: Expected an error for a window of %d intervals", name, n)
+			}
+		}
+		if calls := len(recorder.recorded()); calls != 0 {
+			t.Errorf("%s: Expected oversized windows to be rejected before calling the binary, got %d invocations", name, calls)
+		}
+		if _, err := NewInMemory(WithRotationInterval(tc.interval)).ValidateApiKeyWithinIntervals("testApiKey123", wrongCiphertext, tc.max-1); err != nil {
+			t.Errorf("%s: Expected a window of exactly MaxRangeDays days to be accepted, got %v", name, err)
+		}
+	}
+}